@@ -1,13 +1,19 @@
 package cache
 
 import (
+	"bytes"
 	"encoding/gob"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"text/tabwriter"
 	"time"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/fileutil"
 )
 
 // Entry represents a cache entry
@@ -16,25 +22,42 @@ type Entry struct {
 	Value      interface{}
 	Expiration time.Time
 	Size       int64
+
+	// Optional secondary key, such as a content fingerprint
+	IndexKey string
 }
 
+// DefaultSaveInterval is how often New persists a changed cache to disk
+const DefaultSaveInterval = 1 * time.Minute
+
 // Cache provides a thread-safe in-memory cache with disk persistence
 type Cache struct {
-	entries     map[string]*Entry
-	mu          sync.RWMutex
-	dir         string
-	ttl         time.Duration
-	maxSize     int64
-	currentSize int64
-	hits        atomic.Uint64
-	misses      atomic.Uint64
-	evictions   atomic.Uint64
-	stopChan    chan struct{}
-	wg          sync.WaitGroup
+	entries      map[string]*Entry
+	index        map[string]*Entry
+	mu           sync.RWMutex
+	dir          string
+	ttl          time.Duration
+	maxSize      int64
+	currentSize  int64
+	saveInterval time.Duration
+	dirty        atomic.Bool
+	saveMu       sync.Mutex
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+	evictions    atomic.Uint64
+	stopChan     chan struct{}
+	wg           sync.WaitGroup
 }
 
-// New creates a new cache instance
+// New creates a new cache instance saving to disk every DefaultSaveInterval
 func New(dir string, ttl time.Duration, maxSize int64) (*Cache, error) {
+	return NewWithSaveInterval(dir, ttl, maxSize, DefaultSaveInterval)
+}
+
+// NewWithSaveInterval creates a new cache instance that saves to disk every
+// saveInterval if it changed. A zero interval disables periodic saves; the
+// cache is then only written by Save and Close.
+func NewWithSaveInterval(dir string, ttl time.Duration, maxSize int64, saveInterval time.Duration) (*Cache, error) {
 	// Create cache directory if it doesn't exist
 	if dir != "" {
 		if err := os.MkdirAll(dir, 0755); err != nil {
@@ -43,11 +66,13 @@ func New(dir string, ttl time.Duration, maxSize int64) (*Cache, error) {
 	}
 
 	c := &Cache{
-		entries:  make(map[string]*Entry),
-		dir:      dir,
-		ttl:      ttl,
-		maxSize:  maxSize,
-		stopChan: make(chan struct{}),
+		entries:      make(map[string]*Entry),
+		index:        make(map[string]*Entry),
+		dir:          dir,
+		ttl:          ttl,
+		maxSize:      maxSize,
+		saveInterval: saveInterval,
+		stopChan:     make(chan struct{}),
 	}
 
 	// Load cache from disk if exists
@@ -88,16 +113,38 @@ func (c *Cache) Get(key string) interface{} {
 	return entry.Value
 }
 
+// GetByIndex retrieves a value by its secondary key. Index entries outlive
+// their primary key being overwritten or removed until they expire, so a value
+// can still be found after the file it was cached for is renamed.
+func (c *Cache) GetByIndex(indexKey string) interface{} {
+	c.mu.RLock()
+	entry, exists := c.index[indexKey]
+	c.mu.RUnlock()
+
+	if !exists || time.Now().After(entry.Expiration) {
+		return nil
+	}
+
+	return entry.Value
+}
+
 // Set stores a value in the cache
 func (c *Cache) Set(key string, value interface{}) {
+	c.SetWithIndex(key, "", value)
+}
+
+// SetWithIndex stores a value in the cache and, if indexKey is not empty,
+// makes it retrievable by that secondary key as well
+func (c *Cache) SetWithIndex(key, indexKey string, value interface{}) {
 	// Estimate size (simplified)
-	size := int64(len(key) + 100) // Rough estimate
+	size := int64(len(key) + len(indexKey) + 100) // Rough estimate
 
 	entry := &Entry{
 		Key:        key,
 		Value:      value,
 		Expiration: time.Now().Add(c.ttl),
 		Size:       size,
+		IndexKey:   indexKey,
 	}
 
 	c.mu.Lock()
@@ -115,6 +162,34 @@ func (c *Cache) Set(key string, value interface{}) {
 
 	c.entries[key] = entry
 	c.currentSize += size
+
+	if indexKey != "" {
+		c.index[indexKey] = entry
+	}
+	c.dirty.Store(true)
+}
+
+// Delete removes the entry stored under key. Like an overwritten entry, its
+// index entry stays until it expires, so a renamed file still finds its value.
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, exists := c.entries[key]
+	if !exists {
+		return
+	}
+	c.currentSize -= entry.Size
+	delete(c.entries, key)
+	c.dirty.Store(true)
+}
+
+// unindex drops the index entry for a removed entry, unless the index key has
+// since been taken over by another entry. Must be called with c.mu held.
+func (c *Cache) unindex(entry *Entry) {
+	if entry.IndexKey != "" && c.index[entry.IndexKey] == entry {
+		delete(c.index, entry.IndexKey)
+	}
 }
 
 // evictOldest removes the oldest entry from cache
@@ -131,34 +206,77 @@ func (c *Cache) evictOldest() {
 
 	if oldestKey != "" {
 		c.currentSize -= c.entries[oldestKey].Size
+		c.unindex(c.entries[oldestKey])
 		delete(c.entries, oldestKey)
 		c.evictions.Add(1)
 	}
 }
 
-// Clear removes all entries from the cache
-func (c *Cache) Clear() {
+// Clear removes all entries from the cache and returns how many were removed
+func (c *Cache) Clear() int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	cleared := len(c.entries)
 	c.entries = make(map[string]*Entry)
+	c.index = make(map[string]*Entry)
 	c.currentSize = 0
+	c.dirty.Store(true)
+
+	return cleared
 }
 
-// cleanup periodically removes expired entries
+// Prune removes the entries whose value remove reports true for and returns
+// how many were removed. Index entries holding such a value go as well.
+func (c *Cache) Prune(remove func(key string, value interface{}) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pruned := 0
+	for key, entry := range c.entries {
+		if remove(key, entry.Value) {
+			c.currentSize -= entry.Size
+			c.unindex(entry)
+			delete(c.entries, key)
+			pruned++
+		}
+	}
+
+	// Index entries may outlive the primary entry they were stored with
+	for indexKey, entry := range c.index {
+		if remove(entry.Key, entry.Value) {
+			delete(c.index, indexKey)
+		}
+	}
+
+	if pruned > 0 {
+		c.dirty.Store(true)
+	}
+	return pruned
+}
+
+// cleanup periodically removes expired entries and saves the cache to disk
 func (c *Cache) cleanup() {
 	defer c.wg.Done()
 
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
 
+	// A nil channel never fires, so periodic saves stay off
+	var saveC <-chan time.Time
+	if c.saveInterval > 0 {
+		saveTicker := time.NewTicker(c.saveInterval)
+		defer saveTicker.Stop()
+		saveC = saveTicker.C
+	}
+
 	for {
 		select {
 		case <-c.stopChan:
 			return
 		case <-ticker.C:
 			c.removeExpired()
-			// Periodically save to disk
+		case <-saveC:
 			if err := c.save(); err != nil {
 				fmt.Printf("Failed to save cache to disk: %v\n", err)
 			}
@@ -177,72 +295,118 @@ func (c *Cache) removeExpired() {
 		if now.After(entry.Expiration) {
 			c.currentSize -= entry.Size
 			delete(c.entries, key)
+			c.dirty.Store(true)
 		}
 	}
+
+	// Index entries may outlive the primary entry they were stored with
+	for indexKey, entry := range c.index {
+		if now.After(entry.Expiration) {
+			delete(c.index, indexKey)
+		}
+	}
+}
+
+// Save persists the cache to disk if it changed since the last save
+func (c *Cache) Save() error {
+	return c.save()
 }
 
-// save persists the cache to disk
+// save persists the cache to disk if it changed since the last save
 func (c *Cache) save() error {
 	if c.dir == "" {
 		return nil
 	}
 
+	// One writer at a time, so a slow periodic save and a Save call can't
+	// rotate the backup under each other
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	// Clearing the flag under the read lock means any later change marks
+	// the cache dirty again
 	c.mu.RLock()
+	if !c.dirty.Swap(false) {
+		c.mu.RUnlock()
+		return nil
+	}
 	entries := make(map[string]*Entry, len(c.entries))
 	for k, v := range c.entries {
 		entries[k] = v
 	}
 	c.mu.RUnlock()
 
-	file := filepath.Join(c.dir, "cache.gob")
-	tempFile := file + ".tmp"
-
-	f, err := os.Create(tempFile)
-	if err != nil {
-		return fmt.Errorf("failed to create cache file: %w", err)
-	}
-	defer f.Close()
+	// Retry on the next save
+	saved := false
+	defer func() {
+		if !saved {
+			c.dirty.Store(true)
+		}
+	}()
 
-	encoder := gob.NewEncoder(f)
-	if err := encoder.Encode(entries); err != nil {
-		os.Remove(tempFile)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entries); err != nil {
 		return fmt.Errorf("failed to encode cache: %w", err)
 	}
 
-	if err := f.Sync(); err != nil {
-		os.Remove(tempFile)
-		return fmt.Errorf("failed to sync cache file: %w", err)
+	file := filepath.Join(c.dir, "cache.gob")
+
+	// Keep the previous good version as a backup; load falls back to it if
+	// the write below fails. cache.gob stays in place until the atomic
+	// rename replaces it.
+	if _, err := os.Stat(file); err == nil {
+		if err := backupFile(file, file+".bak"); err != nil {
+			return fmt.Errorf("failed to back up cache file: %w", err)
+		}
 	}
 
-	// Atomic rename
-	if err := os.Rename(tempFile, file); err != nil {
-		os.Remove(tempFile)
-		return fmt.Errorf("failed to rename cache file: %w", err)
+	if err := fileutil.WriteAtomic(file, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
 	}
 
+	saved = true
 	return nil
 }
 
-// load restores the cache from disk
+// backupFile replaces backup with the current contents of file, leaving
+// file itself untouched. A hard link shares the contents without copying
+// them; the atomic rename of the next save gives file a new inode and leaves
+// the backup on the old one. Filesystems without hard links get a copy.
+func backupFile(file, backup string) error {
+	if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Link(file, backup); err == nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteAtomic(backup, data, 0644)
+}
+
+// load restores the cache from disk, falling back to the backup copy
 func (c *Cache) load() error {
 	if c.dir == "" {
 		return nil
 	}
 
 	file := filepath.Join(c.dir, "cache.gob")
-	f, err := os.Open(file)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // No cache file yet
+	entries, err := c.loadFile(file)
+	if err != nil || entries == nil {
+		backup := file + ".bak"
+		if backupEntries, backupErr := c.loadFile(backup); backupErr == nil && backupEntries != nil {
+			if err != nil {
+				fmt.Printf("Failed to load cache from %s: %v\n", file, err)
+			}
+			fmt.Printf("Loaded cache from backup %s\n", backup)
+			entries, err = backupEntries, nil
 		}
-		return fmt.Errorf("failed to open cache file: %w", err)
 	}
-	defer f.Close()
-
-	var entries map[string]*Entry
-	decoder := gob.NewDecoder(f)
-	if err := decoder.Decode(&entries); err != nil {
-		return fmt.Errorf("failed to decode cache: %w", err)
+	if err != nil {
+		return err
 	}
 
 	// Remove expired entries and calculate size
@@ -256,6 +420,9 @@ func (c *Cache) load() error {
 		if now.Before(entry.Expiration) {
 			c.entries[key] = entry
 			totalSize += entry.Size
+			if entry.IndexKey != "" {
+				c.index[entry.IndexKey] = entry
+			}
 		}
 	}
 
@@ -263,6 +430,26 @@ func (c *Cache) load() error {
 	return nil
 }
 
+// loadFile decodes cache entries from a single file
+func (c *Cache) loadFile(file string) (map[string]*Entry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // No cache file yet
+		}
+		return nil, fmt.Errorf("failed to open cache file: %w", err)
+	}
+	defer f.Close()
+
+	var entries map[string]*Entry
+	decoder := gob.NewDecoder(f)
+	if err := decoder.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to decode cache: %w", err)
+	}
+
+	return entries, nil
+}
+
 // Stats returns cache statistics
 func (c *Cache) Stats() map[string]interface{} {
 	c.mu.RLock()
@@ -287,6 +474,42 @@ func (c *Cache) Stats() map[string]interface{} {
 	}
 }
 
+// Dump writes a table of the entries in the cache, sorted by key, for
+// debugging. describe renders an entry's value; without it the value's type
+// is shown. Entries holding no value show "-".
+func (c *Cache) Dump(w io.Writer, describe func(Entry) string) error {
+	c.mu.RLock()
+	entries := make([]Entry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		entries = append(entries, *entry)
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tINDEX KEY\tEXPIRES\tSIZE\tVALUE")
+	for _, entry := range entries {
+		indexKey := entry.IndexKey
+		if indexKey == "" {
+			indexKey = "-"
+		}
+		value := "-"
+		if entry.Value != nil {
+			if describe != nil {
+				value = describe(entry)
+			} else {
+				value = fmt.Sprintf("%T", entry.Value)
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n",
+			entry.Key, indexKey, entry.Expiration.Format(time.RFC3339), entry.Size, value)
+	}
+	return tw.Flush()
+}
+
 // Close shuts down the cache
 func (c *Cache) Close() {
 	close(c.stopChan)
@@ -0,0 +1,403 @@
+// internal/cert/cert.go
+
+package cert
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Errors returned by Parse for data that holds no certificate at all
+var (
+	// ErrNoPEMBlock means the data is neither PEM nor a DER certificate
+	ErrNoPEMBlock = errors.New("no PEM block found and data is not a DER certificate")
+
+	// ErrNotCertificate means the first PEM block is not a certificate,
+	// e.g. a private key or a certificate request
+	ErrNotCertificate = errors.New("PEM block is not a certificate")
+)
+
+// Parse parses the leaf certificate from PEM or DER encoded data. PEM data
+// may carry other blocks, such as the private key of combined key and
+// certificate files; the first block that parses as a certificate is the leaf.
+func Parse(data []byte) (*x509.Certificate, error) {
+	certs, err := parseCertificates(data, 1)
+	if err != nil {
+		return nil, err
+	}
+	return certs[0], nil
+}
+
+// ParseChain parses every certificate in PEM or DER encoded data, in file
+// order. Blocks that aren't certificates, such as keys, are skipped.
+func ParseChain(data []byte) ([]*x509.Certificate, error) {
+	return parseCertificates(data, 0)
+}
+
+// parseCertificates parses the certificates in PEM or DER encoded data, in
+// file order, stopping after limit certificates when limit is positive
+func parseCertificates(data []byte, limit int) ([]*x509.Certificate, error) {
+	// Decode PEM block
+	block, rest := pem.Decode(data)
+	if block == nil {
+		// Try to parse as DER
+		cert, err := x509.ParseCertificate(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w (%v)", ErrNoPEMBlock, err)
+		}
+		return []*x509.Certificate{cert}, nil
+	}
+
+	firstType := block.Type
+	var certs []*x509.Certificate
+	for ; block != nil; block, rest = pem.Decode(rest) {
+		// Parse PEM certificate
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			certs = append(certs, cert)
+			if len(certs) == limit {
+				break
+			}
+			continue
+		}
+		if block.Type == "CERTIFICATE" {
+			return nil, fmt.Errorf("failed to parse PEM certificate: %w", err)
+		}
+
+		// Legacy types like X509 CERTIFICATE still parse; anything else
+		// that fails, like a key, wasn't meant to be a certificate
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("failed to parse PEM certificate: %w: found %s", ErrNotCertificate, firstType)
+	}
+	return certs, nil
+}
+
+// ChainDepth counts the certificates in PEM or DER encoded data. PEM data
+// contributes one per CERTIFICATE block; DER data holds a single certificate.
+func ChainDepth(data []byte) int {
+	depth := 0
+	foundPEM := false
+
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		foundPEM = true
+		if block.Type == "CERTIFICATE" {
+			depth++
+		}
+	}
+
+	if !foundPEM {
+		return 1
+	}
+	return depth
+}
+
+// Encodings reported by Format
+const (
+	FormatPEM    = "pem"
+	FormatDER    = "der"
+	FormatPKCS7  = "pkcs7"
+	FormatPKCS12 = "pkcs12"
+)
+
+// oidSignedData identifies PKCS #7 signed data, the container of .p7b files
+var oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+// Format reports how certificate data is encoded, judged by its content
+// rather than its file name. PEM data holding a PKCS7 block counts as
+// PKCS #7. Binary data is PKCS #7 when it is a signed data content info,
+// PKCS #12 when it starts with the PFX version 3, and DER otherwise.
+func Format(data []byte) string {
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type == "PKCS7" {
+			return FormatPKCS7
+		}
+		return FormatPEM
+	}
+
+	var outer asn1.RawValue
+	if _, err := asn1.Unmarshal(data, &outer); err != nil || outer.Tag != asn1.TagSequence {
+		return FormatDER
+	}
+
+	var first asn1.RawValue
+	if _, err := asn1.Unmarshal(outer.Bytes, &first); err != nil {
+		return FormatDER
+	}
+	switch first.Tag {
+	case asn1.TagOID:
+		var oid asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(first.FullBytes, &oid); err == nil && oid.Equal(oidSignedData) {
+			return FormatPKCS7
+		}
+	case asn1.TagInteger:
+		var version int
+		if _, err := asn1.Unmarshal(first.FullBytes, &version); err == nil && version == 3 {
+			return FormatPKCS12
+		}
+	}
+	return FormatDER
+}
+
+// Key weakness reasons reported by KeyWeaknesses
+const (
+	WeaknessSmallModulus        = "small_modulus"
+	WeaknessSmallExponent       = "small_exponent"
+	WeaknessNonStandardExponent = "non_standard_exponent"
+	WeaknessSmallCurve          = "small_curve"
+)
+
+// minRSAModulusBits is the smallest acceptable RSA modulus size
+const minRSAModulusBits = 2048
+
+// minECDSACurveBits is the smallest acceptable ECDSA curve size
+const minECDSACurveBits = 256
+
+// standardRSAExponent is the conventional RSA public exponent
+const standardRSAExponent = 65537
+
+// KeyWeaknesses reports every weakness found in a certificate's RSA or ECDSA
+// public key. Returns nil for other keys and keys without issues.
+func KeyWeaknesses(cert *x509.Certificate) []string {
+	if ecKey, ok := cert.PublicKey.(*ecdsa.PublicKey); ok {
+		if ecKey.Curve.Params().BitSize < minECDSACurveBits {
+			return []string{WeaknessSmallCurve}
+		}
+		return nil
+	}
+
+	rsaKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil
+	}
+
+	var weaknesses []string
+	if rsaKey.N.BitLen() < minRSAModulusBits {
+		weaknesses = append(weaknesses, WeaknessSmallModulus)
+	}
+
+	switch {
+	case rsaKey.E < standardRSAExponent:
+		weaknesses = append(weaknesses, WeaknessSmallExponent)
+	case rsaKey.E > standardRSAExponent:
+		weaknesses = append(weaknesses, WeaknessNonStandardExponent)
+	}
+
+	return weaknesses
+}
+
+// CurveName returns the curve of an ECDSA or Ed25519 public key, e.g. P-256
+// or Ed25519. Returns an empty string for other keys.
+func CurveName(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		return key.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return ""
+	}
+}
+
+// IsSelfSigned checks if a certificate is signed by its own key. The signature
+// is verified directly rather than with CheckSignatureFrom, which rejects
+// self-signed leaf certificates that don't carry CA basic constraints.
+func IsSelfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return false
+	}
+
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// Certificate types reported by Classify
+const (
+	TypeLeaf         = "leaf"
+	TypeIntermediate = "intermediate"
+	TypeRoot         = "root"
+)
+
+// Classify reports whether a certificate is a leaf, an intermediate CA or a
+// root, a self-signed CA. Self-signed certificates without CA basic
+// constraints are leaves.
+func Classify(cert *x509.Certificate) string {
+	if !cert.BasicConstraintsValid || !cert.IsCA {
+		return TypeLeaf
+	}
+	if IsSelfSigned(cert) {
+		return TypeRoot
+	}
+	return TypeIntermediate
+}
+
+// keyUsageNames maps key usage bits to the names used in metrics
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digital_signature"},
+	{x509.KeyUsageContentCommitment, "content_commitment"},
+	{x509.KeyUsageKeyEncipherment, "key_encipherment"},
+	{x509.KeyUsageDataEncipherment, "data_encipherment"},
+	{x509.KeyUsageKeyAgreement, "key_agreement"},
+	{x509.KeyUsageCertSign, "cert_sign"},
+	{x509.KeyUsageCRLSign, "crl_sign"},
+	{x509.KeyUsageEncipherOnly, "encipher_only"},
+	{x509.KeyUsageDecipherOnly, "decipher_only"},
+}
+
+// extKeyUsageNames maps extended key usages to the names used in metrics
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "any",
+	x509.ExtKeyUsageServerAuth:                     "server_auth",
+	x509.ExtKeyUsageClientAuth:                     "client_auth",
+	x509.ExtKeyUsageCodeSigning:                    "code_signing",
+	x509.ExtKeyUsageEmailProtection:                "email_protection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "ipsec_end_system",
+	x509.ExtKeyUsageIPSECTunnel:                    "ipsec_tunnel",
+	x509.ExtKeyUsageIPSECUser:                      "ipsec_user",
+	x509.ExtKeyUsageTimeStamping:                   "time_stamping",
+	x509.ExtKeyUsageOCSPSigning:                    "ocsp_signing",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "microsoft_server_gated_crypto",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "netscape_server_gated_crypto",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "microsoft_commercial_code_signing",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "microsoft_kernel_code_signing",
+}
+
+// KeyUsages returns the names of the key usage bits set on a certificate
+func KeyUsages(cert *x509.Certificate) []string {
+	var usages []string
+	for _, ku := range keyUsageNames {
+		if cert.KeyUsage&ku.usage != 0 {
+			usages = append(usages, ku.name)
+		}
+	}
+	return usages
+}
+
+// ExtKeyUsages returns the names of a certificate's extended key usages.
+// Usages crypto/x509 doesn't know are reported by OID.
+func ExtKeyUsages(cert *x509.Certificate) []string {
+	var usages []string
+	for _, eku := range cert.ExtKeyUsage {
+		if name, ok := extKeyUsageNames[eku]; ok {
+			usages = append(usages, name)
+		}
+	}
+	for _, oid := range cert.UnknownExtKeyUsage {
+		usages = append(usages, oid.String())
+	}
+	return usages
+}
+
+// oidSCTList identifies the embedded signed certificate timestamp list extension
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// HasSCT checks if a certificate embeds signed certificate timestamps from
+// Certificate Transparency logs. Only presence is checked; the list is not decoded.
+func HasSCT(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidSCTList) {
+			return true
+		}
+	}
+	return false
+}
+
+// SPKISHA256 returns the base64 SHA-256 digest of a certificate's subject
+// public key info, the pin used for public key pinning. It stays the same
+// across renewals that reuse the key.
+func SPKISHA256(cert *x509.Certificate) string {
+	digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(digest[:])
+}
+
+// BaseDomain returns the domain a DNS name belongs to for grouping, found by
+// stripping a leading wildcard or, failing that, the leftmost label. Names of
+// two labels or fewer are returned as they are, so example.com stays
+// example.com. IP addresses and names that aren't hostnames have no base
+// domain.
+func BaseDomain(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	if name == "" || net.ParseIP(strings.Trim(name, "[]")) != nil || strings.ContainsAny(name, " /@:") {
+		return ""
+	}
+
+	if strings.HasPrefix(name, "*.") {
+		return name[2:]
+	}
+	if strings.Count(name, ".") < 2 {
+		return name
+	}
+	return name[strings.Index(name, ".")+1:]
+}
+
+// MatchingSANs returns the subject alternative names of a certificate that cover
+// the given hostname or IP address, honoring single-label wildcards
+func MatchingSANs(cert *x509.Certificate, name string) []string {
+	var matched []string
+
+	// IP addresses only match IP SANs
+	if ip := net.ParseIP(strings.Trim(name, "[]")); ip != nil {
+		for _, san := range cert.IPAddresses {
+			if san.Equal(ip) {
+				matched = append(matched, san.String())
+			}
+		}
+		return matched
+	}
+
+	return MatchingDNSNames(cert.DNSNames, name)
+}
+
+// MatchingDNSNames returns the DNS names that cover the given hostname,
+// honoring single-label wildcards
+func MatchingDNSNames(dnsNames []string, name string) []string {
+	var matched []string
+
+	host := strings.ToLower(strings.TrimSuffix(name, "."))
+	for _, san := range dnsNames {
+		if matchHostname(strings.ToLower(strings.TrimSuffix(san, ".")), host) {
+			matched = append(matched, san)
+		}
+	}
+
+	return matched
+}
+
+// matchHostname matches a host against a DNS SAN pattern, where a leading
+// "*." wildcard covers exactly one label
+func matchHostname(pattern, host string) bool {
+	if pattern == host {
+		return true
+	}
+
+	if !strings.HasPrefix(pattern, "*.") {
+		return false
+	}
+
+	// The wildcard must replace exactly one non-empty label
+	dot := strings.Index(host, ".")
+	if dot <= 0 {
+		return false
+	}
+	return host[dot:] == pattern[1:]
+}
@@ -3,12 +3,20 @@
 package config
 
 import (
+	"bytes"
+	"crypto/x509"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/brandonhon/tls-cert-monitor/internal/fileutil"
+	"github.com/brandonhon/tls-cert-monitor/internal/probe"
 	"github.com/spf13/viper"
 )
 
@@ -22,12 +30,140 @@ type Config struct {
 	TLSCert string `mapstructure:"tls_cert" yaml:"tls_cert"`
 	TLSKey  string `mapstructure:"tls_key" yaml:"tls_key"`
 
+	// Bearer token required by administrative endpoints (disabled when empty)
+	AuthToken string `mapstructure:"auth_token" yaml:"auth_token"`
+
+	// Optional endpoints
+	EnableAlertsEndpoint bool `mapstructure:"enable_alerts_endpoint" yaml:"enable_alerts_endpoint"`
+	EnablePprof          bool `mapstructure:"enable_pprof" yaml:"enable_pprof"`
+
+	// Requests per second allowed on endpoints that read certificate files
+	// (0 disables the limit)
+	DiskEndpointRateLimit float64 `mapstructure:"disk_endpoint_rate_limit" yaml:"disk_endpoint_rate_limit"`
+
 	// Certificate monitoring
 	CertificateDirectories []string      `mapstructure:"certificate_directories" yaml:"certificate_directories"`
 	ScanInterval           time.Duration `mapstructure:"scan_interval" yaml:"scan_interval"`
+	ScanJitter             time.Duration `mapstructure:"scan_jitter" yaml:"scan_jitter"`
+	ScanTimeout            time.Duration `mapstructure:"scan_timeout" yaml:"scan_timeout"`
+	ParseK8sSecrets        bool          `mapstructure:"parse_k8s_secrets" yaml:"parse_k8s_secrets"`
+	StrictGlobs            bool          `mapstructure:"strict_globs" yaml:"strict_globs"`
+	MaxCertFileSize        int64         `mapstructure:"max_cert_file_size" yaml:"max_cert_file_size"`
+	MaxDepth               int           `mapstructure:"max_depth" yaml:"max_depth"`
+	ExcludePaths           []string      `mapstructure:"exclude_paths" yaml:"exclude_paths"`
+	ResolveSymlinks        bool          `mapstructure:"resolve_symlinks" yaml:"resolve_symlinks"`
+	ManifestFile           string        `mapstructure:"manifest_file" yaml:"manifest_file"`
+
+	// Skip directories in later scans, for a growing number of scans, after
+	// they yield more than ParseErrorThreshold parse errors and no
+	// certificate
+	BackoffOnParseErrors bool `mapstructure:"backoff_on_parse_errors" yaml:"backoff_on_parse_errors"`
+	ParseErrorThreshold  int  `mapstructure:"parse_error_threshold" yaml:"parse_error_threshold"`
+
+	// S3 bucket prefix scanned alongside the certificate directories
+	// (disabled when s3_bucket is empty)
+	S3Bucket   string `mapstructure:"s3_bucket" yaml:"s3_bucket"`
+	S3Prefix   string `mapstructure:"s3_prefix" yaml:"s3_prefix"`
+	S3Region   string `mapstructure:"s3_region" yaml:"s3_region"`
+	S3Endpoint string `mapstructure:"s3_endpoint" yaml:"s3_endpoint"`
+
+	// host:port[@server_name] endpoints whose presented certificate is
+	// monitored alongside the files, and how long each TLS handshake may take
+	Endpoints       []string      `mapstructure:"endpoints" yaml:"endpoints"`
+	EndpointTimeout time.Duration `mapstructure:"endpoint_timeout" yaml:"endpoint_timeout"`
+
+	// Refuse to start when a certificate directory is group or world writable
+	RequireSecureDirs bool `mapstructure:"require_secure_dirs" yaml:"require_secure_dirs"`
+
+	// Handling of certificates found in more than one file
+	DuplicatePolicy string `mapstructure:"duplicate_policy" yaml:"duplicate_policy"`
+
+	// PEM CA bundle for issuer classification (name heuristics when empty)
+	CABundleFile string `mapstructure:"ca_bundle_file" yaml:"ca_bundle_file"`
+
+	// Signature algorithm policy (empty allows everything)
+	AllowedSigAlgs []string `mapstructure:"allowed_sig_algs" yaml:"allowed_sig_algs"`
+
+	// Key curves reported by ssl_cert_deprecated_curve_total
+	DeprecatedCurves []string `mapstructure:"deprecated_curves" yaml:"deprecated_curves"`
+
+	// Expiry alerting
+	ExpiryThreshold time.Duration `mapstructure:"expiry_threshold" yaml:"expiry_threshold"`
+	IgnoreNewerThan time.Duration `mapstructure:"ignore_newer_than" yaml:"ignore_newer_than"`
+
+	// Renewal windows by issuer code for ssl_cert_renewal_due; issuers
+	// without an entry use ExpiryThreshold
+	RenewalThresholds map[int]time.Duration `mapstructure:"renewal_thresholds" yaml:"renewal_thresholds"`
+
+	// Validity periods longer than this many days are reported (0 disables)
+	MaxValidityDays int `mapstructure:"max_validity_days" yaml:"max_validity_days"`
+
+	// Maximum validity periods taking effect on a date, such as the CA/Browser
+	// Forum lifetime reductions; the latest one in effect applies
+	ValidityPolicies []ValidityPolicy `mapstructure:"validity_policies" yaml:"validity_policies"`
+
+	// Certificate Transparency
+	CheckSCT bool `mapstructure:"check_sct" yaml:"check_sct"`
+
+	// Per-usage key usage and extended key usage metrics
+	ExportKeyUsage bool `mapstructure:"export_key_usage" yaml:"export_key_usage"`
+
+	// Per-certificate SPKI SHA-256 pin metric
+	ExportSPKI bool `mapstructure:"export_spki" yaml:"export_spki"`
+
+	// Per-certificate subject organization metric
+	ExportOrganization bool `mapstructure:"export_organization" yaml:"export_organization"`
+
+	// Network validation (opt-in)
+	ValidateIPSANs     bool `mapstructure:"validate_ip_sans" yaml:"validate_ip_sans"`
+	NetworkConcurrency int  `mapstructure:"network_concurrency" yaml:"network_concurrency"`
+
+	// Webhook notified of weak keys and deprecated signature algorithms
+	WeakCryptoWebhookURL string `mapstructure:"weak_crypto_webhook_url" yaml:"weak_crypto_webhook_url"`
+
+	// Pushgateway the metrics are pushed to at the end of a --once run
+	PushgatewayURL string `mapstructure:"pushgateway_url" yaml:"pushgateway_url"`
+	PushgatewayJob string `mapstructure:"pushgateway_job" yaml:"pushgateway_job"`
+
+	// Outbound HTTP requests, such as webhooks and S3 (user agent defaults
+	// to tls-cert-monitor/<version>; the CA bundle adds to the system roots)
+	HTTPUserAgent    string `mapstructure:"http_user_agent" yaml:"http_user_agent"`
+	HTTPCABundleFile string `mapstructure:"http_ca_bundle_file" yaml:"http_ca_bundle_file"`
+
+	// Metrics collection
+	CollectorMode bool   `mapstructure:"collector_mode" yaml:"collector_mode"`
+	MetricsPrefix string `mapstructure:"metrics_prefix" yaml:"metrics_prefix"`
+
+	// Add the certificate directory as a dir label to the core certificate
+	// metrics (changes their label set, so off by default)
+	MetricsDirLabel bool `mapstructure:"metrics_dir_label" yaml:"metrics_dir_label"`
+
+	// Serve the OpenMetrics format to scrapers that ask for it in the Accept
+	// header; others get the Prometheus text format either way
+	EnableOpenMetrics bool `mapstructure:"enable_openmetrics" yaml:"enable_openmetrics"`
+
+	// What fills the common_name metric label: the subject common name or the
+	// first SAN, falling back to the other when it's missing
+	PrimaryIdentifier string `mapstructure:"primary_identifier" yaml:"primary_identifier"`
+
+	// Only export per-certificate metrics for certificates whose common name
+	// or a SAN matches one of these regular expressions (empty exports all)
+	IncludeCNPatterns []string `mapstructure:"include_cn_patterns" yaml:"include_cn_patterns"`
+
+	// Upper bounds, in days until expiry, of the expiry histogram buckets
+	ExpiryHistogramBuckets []float64 `mapstructure:"expiry_histogram_buckets" yaml:"expiry_histogram_buckets"`
+
+	// Inventory export
+	InventoryCSVPath string `mapstructure:"inventory_csv_path" yaml:"inventory_csv_path"`
+
+	// Performance: certificate files parsed, and directories listed by the
+	// directory walk, at once (0 or 1 walk workers walk serially)
+	Workers     int `mapstructure:"workers" yaml:"workers"`
+	WalkWorkers int `mapstructure:"walk_workers" yaml:"walk_workers"`
 
-	// Performance
-	Workers int `mapstructure:"workers" yaml:"workers"`
+	// Certificate directories walked at once, whatever the worker counts
+	// (0 or 1 walks them one after another)
+	MaxConcurrentDirs int `mapstructure:"max_concurrent_dirs" yaml:"max_concurrent_dirs"`
 
 	// Logging
 	LogFile  string `mapstructure:"log_file" yaml:"log_file"`
@@ -37,26 +173,137 @@ type Config struct {
 	DryRun    bool `mapstructure:"dry_run" yaml:"dry_run"`
 	HotReload bool `mapstructure:"hot_reload" yaml:"hot_reload"`
 
+	// Quiet periods before acting on file events (0 acts on every event)
+	ConfigDebounce time.Duration `mapstructure:"config_debounce" yaml:"config_debounce"`
+	WatchDebounce  time.Duration `mapstructure:"watch_debounce" yaml:"watch_debounce"`
+
+	// Interval between the checks that wait for a changed certificate file to
+	// stop changing before it is read (0 reads it right away)
+	WatchStableCheck time.Duration `mapstructure:"watch_stable_check" yaml:"watch_stable_check"`
+
 	// Cache settings
-	CacheDir     string        `mapstructure:"cache_dir" yaml:"cache_dir"`
-	CacheTTL     time.Duration `mapstructure:"cache_ttl" yaml:"cache_ttl"`
-	CacheMaxSize int64         `mapstructure:"cache_max_size" yaml:"cache_max_size"`
+	CacheDir          string        `mapstructure:"cache_dir" yaml:"cache_dir"`
+	CacheTTL          time.Duration `mapstructure:"cache_ttl" yaml:"cache_ttl"`
+	CacheMaxSize      int64         `mapstructure:"cache_max_size" yaml:"cache_max_size"`
+	CacheSaveInterval time.Duration `mapstructure:"cache_save_interval" yaml:"cache_save_interval"`
+
+	// Keep certificates expired for longer than this out of the cache
+	// (0 disables)
+	PruneExpiredAfter time.Duration `mapstructure:"prune_expired_after" yaml:"prune_expired_after"`
+
+	// Health checks
+	MinDiskSpaceBytes uint64 `mapstructure:"min_disk_space_bytes" yaml:"min_disk_space_bytes"`
+
+	// Directory patterns that matched no directories when expanded
+	unmatchedGlobs []string
+
+	// Config file used and the source of each setting, recorded by Load
+	configFile string
+	sources    map[string]string
+}
+
+// ValidityPolicy limits certificate validity periods from a date on
+type ValidityPolicy struct {
+	Name            string `mapstructure:"name" yaml:"name"`
+	From            string `mapstructure:"from" yaml:"from"`
+	MaxValidityDays int    `mapstructure:"max_validity_days" yaml:"max_validity_days"`
 }
 
+// validityPolicyDateLayout is the layout of ValidityPolicy.From
+const validityPolicyDateLayout = "2006-01-02"
+
+// metricsPrefixPattern matches prefixes that form valid Prometheus metric names
+var metricsPrefixPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// Primary identifiers of a certificate in metric labels
+const (
+	// IdentifierCommonName labels certificates by subject common name
+	IdentifierCommonName = "common_name"
+	// IdentifierFirstSAN labels certificates by their first DNS or IP SAN
+	IdentifierFirstSAN = "first_san"
+)
+
+// Duplicate policies
+const (
+	// DuplicatePolicyCount only reports duplicates in ssl_cert_duplicate_count
+	DuplicatePolicyCount = "count"
+	// DuplicatePolicyWarn also logs each duplicate at warn level
+	DuplicatePolicyWarn = "warn"
+	// DuplicatePolicyError also logs at error level and counts violations
+	DuplicatePolicyError = "error"
+)
+
 // Defaults returns a Config with default values
 func Defaults() *Config {
 	return &Config{
 		Port:                   3200,
 		BindAddress:            "0.0.0.0",
+		EnableAlertsEndpoint:   false,
+		EnablePprof:            false,
+		DiskEndpointRateLimit:  5,
 		CertificateDirectories: []string{"/etc/ssl/certs"},
 		ScanInterval:           5 * time.Minute,
+		ScanJitter:             0,
+		ScanTimeout:            0,
+		BackoffOnParseErrors:   false,
+		ParseErrorThreshold:    10,
+		ParseK8sSecrets:        false,
+		StrictGlobs:            false,
+		MaxCertFileSize:        5 * 1024 * 1024, // 5MB
+		MaxDepth:               0,
+		ExcludePaths:           nil,
+		ResolveSymlinks:        true,
+		ManifestFile:           "",
+		S3Bucket:               "",
+		S3Prefix:               "",
+		S3Region:               "us-east-1",
+		S3Endpoint:             "",
+		Endpoints:              nil,
+		EndpointTimeout:        5 * time.Second,
+		RequireSecureDirs:      false,
+		DuplicatePolicy:        DuplicatePolicyCount,
+		CABundleFile:           "",
+		AllowedSigAlgs:         nil,
+		DeprecatedCurves:       []string{"P-224"},
+		ExpiryThreshold:        30 * 24 * time.Hour,
+		IgnoreNewerThan:        0,
+		RenewalThresholds:      nil,
+		MaxValidityDays:        825,
+		ValidityPolicies:       nil,
+		CheckSCT:               false,
+		ExportKeyUsage:         false,
+		ExportSPKI:             false,
+		ExportOrganization:     false,
+		ValidateIPSANs:         false,
+		NetworkConcurrency:     4,
+		WeakCryptoWebhookURL:   "",
+		PushgatewayURL:         "",
+		PushgatewayJob:         "tls_cert_monitor",
+		HTTPUserAgent:          "",
+		HTTPCABundleFile:       "",
+		CollectorMode:          false,
+		MetricsPrefix:          "ssl",
+		MetricsDirLabel:        false,
+		EnableOpenMetrics:      true,
+		PrimaryIdentifier:      IdentifierCommonName,
+		IncludeCNPatterns:      nil,
+		ExpiryHistogramBuckets: []float64{7, 14, 30, 60, 90},
+		InventoryCSVPath:       "",
 		Workers:                4,
+		WalkWorkers:            1,
+		MaxConcurrentDirs:      1,
 		LogLevel:               "info",
 		DryRun:                 false,
 		HotReload:              true,
+		ConfigDebounce:         500 * time.Millisecond,
+		WatchDebounce:          0,
+		WatchStableCheck:       50 * time.Millisecond,
 		CacheDir:               "./cache",
 		CacheTTL:               1 * time.Hour,
 		CacheMaxSize:           100 * 1024 * 1024, // 100MB
+		CacheSaveInterval:      1 * time.Minute,
+		PruneExpiredAfter:      0,
+		MinDiskSpaceBytes:      100 * 1024 * 1024, // 100MiB
 	}
 }
 
@@ -69,25 +316,94 @@ func Load(configFile string) (*Config, error) {
 	// Set defaults
 	v.SetDefault("port", cfg.Port)
 	v.SetDefault("bind_address", cfg.BindAddress)
+	v.SetDefault("auth_token", cfg.AuthToken)
+	v.SetDefault("enable_alerts_endpoint", cfg.EnableAlertsEndpoint)
+	v.SetDefault("enable_pprof", cfg.EnablePprof)
+	v.SetDefault("disk_endpoint_rate_limit", cfg.DiskEndpointRateLimit)
 	v.SetDefault("certificate_directories", cfg.CertificateDirectories)
 	v.SetDefault("scan_interval", cfg.ScanInterval)
+	v.SetDefault("scan_jitter", cfg.ScanJitter)
+	v.SetDefault("scan_timeout", cfg.ScanTimeout)
+	v.SetDefault("backoff_on_parse_errors", cfg.BackoffOnParseErrors)
+	v.SetDefault("parse_error_threshold", cfg.ParseErrorThreshold)
+	v.SetDefault("parse_k8s_secrets", cfg.ParseK8sSecrets)
+	v.SetDefault("strict_globs", cfg.StrictGlobs)
+	v.SetDefault("max_cert_file_size", cfg.MaxCertFileSize)
+	v.SetDefault("max_depth", cfg.MaxDepth)
+	v.SetDefault("exclude_paths", cfg.ExcludePaths)
+	v.SetDefault("resolve_symlinks", cfg.ResolveSymlinks)
+	v.SetDefault("require_secure_dirs", cfg.RequireSecureDirs)
+	v.SetDefault("manifest_file", cfg.ManifestFile)
+	v.SetDefault("s3_bucket", cfg.S3Bucket)
+	v.SetDefault("s3_prefix", cfg.S3Prefix)
+	v.SetDefault("s3_region", cfg.S3Region)
+	v.SetDefault("s3_endpoint", cfg.S3Endpoint)
+	v.SetDefault("endpoints", cfg.Endpoints)
+	v.SetDefault("endpoint_timeout", cfg.EndpointTimeout)
+	v.SetDefault("duplicate_policy", cfg.DuplicatePolicy)
+	v.SetDefault("ca_bundle_file", cfg.CABundleFile)
+	v.SetDefault("allowed_sig_algs", cfg.AllowedSigAlgs)
+	v.SetDefault("deprecated_curves", cfg.DeprecatedCurves)
+	v.SetDefault("expiry_threshold", cfg.ExpiryThreshold)
+	v.SetDefault("ignore_newer_than", cfg.IgnoreNewerThan)
+	v.SetDefault("renewal_thresholds", cfg.RenewalThresholds)
+	v.SetDefault("max_validity_days", cfg.MaxValidityDays)
+	v.SetDefault("validity_policies", cfg.ValidityPolicies)
+	v.SetDefault("check_sct", cfg.CheckSCT)
+	v.SetDefault("export_key_usage", cfg.ExportKeyUsage)
+	v.SetDefault("export_spki", cfg.ExportSPKI)
+	v.SetDefault("export_organization", cfg.ExportOrganization)
+	v.SetDefault("validate_ip_sans", cfg.ValidateIPSANs)
+	v.SetDefault("network_concurrency", cfg.NetworkConcurrency)
+	v.SetDefault("weak_crypto_webhook_url", cfg.WeakCryptoWebhookURL)
+	v.SetDefault("pushgateway_url", cfg.PushgatewayURL)
+	v.SetDefault("pushgateway_job", cfg.PushgatewayJob)
+	v.SetDefault("http_user_agent", cfg.HTTPUserAgent)
+	v.SetDefault("http_ca_bundle_file", cfg.HTTPCABundleFile)
+	v.SetDefault("collector_mode", cfg.CollectorMode)
+	v.SetDefault("metrics_prefix", cfg.MetricsPrefix)
+	v.SetDefault("metrics_dir_label", cfg.MetricsDirLabel)
+	v.SetDefault("enable_openmetrics", cfg.EnableOpenMetrics)
+	v.SetDefault("primary_identifier", cfg.PrimaryIdentifier)
+	v.SetDefault("include_cn_patterns", cfg.IncludeCNPatterns)
+	v.SetDefault("expiry_histogram_buckets", cfg.ExpiryHistogramBuckets)
+	v.SetDefault("inventory_csv_path", cfg.InventoryCSVPath)
 	v.SetDefault("workers", cfg.Workers)
+	v.SetDefault("walk_workers", cfg.WalkWorkers)
+	v.SetDefault("max_concurrent_dirs", cfg.MaxConcurrentDirs)
 	v.SetDefault("log_level", cfg.LogLevel)
 	v.SetDefault("dry_run", cfg.DryRun)
 	v.SetDefault("hot_reload", cfg.HotReload)
+	v.SetDefault("config_debounce", cfg.ConfigDebounce)
+	v.SetDefault("watch_debounce", cfg.WatchDebounce)
+	v.SetDefault("watch_stable_check", cfg.WatchStableCheck)
 	v.SetDefault("cache_dir", cfg.CacheDir)
 	v.SetDefault("cache_ttl", cfg.CacheTTL)
 	v.SetDefault("cache_max_size", cfg.CacheMaxSize)
+	v.SetDefault("cache_save_interval", cfg.CacheSaveInterval)
+	v.SetDefault("prune_expired_after", cfg.PruneExpiredAfter)
+	v.SetDefault("min_disk_space_bytes", cfg.MinDiskSpaceBytes)
 
 	// Enable environment variables
-	v.SetEnvPrefix("TLS_MONITOR")
+	v.SetEnvPrefix(envPrefix)
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
 
 	// Load from config file if provided
 	if configFile != "" {
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		// Substitute environment variables before parsing
+		data, err = interpolateEnv(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+
 		v.SetConfigFile(configFile)
-		if err := v.ReadInConfig(); err != nil {
+		if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
 			return nil, fmt.Errorf("failed to read config file: %w", err)
 		}
 	}
@@ -97,12 +413,20 @@ func Load(configFile string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	// Record where each value came from
+	cfg.recordSources(v, configFile)
+
 	// Expand environment variables in paths
 	cfg.expandEnvironmentVariables()
 
-	// Validate configuration
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
+	// Expand glob patterns in certificate directories
+	if err := cfg.expandDirectoryGlobs(); err != nil {
+		return nil, err
+	}
+
+	// Validate configuration, reporting every problem at once
+	if errs := cfg.ValidateAll(); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errs)
 	}
 
 	// Normalize paths
@@ -111,86 +435,423 @@ func Load(configFile string) (*Config, error) {
 	return cfg, nil
 }
 
-// expandEnvironmentVariables expands environment variables in configuration paths
+// expandEnvironmentVariables expands environment variables in configuration
+// paths. Values from the config file were already expanded when it was read,
+// so they are left alone and a $$ escape there stays a literal $.
 func (c *Config) expandEnvironmentVariables() {
+	expand := func(key, value string) string {
+		if value == "" || c.sources[key] == SourceFile {
+			return value
+		}
+		return os.ExpandEnv(value)
+	}
+
 	// Expand certificate directories
 	for i, dir := range c.CertificateDirectories {
-		c.CertificateDirectories[i] = os.ExpandEnv(dir)
+		c.CertificateDirectories[i] = expand("certificate_directories", dir)
+	}
+
+	// Expand excluded paths
+	for i, path := range c.ExcludePaths {
+		c.ExcludePaths[i] = expand("exclude_paths", path)
 	}
 
 	// Expand other paths
-	if c.TLSCert != "" {
-		c.TLSCert = os.ExpandEnv(c.TLSCert)
+	c.TLSCert = expand("tls_cert", c.TLSCert)
+	c.TLSKey = expand("tls_key", c.TLSKey)
+	c.LogFile = expand("log_file", c.LogFile)
+	c.CacheDir = expand("cache_dir", c.CacheDir)
+	c.ManifestFile = expand("manifest_file", c.ManifestFile)
+	c.CABundleFile = expand("ca_bundle_file", c.CABundleFile)
+	c.HTTPCABundleFile = expand("http_ca_bundle_file", c.HTTPCABundleFile)
+}
+
+// expandDirectoryGlobs replaces certificate directory glob patterns with the
+// directories they match. Patterns that match nothing are recorded and dropped.
+func (c *Config) expandDirectoryGlobs() error {
+	var dirs []string
+	c.unmatchedGlobs = nil
+
+	for _, dir := range c.CertificateDirectories {
+		if !strings.ContainsAny(dir, "*?[") {
+			dirs = append(dirs, dir)
+			continue
+		}
+
+		matches, err := filepath.Glob(dir)
+		if err != nil {
+			return fmt.Errorf("invalid certificate directory pattern %s: %w", dir, err)
+		}
+
+		matched := false
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.IsDir() {
+				dirs = append(dirs, match)
+				matched = true
+			}
+		}
+
+		if !matched {
+			c.unmatchedGlobs = append(c.unmatchedGlobs, dir)
+		}
 	}
-	if c.TLSKey != "" {
-		c.TLSKey = os.ExpandEnv(c.TLSKey)
+
+	c.CertificateDirectories = dirs
+	return nil
+}
+
+// Warnings returns non-fatal configuration problems found while loading
+func (c *Config) Warnings() []string {
+	if c.StrictGlobs {
+		return nil
 	}
-	if c.LogFile != "" {
-		c.LogFile = os.ExpandEnv(c.LogFile)
+
+	var warnings []string
+	for _, pattern := range c.unmatchedGlobs {
+		warnings = append(warnings, fmt.Sprintf("certificate directory pattern matches no directories: %s", pattern))
 	}
-	if c.CacheDir != "" {
-		c.CacheDir = os.ExpandEnv(c.CacheDir)
+	return warnings
+}
+
+// ValidationError describes a single invalid configuration field
+type ValidationError struct {
+	Field   string      `json:"field"`
+	Value   interface{} `json:"value,omitempty"`
+	Message string      `json:"message"`
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors is a list of configuration problems
+type ValidationErrors []*ValidationError
+
+// Error implements the error interface
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
 	}
+	return strings.Join(messages, "; ")
 }
 
-// Validate validates the configuration
+// Validate validates the configuration, returning the first problem found
 func (c *Config) Validate() error {
+	if errs := c.ValidateAll(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// ValidateAll validates the configuration and returns every problem found
+func (c *Config) ValidateAll() ValidationErrors {
+	var errs ValidationErrors
+	add := func(field string, value interface{}, format string, args ...interface{}) {
+		errs = append(errs, &ValidationError{
+			Field:   field,
+			Value:   value,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
 	// Validate port
 	if c.Port < 1 || c.Port > 65535 {
-		return fmt.Errorf("invalid port: %d", c.Port)
+		add("port", c.Port, "invalid port: %d", c.Port)
+	}
+
+	// Validate certificate directory patterns
+	if c.StrictGlobs {
+		for _, pattern := range c.unmatchedGlobs {
+			add("certificate_directories", pattern, "certificate directory pattern matches no directories: %s", pattern)
+		}
 	}
 
 	// Validate certificate directories
 	if len(c.CertificateDirectories) == 0 {
-		return fmt.Errorf("at least one certificate directory must be specified")
+		add("certificate_directories", c.CertificateDirectories, "at least one certificate directory must be specified")
 	}
 
-	for _, dir := range c.CertificateDirectories {
+	for i, dir := range c.CertificateDirectories {
+		field := fmt.Sprintf("certificate_directories[%d]", i)
+
 		// Clean the path to prevent traversal
 		cleanPath := filepath.Clean(dir)
 		if cleanPath != dir {
-			return fmt.Errorf("invalid directory path: %s", dir)
+			add(field, dir, "invalid directory path: %s", dir)
+			continue
 		}
 
 		// Check if directory exists
 		info, err := os.Stat(dir)
 		if err != nil {
 			if os.IsNotExist(err) {
-				return fmt.Errorf("certificate directory does not exist: %s", dir)
+				add(field, dir, "certificate directory does not exist: %s", dir)
+			} else {
+				add(field, dir, "failed to access certificate directory %s: %v", dir, err)
 			}
-			return fmt.Errorf("failed to access certificate directory %s: %w", dir, err)
+			continue
 		}
 
 		if !info.IsDir() {
-			return fmt.Errorf("certificate path is not a directory: %s", dir)
+			add(field, dir, "certificate path is not a directory: %s", dir)
+			continue
+		}
+
+		// Writable directories let other users plant or swap certificates
+		if c.RequireSecureDirs && fileutil.IsGroupOrWorldWritable(info.Mode()) {
+			add(field, dir, "certificate directory is group or world writable: %s (%s)", dir, info.Mode().Perm())
+		}
+	}
+
+	// Validate certificate manifest
+	if c.ManifestFile != "" {
+		info, err := os.Stat(c.ManifestFile)
+		if err != nil {
+			add("manifest_file", c.ManifestFile, "certificate manifest not accessible: %v", err)
+		} else if info.IsDir() {
+			add("manifest_file", c.ManifestFile, "certificate manifest is a directory: %s", c.ManifestFile)
+		}
+	}
+
+	// Validate directory walk depth (0 is unlimited)
+	if c.MaxDepth < 0 {
+		add("max_depth", c.MaxDepth, "max depth must not be negative")
+	}
+
+	// Validate S3 bucket scanning
+	if c.S3Bucket != "" {
+		if strings.Contains(c.S3Bucket, "/") {
+			add("s3_bucket", c.S3Bucket, "S3 bucket name must not contain a slash; use s3_prefix for folders")
+		}
+		if c.S3Region == "" {
+			add("s3_region", c.S3Region, "S3 region is required when s3_bucket is set")
+		}
+	}
+	if c.S3Endpoint != "" {
+		if u, err := url.Parse(c.S3Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("s3_endpoint", c.S3Endpoint, "S3 endpoint must be an http or https URL")
+		}
+	}
+
+	// Validate network endpoints
+	for _, endpoint := range c.Endpoints {
+		if _, _, err := probe.ParseEndpoint(endpoint); err != nil {
+			add("endpoints", endpoint, "endpoint must be host:port, optionally followed by @server_name")
+		}
+	}
+	if len(c.Endpoints) > 0 && c.EndpointTimeout <= 0 {
+		add("endpoint_timeout", c.EndpointTimeout, "endpoint timeout must be positive")
+	}
+
+	// Validate maximum certificate file size (0 disables the limit)
+	if c.MaxCertFileSize < 0 {
+		add("max_cert_file_size", c.MaxCertFileSize, "max certificate file size must not be negative")
+	}
+
+	// Validate maximum validity period (0 disables the check)
+	if c.MaxValidityDays < 0 {
+		add("max_validity_days", c.MaxValidityDays, "max validity days must not be negative")
+	}
+
+	// Validate validity policies
+	policyNames := make(map[string]bool, len(c.ValidityPolicies))
+	for _, policy := range c.ValidityPolicies {
+		if policy.Name == "" {
+			add("validity_policies", policy, "validity policy needs a name")
+		} else if policyNames[policy.Name] {
+			add("validity_policies", policy.Name, "duplicate validity policy: %s", policy.Name)
+		}
+		policyNames[policy.Name] = true
+
+		if _, err := time.Parse(validityPolicyDateLayout, policy.From); err != nil {
+			add("validity_policies", policy.From, "validity policy %s: from must be a YYYY-MM-DD date", policy.Name)
+		}
+		if policy.MaxValidityDays <= 0 {
+			add("validity_policies", policy.MaxValidityDays, "validity policy %s: max_validity_days must be positive", policy.Name)
+		}
+	}
+
+	// Validate duplicate policy (empty means count)
+	switch strings.ToLower(c.DuplicatePolicy) {
+	case "", DuplicatePolicyCount, DuplicatePolicyWarn, DuplicatePolicyError:
+	default:
+		add("duplicate_policy", c.DuplicatePolicy, "invalid duplicate policy: %s", c.DuplicatePolicy)
+	}
+
+	// Validate CA bundle
+	if c.CABundleFile != "" {
+		if _, err := os.Stat(c.CABundleFile); err != nil {
+			add("ca_bundle_file", c.CABundleFile, "CA bundle not accessible: %v", err)
+		}
+	}
+
+	// Validate allowed signature algorithms
+	knownSigAlgs := knownSignatureAlgorithms()
+	for i, alg := range c.AllowedSigAlgs {
+		if !knownSigAlgs[strings.ToLower(alg)] {
+			add(fmt.Sprintf("allowed_sig_algs[%d]", i), alg, "unknown signature algorithm: %s", alg)
+		}
+	}
+
+	// Validate deprecated curves
+	for i, curve := range c.DeprecatedCurves {
+		if !isKnownCurve(curve) {
+			add(fmt.Sprintf("deprecated_curves[%d]", i), curve, "unknown curve: %s", curve)
 		}
 	}
 
 	// Validate TLS settings
 	if (c.TLSCert != "" && c.TLSKey == "") || (c.TLSCert == "" && c.TLSKey != "") {
-		return fmt.Errorf("both TLS certificate and key must be provided")
+		add("tls_cert", c.TLSCert, "both TLS certificate and key must be provided")
 	}
 
 	if c.TLSCert != "" {
 		if _, err := os.Stat(c.TLSCert); err != nil {
-			return fmt.Errorf("TLS certificate file not accessible: %w", err)
+			add("tls_cert", c.TLSCert, "TLS certificate file not accessible: %v", err)
 		}
 	}
 
 	if c.TLSKey != "" {
 		if _, err := os.Stat(c.TLSKey); err != nil {
-			return fmt.Errorf("TLS key file not accessible: %w", err)
+			add("tls_key", c.TLSKey, "TLS key file not accessible: %v", err)
+		}
+	}
+
+	// Validate weak crypto webhook
+	if c.WeakCryptoWebhookURL != "" {
+		if u, err := url.Parse(c.WeakCryptoWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("weak_crypto_webhook_url", redactedValue, "weak crypto webhook URL must be an http or https URL")
+		}
+	}
+
+	// Validate Pushgateway, whose URL may carry credentials
+	if c.PushgatewayURL != "" {
+		if u, err := url.Parse(c.PushgatewayURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("pushgateway_url", redactedValue, "Pushgateway URL must be an http or https URL")
+		}
+		if c.PushgatewayJob == "" {
+			add("pushgateway_job", c.PushgatewayJob, "Pushgateway job must not be empty when pushgateway_url is set")
+		}
+	}
+
+	// Validate the CA bundle for outbound requests
+	if c.HTTPCABundleFile != "" {
+		if _, err := os.Stat(c.HTTPCABundleFile); err != nil {
+			add("http_ca_bundle_file", c.HTTPCABundleFile, "HTTP CA bundle not accessible: %v", err)
+		}
+	}
+
+	// Validate metrics prefix; it is joined to metric names with an underscore
+	if c.MetricsPrefix != "" && (!metricsPrefixPattern.MatchString(c.MetricsPrefix) || strings.HasSuffix(c.MetricsPrefix, "_")) {
+		add("metrics_prefix", c.MetricsPrefix, "metrics prefix must start with a letter, contain only letters, digits and underscores, and not end with an underscore")
+	}
+
+	// Validate primary identifier (empty means common name)
+	switch strings.ToLower(c.PrimaryIdentifier) {
+	case "", IdentifierCommonName, IdentifierFirstSAN:
+	default:
+		add("primary_identifier", c.PrimaryIdentifier, "invalid primary identifier: %s", c.PrimaryIdentifier)
+	}
+
+	// Validate the patterns scoping per-certificate metrics
+	for i, pattern := range c.IncludeCNPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			add(fmt.Sprintf("include_cn_patterns[%d]", i), pattern, "invalid include CN pattern: %v", err)
 		}
 	}
 
+	// Validate expiry histogram buckets; Prometheus requires increasing bounds
+	for i, bound := range c.ExpiryHistogramBuckets {
+		if i > 0 && bound <= c.ExpiryHistogramBuckets[i-1] {
+			add(fmt.Sprintf("expiry_histogram_buckets[%d]", i), bound, "expiry histogram buckets must be in increasing order")
+		}
+	}
+
+	// Validate endpoint rate limit
+	if c.DiskEndpointRateLimit < 0 {
+		add("disk_endpoint_rate_limit", c.DiskEndpointRateLimit, "disk endpoint rate limit must not be negative")
+	}
+
 	// Validate workers
 	if c.Workers < 1 {
-		return fmt.Errorf("workers must be at least 1")
+		add("workers", c.Workers, "workers must be at least 1")
+	}
+	if c.WalkWorkers < 0 {
+		add("walk_workers", c.WalkWorkers, "walk workers must not be negative")
+	}
+	if c.MaxConcurrentDirs < 0 {
+		add("max_concurrent_dirs", c.MaxConcurrentDirs, "max concurrent dirs must not be negative")
+	}
+
+	// Validate network lookup concurrency
+	if c.ValidateIPSANs && c.NetworkConcurrency < 1 {
+		add("network_concurrency", c.NetworkConcurrency, "network concurrency must be at least 1")
 	}
 
 	// Validate scan interval
 	if c.ScanInterval < 10*time.Second {
-		return fmt.Errorf("scan interval must be at least 10 seconds")
+		add("scan_interval", c.ScanInterval.String(), "scan interval must be at least 10 seconds")
+	}
+
+	// Validate scan jitter (0 disables it)
+	if c.ScanJitter < 0 {
+		add("scan_jitter", c.ScanJitter.String(), "scan jitter must not be negative")
+	} else if c.ScanJitter > 0 && c.ScanJitter >= c.ScanInterval {
+		add("scan_jitter", c.ScanJitter.String(), "scan jitter must be shorter than the scan interval")
+	}
+
+	// Validate scan timeout (0 lets scans run as long as they take)
+	if c.ScanTimeout < 0 {
+		add("scan_timeout", c.ScanTimeout.String(), "scan timeout must not be negative")
+	}
+
+	// Validate the parse error threshold
+	if c.ParseErrorThreshold < 0 {
+		add("parse_error_threshold", c.ParseErrorThreshold, "parse error threshold must not be negative")
+	}
+
+	// Validate watcher debouncing
+	if c.ConfigDebounce < 0 {
+		add("config_debounce", c.ConfigDebounce.String(), "config debounce must not be negative")
+	}
+	if c.WatchDebounce < 0 {
+		add("watch_debounce", c.WatchDebounce.String(), "watch debounce must not be negative")
+	}
+	if c.WatchStableCheck < 0 {
+		add("watch_stable_check", c.WatchStableCheck.String(), "watch stable check must not be negative")
+	}
+
+	// Validate cache persistence
+	if c.CacheSaveInterval < 0 {
+		add("cache_save_interval", c.CacheSaveInterval.String(), "cache save interval must not be negative")
+	}
+	if c.PruneExpiredAfter < 0 {
+		add("prune_expired_after", c.PruneExpiredAfter.String(), "prune expired after must not be negative")
+	}
+
+	// Validate expiry alerting
+	if c.ExpiryThreshold < 0 {
+		add("expiry_threshold", c.ExpiryThreshold.String(), "expiry threshold must not be negative")
+	}
+	if c.IgnoreNewerThan < 0 {
+		add("ignore_newer_than", c.IgnoreNewerThan.String(), "ignore newer than must not be negative")
+	}
+	codes := make([]int, 0, len(c.RenewalThresholds))
+	for code := range c.RenewalThresholds {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		threshold := c.RenewalThresholds[code]
+		field := fmt.Sprintf("renewal_thresholds[%d]", code)
+		if code < 1 {
+			add(field, code, "renewal threshold issuer code must be positive")
+		}
+		if threshold < 0 {
+			add(field, threshold.String(), "renewal threshold must not be negative")
+		}
 	}
 
 	// Validate log level
@@ -202,10 +863,93 @@ func (c *Config) Validate() error {
 	}
 
 	if !validLevels[strings.ToLower(c.LogLevel)] {
-		return fmt.Errorf("invalid log level: %s", c.LogLevel)
+		add("log_level", c.LogLevel, "invalid log level: %s", c.LogLevel)
 	}
 
-	return nil
+	return errs
+}
+
+// signatureAlgorithmNames returns the names of the signature algorithms
+// known to crypto/x509
+func signatureAlgorithmNames() []string {
+	var names []string
+	for alg := x509.UnknownSignatureAlgorithm + 1; alg < 64; alg++ {
+		name := alg.String()
+		// Unknown values are printed as their number
+		if _, err := strconv.Atoi(name); err == nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// knownSignatureAlgorithms returns the lowercased names of the signature
+// algorithms known to crypto/x509
+func knownSignatureAlgorithms() map[string]bool {
+	known := make(map[string]bool)
+	for _, name := range signatureAlgorithmNames() {
+		known[strings.ToLower(name)] = true
+	}
+	return known
+}
+
+// curveNames lists the key curves Go parses in certificates
+var curveNames = []string{"P-224", "P-256", "P-384", "P-521", "Ed25519"}
+
+// isKnownCurve checks a curve name against curveNames, ignoring case
+func isKnownCurve(curve string) bool {
+	for _, name := range curveNames {
+		if strings.EqualFold(name, curve) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCurveDeprecated checks a key curve name against DeprecatedCurves
+func (c *Config) IsCurveDeprecated(curve string) bool {
+	for _, deprecated := range c.DeprecatedCurves {
+		if strings.EqualFold(deprecated, curve) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidityPolicyAt returns the validity policy in effect at t, the one with
+// the latest start date not after t, and whether any policy is in effect
+func (c *Config) ValidityPolicyAt(t time.Time) (ValidityPolicy, bool) {
+	var (
+		active ValidityPolicy
+		start  time.Time
+		found  bool
+	)
+	for _, policy := range c.ValidityPolicies {
+		from, err := time.Parse(validityPolicyDateLayout, policy.From)
+		if err != nil || from.After(t) {
+			continue
+		}
+		if !found || from.After(start) {
+			active, start, found = policy, from, true
+		}
+	}
+	return active, found
+}
+
+// IsSignatureAlgorithmAllowed checks a signature algorithm name against
+// AllowedSigAlgs. Every algorithm is allowed when the list is empty.
+func (c *Config) IsSignatureAlgorithmAllowed(alg string) bool {
+	if len(c.AllowedSigAlgs) == 0 {
+		return true
+	}
+
+	for _, allowed := range c.AllowedSigAlgs {
+		if strings.EqualFold(allowed, alg) {
+			return true
+		}
+	}
+	return false
 }
 
 // normalizePaths normalizes all file paths in the configuration
@@ -215,6 +959,11 @@ func (c *Config) normalizePaths() {
 		c.CertificateDirectories[i] = filepath.Clean(dir)
 	}
 
+	// Normalize excluded paths so they compare with walked paths
+	for i, path := range c.ExcludePaths {
+		c.ExcludePaths[i] = filepath.Clean(path)
+	}
+
 	// Normalize TLS paths
 	if c.TLSCert != "" {
 		c.TLSCert = filepath.Clean(c.TLSCert)
@@ -232,6 +981,19 @@ func (c *Config) normalizePaths() {
 	if c.CacheDir != "" {
 		c.CacheDir = filepath.Clean(c.CacheDir)
 	}
+
+	// Normalize manifest path
+	if c.ManifestFile != "" {
+		c.ManifestFile = filepath.Clean(c.ManifestFile)
+	}
+
+	// Normalize CA bundle paths
+	if c.CABundleFile != "" {
+		c.CABundleFile = filepath.Clean(c.CABundleFile)
+	}
+	if c.HTTPCABundleFile != "" {
+		c.HTTPCABundleFile = filepath.Clean(c.HTTPCABundleFile)
+	}
 }
 
 // IsPathAllowed checks if a path is within the configured certificate directories
@@ -0,0 +1,61 @@
+// internal/config/interpolate.go
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// interpolateEnv replaces ${VAR} and $VAR references in config file data
+// with the values of environment variables, before the file is parsed, so
+// any string setting can use them. $$ stands for a literal $. Fails listing
+// every referenced variable that isn't set; set but empty variables expand
+// to nothing.
+func interpolateEnv(data []byte) ([]byte, error) {
+	missing := make(map[string]bool)
+
+	expanded := os.Expand(string(data), func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+
+		// Shell special parameters such as $1 or $? aren't variables; keep
+		// them as written, e.g. in regular expressions
+		if !isEnvName(name) {
+			return "$" + name
+		}
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing[name] = true
+		}
+		return value
+	})
+
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("config file references unset environment variables: %s", strings.Join(names, ", "))
+	}
+
+	return []byte(expanded), nil
+}
+
+// isEnvName checks if name is a valid environment variable name
+func isEnvName(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for _, r := range name {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,148 @@
+// internal/config/schema.go
+
+package config
+
+import (
+	"reflect"
+	"time"
+)
+
+// schemaURI identifies the JSON Schema dialect of Schema
+const schemaURI = "https://json-schema.org/draft/2020-12/schema"
+
+// durationPattern matches non-negative Go durations such as 90s or 1h30m
+const durationPattern = `^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+
+// Schema returns a JSON Schema describing the configuration file. Properties
+// and defaults are derived from the Config struct tags and Defaults; the rules
+// enforced by ValidateAll that JSON Schema can express come from
+// schemaConstraints. Enumerations list the canonical spelling even where the
+// monitor itself ignores case.
+func Schema() map[string]interface{} {
+	defaults := reflect.ValueOf(Defaults()).Elem()
+	rt := defaults.Type()
+
+	properties := make(map[string]interface{}, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		key := rt.Field(i).Tag.Get("mapstructure")
+		if key == "" {
+			continue
+		}
+
+		property := schemaType(rt.Field(i).Type)
+		if value, ok := schemaDefault(defaults.Field(i)); ok {
+			property["default"] = value
+		}
+		properties[key] = property
+	}
+
+	// A constraint for a key without a field shows up as an untyped property
+	for key, constraints := range schemaConstraints() {
+		property, ok := properties[key].(map[string]interface{})
+		if !ok {
+			property = make(map[string]interface{})
+			properties[key] = property
+		}
+		for name, value := range constraints {
+			property[name] = value
+		}
+	}
+
+	return map[string]interface{}{
+		"$schema":              schemaURI,
+		"title":                "TLS Certificate Monitor configuration",
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// schemaType returns the JSON Schema type of a Config field
+func schemaType(t reflect.Type) map[string]interface{} {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return map[string]interface{}{"type": "string", "pattern": durationPattern}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaType(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaType(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{}, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			if key := t.Field(i).Tag.Get("mapstructure"); key != "" {
+				properties[key] = schemaType(t.Field(i).Type)
+			}
+		}
+		return map[string]interface{}{"type": "object", "properties": properties, "additionalProperties": false}
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
+// schemaDefault returns the default of a Config field as it is written in the
+// config file. Unset lists and maps have no default.
+func schemaDefault(value reflect.Value) (interface{}, bool) {
+	switch v := value.Interface().(type) {
+	case time.Duration:
+		return v.String(), true
+	case []string:
+		return v, v != nil
+	case []ValidityPolicy:
+		return v, v != nil
+	case map[int]time.Duration:
+		return v, v != nil
+	default:
+		return v, true
+	}
+}
+
+// schemaConstraints returns the ValidateAll rules expressible in JSON Schema,
+// keyed by configuration key. Keep in step with ValidateAll; file existence
+// checks and rules depending on other settings are left out.
+func schemaConstraints() map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		"port":                     {"minimum": 1, "maximum": 65535},
+		"disk_endpoint_rate_limit": {"minimum": 0},
+		"certificate_directories":  {"minItems": 1},
+		"scan_interval":            {"description": "At least 10s"},
+		"scan_jitter":              {"description": "Shorter than scan_interval; 0 disables"},
+		"scan_timeout":             {"description": "0 disables"},
+		"parse_error_threshold":    {"minimum": 0},
+		"s3_bucket":                {"pattern": `^[^/]*$`},
+		"s3_endpoint":              {"pattern": `^(https?://.+)?$`},
+		"endpoint_timeout":         {"description": "Positive when endpoints are set"},
+		"prune_expired_after":      {"description": "0 disables"},
+		"max_cert_file_size":       {"minimum": 0, "description": "0 disables the limit"},
+		"renewal_thresholds":       {"propertyNames": map[string]interface{}{"pattern": `^[1-9][0-9]*$`}},
+		"max_depth":                {"minimum": 0, "description": "0 is unlimited"},
+		"max_validity_days":        {"minimum": 0, "description": "0 disables the check"},
+		"expiry_histogram_buckets": {"description": "Increasing upper bounds in days"},
+		"primary_identifier":       {"enum": []string{"", IdentifierCommonName, IdentifierFirstSAN}},
+		"duplicate_policy": {
+			"enum": []string{"", DuplicatePolicyCount, DuplicatePolicyWarn, DuplicatePolicyError},
+		},
+		"allowed_sig_algs":        {"items": map[string]interface{}{"type": "string", "enum": signatureAlgorithmNames()}},
+		"deprecated_curves":       {"items": map[string]interface{}{"type": "string", "enum": curveNames}},
+		"weak_crypto_webhook_url": {"pattern": `^(https?://.+)?$`},
+		"pushgateway_url":         {"pattern": `^(https?://.+)?$`},
+		"pushgateway_job":         {"description": "Required with pushgateway_url"},
+		"metrics_prefix":          {"pattern": `^([a-zA-Z]([a-zA-Z0-9_]*[a-zA-Z0-9])?)?$`},
+		"workers":                 {"minimum": 1},
+		"walk_workers":            {"minimum": 0, "description": "0 or 1 walks directories serially"},
+		"max_concurrent_dirs":     {"minimum": 0, "description": "0 or 1 walks certificate_directories one at a time"},
+		"network_concurrency":     {"description": "At least 1 when validate_ip_sans is enabled"},
+		"log_level":               {"enum": []string{"debug", "info", "warn", "error"}},
+		"tls_cert":                {"description": "Requires tls_key"},
+		"tls_key":                 {"description": "Requires tls_cert"},
+	}
+}
@@ -0,0 +1,119 @@
+// internal/config/sources.go
+
+package config
+
+import (
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Configuration value sources
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+)
+
+// envPrefix is the prefix of environment variable overrides
+const envPrefix = "TLS_MONITOR"
+
+// redactedValue replaces secret values in the effective configuration
+const redactedValue = "REDACTED"
+
+// secretKeys lists the settings never exposed by Effective
+var secretKeys = map[string]bool{
+	"auth_token":              true,
+	"tls_key":                 true,
+	"weak_crypto_webhook_url": true,
+	"pushgateway_url":         true,
+}
+
+// settingKeys returns the configuration key of every setting, in field order
+func settingKeys() []string {
+	t := reflect.TypeOf(Config{})
+
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if key := t.Field(i).Tag.Get("mapstructure"); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// recordSources records where each setting's value came from. Environment
+// variables take precedence over the config file, which overrides defaults.
+func (c *Config) recordSources(v *viper.Viper, configFile string) {
+	c.configFile = configFile
+	c.sources = make(map[string]string)
+
+	for _, key := range settingKeys() {
+		switch {
+		case os.Getenv(envPrefix+"_"+strings.ToUpper(key)) != "":
+			c.sources[key] = SourceEnv
+		case v.InConfig(key):
+			c.sources[key] = SourceFile
+		default:
+			c.sources[key] = SourceDefault
+		}
+	}
+}
+
+// ConfigFile returns the path of the config file the configuration was loaded
+// from, or an empty string if none was used
+func (c *Config) ConfigFile() string {
+	return c.configFile
+}
+
+// Sources returns the source (default, file or env) of each setting, keyed by
+// configuration key. Returns an empty map for configurations not built by Load.
+func (c *Config) Sources() map[string]string {
+	sources := make(map[string]string, len(c.sources))
+	for key, source := range c.sources {
+		sources[key] = source
+	}
+	return sources
+}
+
+// Effective returns the configuration keyed by configuration key, with
+// durations as strings, including those of renewal_thresholds keyed by issuer
+// code, and secrets such as auth_token and tls_key redacted
+func (c *Config) Effective() map[string]interface{} {
+	rv := reflect.ValueOf(c).Elem()
+	rt := rv.Type()
+
+	effective := make(map[string]interface{}, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		key := rt.Field(i).Tag.Get("mapstructure")
+		if key == "" {
+			continue
+		}
+
+		value := rv.Field(i).Interface()
+		switch v := value.(type) {
+		case time.Duration:
+			value = v.String()
+		case map[int]time.Duration:
+			// renewal_thresholds, keyed by issuer code
+			if v != nil {
+				durations := make(map[string]string, len(v))
+				for code, d := range v {
+					durations[strconv.Itoa(code)] = d.String()
+				}
+				value = durations
+			}
+		case string:
+			if secretKeys[key] && v != "" {
+				value = redactedValue
+			}
+		}
+		effective[key] = value
+	}
+
+	return effective
+}
@@ -1,7 +1,12 @@
 package config
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"os"
 	"path/filepath"
 	"sync"
 	"time"
@@ -10,15 +15,46 @@ import (
 	"go.uber.org/zap"
 )
 
-// ReloadCallback is called when configuration changes
-type ReloadCallback func(*Config)
+// ReloadCallback is called when configuration changes. An error rejects
+// the new configuration: the current one stays in effect and the same file
+// contents are tried again on the next change.
+type ReloadCallback func(*Config) error
+
+// ReloadErrorHandler is called when a changed configuration fails to load
+// or validate
+type ReloadErrorHandler func(error)
+
+// ReloadResultHandler is called with the outcome of every reload attempt
+type ReloadResultHandler func(result string)
+
+// Reload outcomes passed to a ReloadResultHandler
+const (
+	ReloadSuccess   = "success"
+	ReloadError     = "error"
+	ReloadUnchanged = "unchanged"
+	ReloadMissing   = "missing"
+	ReloadEmpty     = "empty"
+)
+
+// How long to wait for a replaced config file to reappear before giving up
+// on watching it directly
+const (
+	rewatchAttempts = 10
+	rewatchInterval = 100 * time.Millisecond
+)
 
 // Watcher watches for configuration changes
 type Watcher struct {
 	config     *Config
 	configFile string
 	logger     *zap.Logger
+	onError    ReloadErrorHandler
+	onResult   ReloadResultHandler
 	mu         sync.RWMutex
+
+	// digest of the file contents behind the current configuration, so a
+	// rewrite with identical contents doesn't trigger a reload
+	digest [sha256.Size]byte
 }
 
 // NewWatcher creates a new configuration watcher
@@ -49,11 +85,36 @@ func (w *Watcher) Watch(ctx context.Context, callback ReloadCallback) error {
 		return err
 	}
 
+	// Also watch the file itself: a symlinked config (e.g. a Kubernetes
+	// ConfigMap) is replaced without any event naming it in its directory
+	if err := watcher.Add(w.configFile); err != nil {
+		w.logger.Warn("Failed to watch configuration file", zap.String("file", w.configFile), zap.Error(err))
+	}
+
+	// Signalled once the file watch is re-established after a replace
+	rewatched := make(chan struct{}, 1)
+
+	if data, err := os.ReadFile(w.configFile); err == nil {
+		w.mu.Lock()
+		w.digest = sha256.Sum256(data)
+		w.mu.Unlock()
+	}
+
 	w.logger.Info("Watching for configuration changes", zap.String("file", w.configFile))
 
 	// Debounce timer to avoid multiple reloads
 	var debounceTimer *time.Timer
-	debounce := 500 * time.Millisecond
+	scheduleReload := func() {
+		// Cancel previous timer if exists
+		if debounceTimer != nil {
+			debounceTimer.Stop()
+		}
+
+		// Set new timer, honoring a debounce changed by the last reload
+		debounceTimer = time.AfterFunc(w.GetConfig().ConfigDebounce, func() {
+			w.handleConfigChange(callback)
+		})
+	}
 
 	for {
 		select {
@@ -73,19 +134,23 @@ func (w *Watcher) Watch(ctx context.Context, callback ReloadCallback) error {
 				continue
 			}
 
-			// Handle write and create events
-			if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
-				// Cancel previous timer if exists
-				if debounceTimer != nil {
-					debounceTimer.Stop()
-				}
-
-				// Set new timer
-				debounceTimer = time.AfterFunc(debounce, func() {
-					w.handleConfigChange(callback)
-				})
+			// Handle write and create events, and removes and renames so a
+			// deleted file is reported and a replaced one is picked up
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+				scheduleReload()
 			}
 
+			// An atomic replace (write a temp file, rename it over the
+			// config) drops the watch on the old inode; watch the new one
+			if event.Op&(fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+				go w.rewatch(ctx, watcher, rewatched)
+			}
+
+		case <-rewatched:
+			// Events on the new file may have been missed while it wasn't
+			// watched
+			scheduleReload()
+
 		case err, ok := <-watcher.Errors:
 			if !ok {
 				return nil
@@ -95,28 +160,132 @@ func (w *Watcher) Watch(ctx context.Context, callback ReloadCallback) error {
 	}
 }
 
+// rewatch re-adds the watch on the config file after it was created,
+// removed or renamed, retrying briefly while a replacement hasn't appeared
+// yet
+func (w *Watcher) rewatch(ctx context.Context, watcher *fsnotify.Watcher, done chan<- struct{}) {
+	// The watch may survive a rename on some platforms
+	_ = watcher.Remove(w.configFile)
+
+	var err error
+	for attempt := 0; attempt < rewatchAttempts; attempt++ {
+		if err = watcher.Add(w.configFile); err == nil {
+			w.logger.Debug("Re-established configuration file watch", zap.String("file", w.configFile))
+			select {
+			case done <- struct{}{}:
+			default:
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(rewatchInterval):
+		}
+	}
+
+	// The directory watch still picks up the file if it is recreated
+	w.logger.Warn("Configuration file did not reappear, watching its directory only",
+		zap.String("file", w.configFile), zap.Error(err))
+}
+
 // handleConfigChange handles configuration file changes
 func (w *Watcher) handleConfigChange(callback ReloadCallback) {
+	// A file caught mid-write or deleted keeps the current configuration
+	// without counting as a failed reload; its replacement triggers another
+	// event
+	data, err := os.ReadFile(w.configFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		w.logger.Warn("Configuration file is missing, keeping the current one", zap.String("file", w.configFile))
+		w.reportResult(ReloadMissing)
+		return
+	case err == nil && len(bytes.TrimSpace(data)) == 0:
+		w.logger.Warn("Configuration file is empty, keeping the current one", zap.String("file", w.configFile))
+		w.reportResult(ReloadEmpty)
+		return
+	}
+
+	digest := sha256.Sum256(data)
+	w.mu.RLock()
+	unchanged := err == nil && digest == w.digest
+	w.mu.RUnlock()
+	if unchanged {
+		w.logger.Debug("Configuration file contents unchanged, skipping reload")
+		w.reportResult(ReloadUnchanged)
+		return
+	}
+
 	w.logger.Info("Configuration file changed, reloading...")
 
-	// Load new configuration
+	// Load and validate the new configuration; on failure the current one
+	// stays in effect
 	newConfig, err := Load(w.configFile)
 	if err != nil {
-		w.logger.Error("Failed to reload configuration", zap.Error(err))
+		w.logger.Error("Failed to reload configuration, keeping the current one", zap.Error(err))
+		w.reportError(err)
 		return
 	}
 
-	// Update configuration
+	for _, warning := range newConfig.Warnings() {
+		w.logger.Warn("Configuration warning", zap.String("warning", warning))
+	}
+
+	// Apply the new configuration, committing it only once it was accepted
+	if callback != nil {
+		if err := callback(newConfig); err != nil {
+			w.logger.Error("Failed to apply reloaded configuration, keeping the current one", zap.Error(err))
+			w.reportError(err)
+			return
+		}
+	}
+
 	w.mu.Lock()
 	w.config = newConfig
+	w.digest = digest
 	w.mu.Unlock()
 
-	// Call the callback with new configuration
-	if callback != nil {
-		callback(newConfig)
+	w.reportResult(ReloadSuccess)
+	w.logger.Info("Configuration reloaded successfully")
+}
+
+// reportError passes a rejected reload to the error handler, if any, and
+// reports it as failed
+func (w *Watcher) reportError(err error) {
+	w.mu.RLock()
+	onError := w.onError
+	w.mu.RUnlock()
+	if onError != nil {
+		onError(err)
 	}
+	w.reportResult(ReloadError)
+}
 
-	w.logger.Info("Configuration reloaded successfully")
+// reportResult passes a reload outcome to the result handler, if any
+func (w *Watcher) reportResult(result string) {
+	w.mu.RLock()
+	onResult := w.onResult
+	w.mu.RUnlock()
+	if onResult != nil {
+		onResult(result)
+	}
+}
+
+// SetReloadErrorHandler sets the handler called when a changed configuration
+// is rejected
+func (w *Watcher) SetReloadErrorHandler(handler ReloadErrorHandler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onError = handler
+}
+
+// SetReloadResultHandler sets the handler called with the outcome of every
+// reload attempt
+func (w *Watcher) SetReloadResultHandler(handler ReloadResultHandler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onResult = handler
 }
 
 // GetConfig returns the current configuration
@@ -0,0 +1,15 @@
+//go:build !windows
+
+// internal/fileutil/perm_unix.go
+
+package fileutil
+
+import (
+	"io/fs"
+)
+
+// IsGroupOrWorldWritable reports whether the permission bits let users other
+// than the owner write to the file or directory
+func IsGroupOrWorldWritable(mode fs.FileMode) bool {
+	return mode.Perm()&0o022 != 0
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+// internal/fileutil/perm_windows.go
+
+package fileutil
+
+import (
+	"io/fs"
+)
+
+// IsGroupOrWorldWritable always reports false on Windows, where access is
+// controlled by ACLs and Go's permission bits don't describe other users
+func IsGroupOrWorldWritable(mode fs.FileMode) bool {
+	return false
+}
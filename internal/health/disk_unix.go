@@ -0,0 +1,22 @@
+//go:build !windows
+
+// internal/health/disk_unix.go
+
+package health
+
+import (
+	"syscall"
+)
+
+// getDiskUsage gets disk usage for a path
+func (c *Checker) getDiskUsage(path string) DiskUsage {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return DiskUsage{}
+	}
+
+	total := stat.Blocks * uint64(stat.Bsize)
+	free := stat.Bavail * uint64(stat.Bsize)
+
+	return newDiskUsage(total, free)
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+// internal/health/disk_windows.go
+
+package health
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// getDiskUsage gets disk usage for a path
+func (c *Checker) getDiskUsage(path string) DiskUsage {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return DiskUsage{}
+	}
+
+	// Free bytes available to the caller honor per-user quotas, like Bavail on Unix
+	var freeAvailable, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(pathPtr, &freeAvailable, &total, &totalFree); err != nil {
+		return DiskUsage{}
+	}
+
+	return newDiskUsage(total, freeAvailable)
+}
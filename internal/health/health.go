@@ -6,7 +6,6 @@ import (
 	"path/filepath"
 	"runtime"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/brandonhon/tls-cert-monitor/internal/cache"
@@ -50,10 +49,15 @@ type Checker struct {
 
 // New creates a new health checker
 func New(cfg *config.Config, metrics *metrics.Collector) *Checker {
-	return &Checker{
+	c := &Checker{
 		config:  cfg,
 		metrics: metrics,
 	}
+
+	// Expose available disk space on every scrape
+	metrics.SetDiskSpaceSource(c.availableDiskSpace)
+
+	return c
 }
 
 // SetCache sets the cache for health checks
@@ -337,7 +341,8 @@ func (c *Checker) checkDiskSpace() []Check {
 	for _, dir := range c.config.CertificateDirectories {
 		usage := c.getDiskUsage(dir)
 		status := StatusHealthy
-		if usage.UsedPercent > 90 {
+		lowSpace := usage.Total > 0 && usage.Free < c.config.MinDiskSpaceBytes
+		if usage.UsedPercent > 90 || lowSpace {
 			status = StatusUnhealthy
 		} else if usage.UsedPercent > 80 {
 			status = StatusDegraded
@@ -359,6 +364,21 @@ func (c *Checker) checkDiskSpace() []Check {
 	return checks
 }
 
+// availableDiskSpace returns the available bytes for each certificate directory
+func (c *Checker) availableDiskSpace() map[string]uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	available := make(map[string]uint64, len(c.config.CertificateDirectories))
+	for _, dir := range c.config.CertificateDirectories {
+		// Skip directories whose filesystem couldn't be read
+		if usage := c.getDiskUsage(dir); usage.Total > 0 {
+			available[dir] = usage.Free
+		}
+	}
+	return available
+}
+
 // checkSystem performs system-level health checks
 func (c *Checker) checkSystem() []Check {
 	checks := []Check{}
@@ -402,23 +422,18 @@ type DiskUsage struct {
 	UsedPercent float64
 }
 
-// getDiskUsage gets disk usage for a path
-func (c *Checker) getDiskUsage(path string) DiskUsage {
-	var stat syscall.Statfs_t
-	if err := syscall.Statfs(path, &stat); err != nil {
+// newDiskUsage builds disk usage statistics from total and available bytes
+func newDiskUsage(total, free uint64) DiskUsage {
+	if total == 0 {
 		return DiskUsage{}
 	}
 
-	total := stat.Blocks * uint64(stat.Bsize)
-	free := stat.Bavail * uint64(stat.Bsize)
 	used := total - free
-	usedPercent := float64(used) / float64(total) * 100
-
 	return DiskUsage{
 		Total:       total,
 		Free:        free,
 		Used:        used,
-		UsedPercent: usedPercent,
+		UsedPercent: float64(used) / float64(total) * 100,
 	}
 }
 
@@ -0,0 +1,82 @@
+// internal/httpclient/httpclient.go
+
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+)
+
+// defaultUserAgent is sent when Options.UserAgent is empty
+var defaultUserAgent atomic.Value
+
+func init() {
+	defaultUserAgent.Store("tls-cert-monitor/dev")
+}
+
+// SetVersion sets the version in the default user agent
+func SetVersion(version string) {
+	defaultUserAgent.Store("tls-cert-monitor/" + version)
+}
+
+// Options configure the transport shared by outbound HTTP requests
+type Options struct {
+	// User-Agent header sent with each request (tls-cert-monitor/<version>
+	// when empty)
+	UserAgent string
+
+	// PEM bundle of CAs trusted in addition to the system roots, for
+	// endpoints behind an internal CA
+	CABundleFile string
+}
+
+// NewTransport returns a transport for outbound requests. Proxies come from
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+func NewTransport(opts Options) (http.RoundTripper, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.CABundleFile != "" {
+		data, err := os.ReadFile(opts.CABundleFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read HTTP CA bundle: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in HTTP CA bundle: %s", opts.CABundleFile)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	userAgent := opts.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent.Load().(string)
+	}
+
+	return &userAgentTransport{userAgent: userAgent, next: transport}, nil
+}
+
+// userAgentTransport sets the User-Agent header on requests without one
+type userAgentTransport struct {
+	userAgent string
+	next      http.RoundTripper
+}
+
+// RoundTrip sends the request with the user agent set
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		// A RoundTripper must not modify the caller's request
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.next.RoundTrip(req)
+}
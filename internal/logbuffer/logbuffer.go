@@ -0,0 +1,129 @@
+// internal/logbuffer/logbuffer.go
+
+package logbuffer
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultSize is the number of entries a buffer keeps by default
+const DefaultSize = 500
+
+// Entry is a log entry kept by a Buffer
+type Entry struct {
+	Time    time.Time              `json:"timestamp"`
+	Level   string                 `json:"level"`
+	Caller  string                 `json:"caller,omitempty"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Buffer keeps the most recent log entries in memory, dropping the oldest
+// once it is full
+type Buffer struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// New creates a buffer keeping the last size entries
+func New(size int) *Buffer {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Buffer{entries: make([]Entry, size)}
+}
+
+// Entries returns the buffered entries, oldest first
+func (b *Buffer) Entries() []Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.full {
+		return append([]Entry(nil), b.entries[:b.next]...)
+	}
+	entries := make([]Entry, 0, len(b.entries))
+	entries = append(entries, b.entries[b.next:]...)
+	return append(entries, b.entries[:b.next]...)
+}
+
+// add stores an entry, overwriting the oldest when the buffer is full
+func (b *Buffer) add(entry Entry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries[b.next] = entry
+	b.next++
+	if b.next == len(b.entries) {
+		b.next = 0
+		b.full = true
+	}
+}
+
+// Core returns a zap core recording the entries enabled by level into the
+// buffer, for teeing with the core that writes the log
+func (b *Buffer) Core(level zapcore.LevelEnabler) zapcore.Core {
+	return &core{LevelEnabler: level, buffer: b}
+}
+
+// core is a zapcore.Core writing to a Buffer
+type core struct {
+	zapcore.LevelEnabler
+	buffer *Buffer
+	fields []zapcore.Field
+}
+
+// With implements zapcore.Core
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	return &core{
+		LevelEnabler: c.LevelEnabler,
+		buffer:       c.buffer,
+		fields:       append(append([]zapcore.Field(nil), c.fields...), fields...),
+	}
+}
+
+// Check implements zapcore.Core
+func (c *core) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write implements zapcore.Core
+func (c *core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	var encoded map[string]interface{}
+	if len(c.fields)+len(fields) > 0 {
+		encoder := zapcore.NewMapObjectEncoder()
+		for _, field := range c.fields {
+			field.AddTo(encoder)
+		}
+		for _, field := range fields {
+			field.AddTo(encoder)
+		}
+		encoded = encoder.Fields
+	}
+
+	var caller string
+	if entry.Caller.Defined {
+		caller = entry.Caller.TrimmedPath()
+	}
+
+	c.buffer.add(Entry{
+		Time:    entry.Time,
+		Level:   entry.Level.CapitalString(),
+		Caller:  caller,
+		Message: entry.Message,
+		Fields:  encoded,
+	})
+	return nil
+}
+
+// Sync implements zapcore.Core
+func (c *core) Sync() error {
+	return nil
+}
@@ -0,0 +1,451 @@
+// internal/metrics/certificates.go
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CertificateSnapshot holds the per-certificate values exposed at scrape time
+type CertificateSnapshot struct {
+	Path               string
+	Subject            string
+	Issuer             string
+	SerialNumber       string
+	SignatureAlgorithm string
+	Source             string
+	CommonName         string
+	FileName           string
+	Dir                string
+	Fingerprint        string
+	BaseDomain         string
+	NotAfter           time.Time
+	SANCount           int
+	IssuerCode         int
+	ExpiringSoon       bool
+	RenewalDue         bool
+	KeyWeaknesses      []string
+	DeprecatedCurve    string
+	ExcessiveValidity  int
+	PolicyViolation    string
+	ValidityDays       int
+	IPSANMismatches    []string
+	SCTChecked         bool
+	HasSCT             bool
+	ChainDepth         int
+	KeyUsages          []string
+	ExtKeyUsages       []string
+	SPKISHA256         string
+	Organization       string
+	Format             string
+}
+
+// CertificateSource returns the certificates to expose on a scrape
+type CertificateSource func() []CertificateSnapshot
+
+// certVecs groups the per-certificate metric vectors
+type certVecs struct {
+	expiration        *prometheus.GaugeVec
+	sanCount          *prometheus.GaugeVec
+	info              *prometheus.GaugeVec
+	duplicateCount    *prometheus.GaugeVec
+	issuerCode        *prometheus.GaugeVec
+	expiringSoon      *prometheus.GaugeVec
+	renewalDue        *prometheus.GaugeVec
+	byDomain          *prometheus.GaugeVec
+	keyWeakness       *prometheus.GaugeVec
+	deprecatedCurve   *prometheus.GaugeVec
+	excessiveValidity *prometheus.GaugeVec
+	policyViolation   *prometheus.GaugeVec
+	ipSANMismatch     *prometheus.GaugeVec
+	hasSCT            *prometheus.GaugeVec
+	chainDepth        *prometheus.GaugeVec
+	keyUsage          *prometheus.GaugeVec
+	extKeyUsage       *prometheus.GaugeVec
+	spki              *prometheus.GaugeVec
+	organization      *prometheus.GaugeVec
+	format            *prometheus.GaugeVec
+	expiryDays        *prometheus.HistogramVec
+
+	// Whether the core vectors carry a trailing dir label
+	dirLabel bool
+
+	// Bucket upper bounds of the expiry histogram, in days
+	expiryBuckets []float64
+}
+
+// dirLabelName is the label holding a certificate's configured directory
+const dirLabelName = "dir"
+
+// DefaultExpiryBuckets are the expiry histogram bucket bounds, in days, used
+// when none are configured
+var DefaultExpiryBuckets = []float64{7, 14, 30, 60, 90}
+
+// newCertVecs creates the per-certificate metric vectors
+func newCertVecs(prefix string, dirLabel bool, expiryBuckets []float64) *certVecs {
+	if len(expiryBuckets) == 0 {
+		expiryBuckets = DefaultExpiryBuckets
+	}
+
+	// Core metrics optionally carry the certificate directory
+	coreLabels := func(names ...string) []string {
+		if dirLabel {
+			return append(names, dirLabelName)
+		}
+		return names
+	}
+
+	return &certVecs{
+		dirLabel:      dirLabel,
+		expiryBuckets: expiryBuckets,
+		expiration: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_expiration_timestamp",
+				Help:      "Certificate expiration time (Unix timestamp)",
+			},
+			coreLabels("path", "subject", "issuer"),
+		),
+		sanCount: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_san_count",
+				Help:      "Number of Subject Alternative Names",
+			},
+			coreLabels("path"),
+		),
+		info: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_info",
+				Help:      "Certificate information with labels",
+			},
+			coreLabels("path", "subject", "issuer", "serial", "signature_algorithm", "source"),
+		),
+		duplicateCount: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_duplicate_count",
+				Help:      "Number of duplicate certificates",
+			},
+			[]string{"fingerprint"},
+		),
+		issuerCode: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_issuer_code",
+				Help:      "Numeric issuer classification",
+			},
+			coreLabels("issuer", "common_name", "file_name"),
+		),
+		expiringSoon: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_expiring_soon",
+				Help:      "Whether the certificate expires within the configured threshold (1 = yes)",
+			},
+			coreLabels("path"),
+		),
+		renewalDue: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_renewal_due",
+				Help:      "Whether the certificate is within the renewal window of its issuer (1 = yes)",
+			},
+			[]string{"common_name", "file_name"},
+		),
+		byDomain: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_by_domain",
+				Help:      "Certificate expiration time (Unix timestamp) by base domain of the common name",
+			},
+			[]string{"base_domain", "common_name", "file_name"},
+		),
+		keyWeakness: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_key_weakness_total",
+				Help:      "Certificate public key weaknesses by reason",
+			},
+			[]string{"common_name", "file_name", "reason"},
+		),
+		deprecatedCurve: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_deprecated_curve_total",
+				Help:      "Certificate public keys on a curve listed in deprecated_curves",
+			},
+			[]string{"common_name", "file_name", "curve"},
+		),
+		excessiveValidity: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_excessive_validity",
+				Help:      "Validity period in days of certificates valid for longer than max_validity_days",
+			},
+			[]string{"common_name", "file_name"},
+		),
+		policyViolation: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_policy_violation",
+				Help:      "Validity period in days of certificates valid for longer than the validity policy in effect allows",
+			},
+			[]string{"common_name", "file_name", "policy"},
+		),
+		ipSANMismatch: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_ip_san_mismatch",
+				Help:      "IP SANs whose reverse DNS lookup matches none of the certificate's DNS SANs",
+			},
+			[]string{"common_name", "file_name", "ip"},
+		),
+		hasSCT: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_has_sct",
+				Help:      "Whether the certificate embeds Certificate Transparency SCTs (1 = yes)",
+			},
+			[]string{"common_name", "file_name"},
+		),
+		chainDepth: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_chain_depth",
+				Help:      "Number of certificates in the file, including the leaf",
+			},
+			coreLabels("common_name", "file_name"),
+		),
+		keyUsage: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_key_usage",
+				Help:      "Key usages set on the certificate (1 per usage)",
+			},
+			[]string{"common_name", "file_name", "usage"},
+		),
+		extKeyUsage: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_ext_key_usage",
+				Help:      "Extended key usages set on the certificate (1 per usage)",
+			},
+			[]string{"common_name", "file_name", "eku"},
+		),
+		spki: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_spki_info",
+				Help:      "Base64 SHA-256 of the certificate's subject public key info, for pinning",
+			},
+			[]string{"common_name", "file_name", "spki_sha256"},
+		),
+		organization: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_organization_info",
+				Help:      "Subject organization (O) of the certificate, multiple values joined",
+			},
+			[]string{"common_name", "file_name", "organization"},
+		),
+		format: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_format",
+				Help:      "Certificate file encoding (pem, der, pkcs7 or pkcs12)",
+			},
+			[]string{"common_name", "file_name", "format"},
+		),
+		expiryDays: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: prefix,
+				Name:      "cert_expiry_days",
+				Help:      "Distribution of days until expiry across the certificates of the last scan",
+				Buckets:   expiryBuckets,
+			},
+			[]string{},
+		),
+	}
+}
+
+// labelValues returns the label values of a core metric, appending the
+// directory when the dir label is enabled
+func (v *certVecs) labelValues(dir string, values ...string) []string {
+	if v.dirLabel {
+		return append(values, dir)
+	}
+	return values
+}
+
+// collectors returns the vectors as Prometheus collectors
+func (v *certVecs) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		v.expiration,
+		v.sanCount,
+		v.info,
+		v.duplicateCount,
+		v.issuerCode,
+		v.expiringSoon,
+		v.renewalDue,
+		v.byDomain,
+		v.keyWeakness,
+		v.deprecatedCurve,
+		v.excessiveValidity,
+		v.policyViolation,
+		v.ipSANMismatch,
+		v.hasSCT,
+		v.chainDepth,
+		v.keyUsage,
+		v.extKeyUsage,
+		v.spki,
+		v.organization,
+		v.format,
+		v.expiryDays,
+	}
+}
+
+// reset removes all series from the vectors
+func (v *certVecs) reset() {
+	v.expiration.Reset()
+	v.sanCount.Reset()
+	v.info.Reset()
+	v.duplicateCount.Reset()
+	v.issuerCode.Reset()
+	v.expiringSoon.Reset()
+	v.renewalDue.Reset()
+	v.byDomain.Reset()
+	v.keyWeakness.Reset()
+	v.deprecatedCurve.Reset()
+	v.excessiveValidity.Reset()
+	v.policyViolation.Reset()
+	v.ipSANMismatch.Reset()
+	v.hasSCT.Reset()
+	v.chainDepth.Reset()
+	v.keyUsage.Reset()
+	v.extKeyUsage.Reset()
+	v.spki.Reset()
+	v.organization.Reset()
+	v.format.Reset()
+	v.expiryDays.Reset()
+}
+
+// populate fills the vectors from a set of certificate snapshots
+func (v *certVecs) populate(snapshots []CertificateSnapshot) {
+	duplicates := make(map[string]int)
+
+	for _, cert := range snapshots {
+		v.expiration.WithLabelValues(v.labelValues(cert.Dir, cert.Path, cert.Subject, cert.Issuer)...).Set(float64(cert.NotAfter.Unix()))
+		v.sanCount.WithLabelValues(v.labelValues(cert.Dir, cert.Path)...).Set(float64(cert.SANCount))
+		v.info.WithLabelValues(v.labelValues(cert.Dir, cert.Path, cert.Subject, cert.Issuer, cert.SerialNumber, cert.SignatureAlgorithm, cert.Source)...).Set(1)
+		v.issuerCode.WithLabelValues(v.labelValues(cert.Dir, cert.Issuer, cert.CommonName, cert.FileName)...).Set(float64(cert.IssuerCode))
+
+		expiringSoon := 0.0
+		if cert.ExpiringSoon {
+			expiringSoon = 1
+		}
+		v.expiringSoon.WithLabelValues(v.labelValues(cert.Dir, cert.Path)...).Set(expiringSoon)
+
+		renewalDue := 0.0
+		if cert.RenewalDue {
+			renewalDue = 1
+		}
+		v.renewalDue.WithLabelValues(cert.CommonName, cert.FileName).Set(renewalDue)
+		if cert.BaseDomain != "" {
+			v.byDomain.WithLabelValues(cert.BaseDomain, cert.CommonName, cert.FileName).Set(float64(cert.NotAfter.Unix()))
+		}
+
+		for _, reason := range cert.KeyWeaknesses {
+			v.keyWeakness.WithLabelValues(cert.CommonName, cert.FileName, reason).Set(1)
+		}
+		if cert.DeprecatedCurve != "" {
+			v.deprecatedCurve.WithLabelValues(cert.CommonName, cert.FileName, cert.DeprecatedCurve).Set(1)
+		}
+		if cert.ExcessiveValidity > 0 {
+			v.excessiveValidity.WithLabelValues(cert.CommonName, cert.FileName).Set(float64(cert.ExcessiveValidity))
+		}
+		if cert.PolicyViolation != "" {
+			v.policyViolation.WithLabelValues(cert.CommonName, cert.FileName, cert.PolicyViolation).Set(float64(cert.ValidityDays))
+		}
+		for _, ip := range cert.IPSANMismatches {
+			v.ipSANMismatch.WithLabelValues(cert.CommonName, cert.FileName, ip).Set(1)
+		}
+
+		if cert.SCTChecked {
+			hasSCT := 0.0
+			if cert.HasSCT {
+				hasSCT = 1
+			}
+			v.hasSCT.WithLabelValues(cert.CommonName, cert.FileName).Set(hasSCT)
+		}
+		v.chainDepth.WithLabelValues(v.labelValues(cert.Dir, cert.CommonName, cert.FileName)...).Set(float64(cert.ChainDepth))
+		for _, usage := range cert.KeyUsages {
+			v.keyUsage.WithLabelValues(cert.CommonName, cert.FileName, usage).Set(1)
+		}
+		for _, eku := range cert.ExtKeyUsages {
+			v.extKeyUsage.WithLabelValues(cert.CommonName, cert.FileName, eku).Set(1)
+		}
+		if cert.SPKISHA256 != "" {
+			v.spki.WithLabelValues(cert.CommonName, cert.FileName, cert.SPKISHA256).Set(1)
+		}
+		if cert.Organization != "" {
+			v.organization.WithLabelValues(cert.CommonName, cert.FileName, cert.Organization).Set(1)
+		}
+		if cert.Format != "" {
+			v.format.WithLabelValues(cert.CommonName, cert.FileName, cert.Format).Set(1)
+		}
+		v.expiryDays.WithLabelValues().Observe(time.Until(cert.NotAfter).Hours() / 24)
+
+		duplicates[cert.Fingerprint]++
+	}
+
+	for fingerprint, count := range duplicates {
+		if count > 1 {
+			v.duplicateCount.WithLabelValues(fingerprint).Set(float64(count))
+		}
+	}
+}
+
+// certCollector exposes the per-certificate metrics. Without a certificate
+// source it serves the values pushed during scans; with one it builds a fresh
+// point-in-time view from the source on every scrape.
+type certCollector struct {
+	c *Collector
+}
+
+// Describe implements prometheus.Collector
+func (cc *certCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range cc.c.certs.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector
+func (cc *certCollector) Collect(ch chan<- prometheus.Metric) {
+	cc.c.mu.RLock()
+	source := cc.c.source
+	cc.c.mu.RUnlock()
+
+	vecs := cc.c.certs
+	if source != nil {
+		vecs = newCertVecs(cc.c.prefix, cc.c.certs.dirLabel, cc.c.certs.expiryBuckets)
+		vecs.populate(source())
+	}
+
+	for _, collector := range vecs.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+// SetCertificateSource switches the per-certificate metrics to scrape-time
+// collection from the given source. Passing nil restores push-on-scan.
+func (c *Collector) SetCertificateSource(source CertificateSource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.source = source
+	c.certs.reset()
+}
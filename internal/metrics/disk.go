@@ -0,0 +1,53 @@
+// internal/metrics/disk.go
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DiskSpaceSource returns the available bytes per directory
+type DiskSpaceSource func() map[string]uint64
+
+// newDiskAvailableDesc describes the available disk space metric
+func newDiskAvailableDesc(prefix string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName(prefix, "", "cert_monitor_disk_available_bytes"),
+		"Available disk space for the filesystem holding each certificate directory",
+		[]string{"dir"},
+		nil,
+	)
+}
+
+// diskCollector reads available disk space from the source on every scrape
+type diskCollector struct {
+	c *Collector
+}
+
+// Describe implements prometheus.Collector
+func (dc *diskCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dc.c.diskAvailableDesc
+}
+
+// Collect implements prometheus.Collector
+func (dc *diskCollector) Collect(ch chan<- prometheus.Metric) {
+	dc.c.mu.RLock()
+	source := dc.c.diskSpace
+	dc.c.mu.RUnlock()
+
+	if source == nil {
+		return
+	}
+
+	for dir, available := range source() {
+		ch <- prometheus.MustNewConstMetric(dc.c.diskAvailableDesc, prometheus.GaugeValue, float64(available), dir)
+	}
+}
+
+// SetDiskSpaceSource sets the source of the available disk space metric
+func (c *Collector) SetDiskSpaceSource(source DiskSpaceSource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.diskSpace = source
+}
@@ -4,7 +4,9 @@ package metrics
 
 import (
 	"fmt"
+	"runtime"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
@@ -16,137 +18,359 @@ var (
 	instance *Collector
 )
 
+// DefaultPrefix is the namespace of every metric unless configured otherwise
+const DefaultPrefix = "ssl"
+
 // Collector manages all Prometheus metrics
 type Collector struct {
 	// Certificate metrics
-	certExpiration     *prometheus.GaugeVec
-	certSANCount       *prometheus.GaugeVec
-	certInfo           *prometheus.GaugeVec
-	certDuplicateCount *prometheus.GaugeVec
-	certIssuerCode     *prometheus.GaugeVec
+	certs  *certVecs
+	source CertificateSource
 
 	// Security metrics
 	weakKeyTotal     prometheus.Gauge
 	deprecatedSigAlg prometheus.Gauge
+	disallowedSigAlg prometheus.Gauge
+
+	// Duplicate policy violations
+	duplicateViolations prometheus.Counter
 
 	// Operational metrics
 	certFilesTotal       prometheus.Gauge
 	certsParsedTotal     prometheus.Gauge
 	certParseErrorsTotal prometheus.Gauge
+	certParseErrors      *prometheus.GaugeVec
 	scanDuration         prometheus.Gauge
 	lastScanTimestamp    prometheus.Gauge
+	scansInFlight        prometheus.Gauge
+	degraded             prometheus.Gauge
+	oversizedFilesTotal  prometheus.Counter
+	cacheHitsTotal       prometheus.Counter
+	cacheMissesTotal     prometheus.Counter
+	configReloadErrors   prometheus.Counter
+	configReloads        *prometheus.CounterVec
+	scanTimeouts         prometheus.Counter
+	walkPermissionErrors *prometheus.CounterVec
+	certRotations        *prometheus.CounterVec
+	dirLastChange        *prometheus.GaugeVec
+	watchedDirs          prometheus.Gauge
+	monitoredFiles       prometheus.Gauge
+	insecureDir          *prometheus.GaugeVec
+	certTypeCount        *prometheus.GaugeVec
+	dirBackoff           *prometheus.GaugeVec
+	endpointSNIMatch     *prometheus.GaugeVec
+
+	// Disk metrics
+	diskSpace         DiskSpaceSource
+	diskAvailableDesc *prometheus.Desc
+
+	// Process metrics
+	buildInfo      *prometheus.GaugeVec
+	startTime      prometheus.Gauge
+	selfCertExpiry *prometheus.GaugeVec
+	uptime         prometheus.GaugeFunc
+	startTimestamp time.Time
 
 	mu       sync.RWMutex
 	registry prometheus.Registerer
+	prefix   string
 }
 
 // NewCollector creates a new metrics collector (singleton for default registry)
 func NewCollector() *Collector {
 	once.Do(func() {
-		instance = createCollector(prometheus.DefaultRegisterer)
+		instance = createCollector(prometheus.DefaultRegisterer, Options{Prefix: DefaultPrefix})
 	})
 	return instance
 }
 
 // NewCollectorWithRegistry creates a new metrics collector with a custom registry (for testing)
 func NewCollectorWithRegistry(reg prometheus.Registerer) *Collector {
-	return createCollector(reg)
+	return createCollector(reg, Options{Prefix: DefaultPrefix})
+}
+
+// Options customizes the metrics exposed by a collector
+type Options struct {
+	// Prefix replaces DefaultPrefix as the namespace of every metric name;
+	// an empty prefix drops it
+	Prefix string
+
+	// DirLabel adds the certificate directory as a dir label to the core
+	// per-certificate metrics
+	DirLabel bool
+
+	// ExpiryBuckets are the bucket upper bounds, in days, of the expiry
+	// histogram; empty uses DefaultExpiryBuckets
+	ExpiryBuckets []float64
+}
+
+// NewCollectorWithOptions creates a new metrics collector with custom options
+func NewCollectorWithOptions(reg prometheus.Registerer, opts Options) *Collector {
+	return createCollector(reg, opts)
 }
 
 // createCollector creates the actual collector instance
-func createCollector(reg prometheus.Registerer) *Collector {
+func createCollector(reg prometheus.Registerer, opts Options) *Collector {
+	prefix := opts.Prefix
 	c := &Collector{
-		registry: reg,
+		registry:       reg,
+		prefix:         prefix,
+		startTimestamp: time.Now(),
 		// Certificate metrics
-		certExpiration: prometheus.NewGaugeVec(
+		certs:             newCertVecs(prefix, opts.DirLabel, opts.ExpiryBuckets),
+		diskAvailableDesc: newDiskAvailableDesc(prefix),
+
+		// Security metrics
+		weakKeyTotal: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Name: "ssl_cert_expiration_timestamp",
-				Help: "Certificate expiration time (Unix timestamp)",
+				Namespace: prefix,
+				Name:      "cert_weak_key_total",
+				Help:      "Certificates with weak cryptographic keys",
 			},
-			[]string{"path", "subject", "issuer"},
 		),
-		certSANCount: prometheus.NewGaugeVec(
+		deprecatedSigAlg: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Name: "ssl_cert_san_count",
-				Help: "Number of Subject Alternative Names",
+				Namespace: prefix,
+				Name:      "cert_deprecated_sigalg_total",
+				Help:      "Certificates using deprecated signature algorithms",
 			},
-			[]string{"path"},
 		),
-		certInfo: prometheus.NewGaugeVec(
+		disallowedSigAlg: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Name: "ssl_cert_info",
-				Help: "Certificate information with labels",
+				Namespace: prefix,
+				Name:      "cert_disallowed_sigalg_total",
+				Help:      "Certificates using signature algorithms outside the configured allow-list",
 			},
-			[]string{"path", "subject", "issuer", "serial", "signature_algorithm"},
 		),
-		certDuplicateCount: prometheus.NewGaugeVec(
+
+		// Operational metrics
+		certFilesTotal: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Name: "ssl_cert_duplicate_count",
-				Help: "Number of duplicate certificates",
+				Namespace: prefix,
+				Name:      "cert_files_total",
+				Help:      "Total certificate files processed",
 			},
-			[]string{"fingerprint"},
 		),
-		certIssuerCode: prometheus.NewGaugeVec(
+		certsParsedTotal: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Name: "ssl_cert_issuer_code",
-				Help: "Numeric issuer classification",
+				Namespace: prefix,
+				Name:      "certs_parsed_total",
+				Help:      "Successfully parsed certificates",
 			},
-			[]string{"issuer", "common_name", "file_name"},
 		),
-
-		// Security metrics
-		weakKeyTotal: prometheus.NewGauge(
+		certParseErrorsTotal: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Name: "ssl_cert_weak_key_total",
-				Help: "Certificates with weak cryptographic keys",
+				Namespace: prefix,
+				Name:      "cert_parse_errors_total",
+				Help:      "Certificate parsing errors",
 			},
 		),
-		deprecatedSigAlg: prometheus.NewGauge(
+		certParseErrors: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "ssl_cert_deprecated_sigalg_total",
-				Help: "Certificates using deprecated signature algorithms",
+				Namespace: prefix,
+				Name:      "cert_parse_errors_by_reason",
+				Help:      "Certificate parsing errors in the last scan by reason",
 			},
+			[]string{"reason"},
 		),
-
-		// Operational metrics
-		certFilesTotal: prometheus.NewGauge(
+		scanDuration: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Name: "ssl_cert_files_total",
-				Help: "Total certificate files processed",
+				Namespace: prefix,
+				Name:      "cert_scan_duration_seconds",
+				Help:      "Directory scan duration",
 			},
 		),
-		certsParsedTotal: prometheus.NewGauge(
+		lastScanTimestamp: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Name: "ssl_certs_parsed_total",
-				Help: "Successfully parsed certificates",
+				Namespace: prefix,
+				Name:      "cert_last_scan_timestamp",
+				Help:      "Last successful scan time",
 			},
 		),
-		certParseErrorsTotal: prometheus.NewGauge(
+		scansInFlight: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Name: "ssl_cert_parse_errors_total",
-				Help: "Certificate parsing errors",
+				Namespace: prefix,
+				Name:      "cert_scans_in_flight",
+				Help:      "Number of certificate scans currently running",
 			},
 		),
-		scanDuration: prometheus.NewGauge(
+		degraded: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Name: "ssl_cert_scan_duration_seconds",
-				Help: "Directory scan duration",
+				Namespace: prefix,
+				Name:      "cert_monitor_degraded",
+				Help:      "Whether the last scan took longer than the scan interval (1 = yes)",
 			},
 		),
-		lastScanTimestamp: prometheus.NewGauge(
+		oversizedFilesTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: prefix,
+				Name:      "cert_oversized_files_total",
+				Help:      "Certificate files skipped for exceeding the maximum file size",
+			},
+		),
+		cacheHitsTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: prefix,
+				Name:      "cert_cache_hits_total",
+				Help:      "Certificate files served from the cache instead of being parsed",
+			},
+		),
+		cacheMissesTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: prefix,
+				Name:      "cert_cache_misses_total",
+				Help:      "Certificate files parsed because the cache had no entry for them",
+			},
+		),
+		scanTimeouts: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: prefix,
+				Name:      "cert_scan_timeouts_total",
+				Help:      "Scans abandoned for running longer than scan_timeout",
+			},
+		),
+		configReloadErrors: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: prefix,
+				Name:      "cert_config_reload_errors_total",
+				Help:      "Configuration reloads rejected because the new configuration was invalid",
+			},
+		),
+		configReloads: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prefix,
+				Name:      "cert_config_reload_total",
+				Help:      "Configuration reload attempts by result",
+			},
+			[]string{"result"},
+		),
+		duplicateViolations: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: prefix,
+				Name:      "cert_duplicate_violation_total",
+				Help:      "Certificates found in more than one file while duplicate_policy is error",
+			},
+		),
+		walkPermissionErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prefix,
+				Name:      "cert_walk_permission_errors_total",
+				Help:      "Paths skipped during directory scans because of permission errors",
+			},
+			[]string{"dir"},
+		),
+		certRotations: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prefix,
+				Name:      "cert_rotations_total",
+				Help:      "Times the certificate in a file was replaced by one with a different fingerprint",
+			},
+			[]string{"common_name", "file_name"},
+		),
+		dirLastChange: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_dir_last_change_seconds",
+				Help:      "Time a certificate in the directory was last added or modified (Unix timestamp)",
+			},
+			[]string{"dir"},
+		),
+		watchedDirs: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_watched_dirs",
+				Help:      "Directories watched for certificate changes",
+			},
+		),
+		monitoredFiles: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_monitored_files",
+				Help:      "Files holding a certificate as of the last scan or file change",
+			},
+		),
+		insecureDir: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_insecure_dir",
+				Help:      "Whether the certificate directory is group or world writable (1 = yes)",
+			},
+			[]string{"dir"},
+		),
+		certTypeCount: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_type_count",
+				Help:      "Certificates per directory by type (leaf, intermediate or root) as of the last scan",
+			},
+			[]string{"dir", "type"},
+		),
+		dirBackoff: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_dir_backoff",
+				Help:      "Whether scans skip the directory after it yielded only parse errors (1 = yes)",
+			},
+			[]string{"dir"},
+		),
+		endpointSNIMatch: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_endpoint_sni_match",
+				Help:      "Whether the certificate presented by a network endpoint is valid for the requested server name (1 = yes)",
+			},
+			[]string{"endpoint", "server_name", "common_name"},
+		),
+
+		// Process metrics
+		buildInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_monitor_build_info",
+				Help:      "Build information of the running monitor",
+			},
+			[]string{"version", "commit", "go_version"},
+		),
+		startTime: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: prefix,
+				Name:      "cert_monitor_start_time_seconds",
+				Help:      "Monitor start time (Unix timestamp)",
+			},
+		),
+		selfCertExpiry: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Name: "ssl_cert_last_scan_timestamp",
-				Help: "Last successful scan time",
+				Namespace: prefix,
+				Name:      "cert_monitor_self_expiration_timestamp",
+				Help:      "Expiration time (Unix timestamp) of the certificate the monitor serves HTTPS with",
 			},
+			[]string{"path"},
 		),
 	}
 
+	c.startTime.Set(float64(c.startTimestamp.Unix()))
+	c.uptime = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: prefix,
+			Name:      "cert_monitor_uptime_seconds",
+			Help:      "Seconds since the monitor started",
+		},
+		func() float64 {
+			return time.Since(c.startTimestamp).Seconds()
+		},
+	)
+
 	// Register all metrics with the provided registerer
 	c.registerMetrics(reg)
 
 	return c
 }
 
+// metricName returns the full name of a metric under the collector's prefix
+func (c *Collector) metricName(name string) string {
+	return prometheus.BuildFQName(c.prefix, "", name)
+}
+
 // safeRegister safely registers a collector, logging warnings instead of panicking on duplicates
 func (c *Collector) safeRegister(reg prometheus.Registerer, collector prometheus.Collector, name string) {
 	if err := reg.Register(collector); err != nil {
@@ -163,22 +387,45 @@ func (c *Collector) safeRegister(reg prometheus.Registerer, collector prometheus
 // registerMetrics registers all metrics with the provided registerer
 func (c *Collector) registerMetrics(reg prometheus.Registerer) {
 	// Certificate metrics - use safe registration
-	c.safeRegister(reg, c.certExpiration, "ssl_cert_expiration_timestamp")
-	c.safeRegister(reg, c.certSANCount, "ssl_cert_san_count")
-	c.safeRegister(reg, c.certInfo, "ssl_cert_info")
-	c.safeRegister(reg, c.certDuplicateCount, "ssl_cert_duplicate_count")
-	c.safeRegister(reg, c.certIssuerCode, "ssl_cert_issuer_code")
+	c.safeRegister(reg, &certCollector{c: c}, c.metricName("cert_certificate_metrics"))
 
 	// Security metrics
-	c.safeRegister(reg, c.weakKeyTotal, "ssl_cert_weak_key_total")
-	c.safeRegister(reg, c.deprecatedSigAlg, "ssl_cert_deprecated_sigalg_total")
+	c.safeRegister(reg, c.weakKeyTotal, c.metricName("cert_weak_key_total"))
+	c.safeRegister(reg, c.deprecatedSigAlg, c.metricName("cert_deprecated_sigalg_total"))
+	c.safeRegister(reg, c.disallowedSigAlg, c.metricName("cert_disallowed_sigalg_total"))
+	c.safeRegister(reg, c.duplicateViolations, c.metricName("cert_duplicate_violation_total"))
 
 	// Operational metrics
-	c.safeRegister(reg, c.certFilesTotal, "ssl_cert_files_total")
-	c.safeRegister(reg, c.certsParsedTotal, "ssl_certs_parsed_total")
-	c.safeRegister(reg, c.certParseErrorsTotal, "ssl_cert_parse_errors_total")
-	c.safeRegister(reg, c.scanDuration, "ssl_cert_scan_duration_seconds")
-	c.safeRegister(reg, c.lastScanTimestamp, "ssl_cert_last_scan_timestamp")
+	c.safeRegister(reg, c.certFilesTotal, c.metricName("cert_files_total"))
+	c.safeRegister(reg, c.certsParsedTotal, c.metricName("certs_parsed_total"))
+	c.safeRegister(reg, c.certParseErrorsTotal, c.metricName("cert_parse_errors_total"))
+	c.safeRegister(reg, c.certParseErrors, c.metricName("cert_parse_errors_by_reason"))
+	c.safeRegister(reg, c.scanDuration, c.metricName("cert_scan_duration_seconds"))
+	c.safeRegister(reg, c.lastScanTimestamp, c.metricName("cert_last_scan_timestamp"))
+	c.safeRegister(reg, c.scansInFlight, c.metricName("cert_scans_in_flight"))
+	c.safeRegister(reg, c.degraded, c.metricName("cert_monitor_degraded"))
+	c.safeRegister(reg, c.oversizedFilesTotal, c.metricName("cert_oversized_files_total"))
+	c.safeRegister(reg, c.cacheHitsTotal, c.metricName("cert_cache_hits_total"))
+	c.safeRegister(reg, c.cacheMissesTotal, c.metricName("cert_cache_misses_total"))
+	c.safeRegister(reg, c.configReloadErrors, c.metricName("cert_config_reload_errors_total"))
+	c.safeRegister(reg, c.configReloads, c.metricName("cert_config_reload_total"))
+	c.safeRegister(reg, c.scanTimeouts, c.metricName("cert_scan_timeouts_total"))
+	c.safeRegister(reg, c.walkPermissionErrors, c.metricName("cert_walk_permission_errors_total"))
+	c.safeRegister(reg, c.certRotations, c.metricName("cert_rotations_total"))
+	c.safeRegister(reg, c.dirLastChange, c.metricName("cert_dir_last_change_seconds"))
+	c.safeRegister(reg, c.watchedDirs, c.metricName("cert_watched_dirs"))
+	c.safeRegister(reg, c.monitoredFiles, c.metricName("cert_monitored_files"))
+	c.safeRegister(reg, c.insecureDir, c.metricName("cert_insecure_dir"))
+	c.safeRegister(reg, c.certTypeCount, c.metricName("cert_type_count"))
+	c.safeRegister(reg, c.dirBackoff, c.metricName("cert_dir_backoff"))
+	c.safeRegister(reg, c.endpointSNIMatch, c.metricName("cert_endpoint_sni_match"))
+
+	// Process metrics
+	c.safeRegister(reg, c.buildInfo, c.metricName("cert_monitor_build_info"))
+	c.safeRegister(reg, c.startTime, c.metricName("cert_monitor_start_time_seconds"))
+	c.safeRegister(reg, c.uptime, c.metricName("cert_monitor_uptime_seconds"))
+	c.safeRegister(reg, c.selfCertExpiry, c.metricName("cert_monitor_self_expiration_timestamp"))
+	c.safeRegister(reg, &diskCollector{c: c}, c.metricName("cert_monitor_disk_available_bytes"))
 
 	// Only register Go runtime metrics if using default registry
 	// Use safe registration for these as they're commonly registered by other code
@@ -193,41 +440,134 @@ func (c *Collector) ResetCertificateMetrics() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.certExpiration.Reset()
-	c.certSANCount.Reset()
-	c.certInfo.Reset()
-	c.certDuplicateCount.Reset()
-	c.certIssuerCode.Reset()
+	c.certs.reset()
 }
 
 // SetCertExpiration sets certificate expiration metric
-func (c *Collector) SetCertExpiration(path, subject, issuer string, timestamp float64) {
-	c.certExpiration.WithLabelValues(path, subject, issuer).Set(timestamp)
+func (c *Collector) SetCertExpiration(path, subject, issuer, dir string, timestamp float64) {
+	c.certs.expiration.WithLabelValues(c.certs.labelValues(dir, path, subject, issuer)...).Set(timestamp)
 }
 
 // SetCertSANCount sets SAN count metric
-func (c *Collector) SetCertSANCount(path string, count float64) {
-	c.certSANCount.WithLabelValues(path).Set(count)
+func (c *Collector) SetCertSANCount(path, dir string, count float64) {
+	c.certs.sanCount.WithLabelValues(c.certs.labelValues(dir, path)...).Set(count)
 }
 
 // SetCertInfo sets certificate info metric
-func (c *Collector) SetCertInfo(path, subject, issuer, serial, sigAlg string) {
-	c.certInfo.WithLabelValues(path, subject, issuer, serial, sigAlg).Set(1)
+func (c *Collector) SetCertInfo(path, subject, issuer, serial, sigAlg, source, dir string) {
+	c.certs.info.WithLabelValues(c.certs.labelValues(dir, path, subject, issuer, serial, sigAlg, source)...).Set(1)
 }
 
 // SetCertDuplicateCount sets duplicate count metric
 func (c *Collector) SetCertDuplicateCount(fingerprint string, count float64) {
-	c.certDuplicateCount.WithLabelValues(fingerprint).Set(count)
+	c.certs.duplicateCount.WithLabelValues(fingerprint).Set(count)
 }
 
 // SetCertIssuerCode sets issuer code metric (legacy method for backward compatibility)
 func (c *Collector) SetCertIssuerCode(issuer string, code float64) {
-	c.certIssuerCode.WithLabelValues(issuer, "", "").Set(code)
+	c.certs.issuerCode.WithLabelValues(c.certs.labelValues("", issuer, "", "")...).Set(code)
 }
 
 // SetCertIssuerCodeWithLabels sets issuer code metric with additional labels
-func (c *Collector) SetCertIssuerCodeWithLabels(issuer, commonName, fileName string, code float64) {
-	c.certIssuerCode.WithLabelValues(issuer, commonName, fileName).Set(code)
+func (c *Collector) SetCertIssuerCodeWithLabels(issuer, commonName, fileName, dir string, code float64) {
+	c.certs.issuerCode.WithLabelValues(c.certs.labelValues(dir, issuer, commonName, fileName)...).Set(code)
+}
+
+// SetCertExpiringSoon sets the expiring soon metric
+func (c *Collector) SetCertExpiringSoon(path, dir string, expiringSoon bool) {
+	value := 0.0
+	if expiringSoon {
+		value = 1
+	}
+	c.certs.expiringSoon.WithLabelValues(c.certs.labelValues(dir, path)...).Set(value)
+}
+
+// SetCertRenewalDue sets whether a certificate is within its issuer's
+// renewal window
+func (c *Collector) SetCertRenewalDue(commonName, fileName string, renewalDue bool) {
+	value := 0.0
+	if renewalDue {
+		value = 1
+	}
+	c.certs.renewalDue.WithLabelValues(commonName, fileName).Set(value)
+}
+
+// SetCertKeyWeakness marks a key weakness for a certificate
+func (c *Collector) SetCertKeyWeakness(commonName, fileName, reason string) {
+	c.certs.keyWeakness.WithLabelValues(commonName, fileName, reason).Set(1)
+}
+
+// SetCertDeprecatedCurve marks a certificate whose key uses a deprecated curve
+func (c *Collector) SetCertDeprecatedCurve(commonName, fileName, curve string) {
+	c.certs.deprecatedCurve.WithLabelValues(commonName, fileName, curve).Set(1)
+}
+
+// SetCertIPSANMismatch marks an IP SAN that failed reverse lookup validation
+func (c *Collector) SetCertIPSANMismatch(commonName, fileName, ip string) {
+	c.certs.ipSANMismatch.WithLabelValues(commonName, fileName, ip).Set(1)
+}
+
+// SetCertHasSCT sets whether a certificate embeds Certificate Transparency SCTs
+func (c *Collector) SetCertHasSCT(commonName, fileName string, hasSCT bool) {
+	value := 0.0
+	if hasSCT {
+		value = 1
+	}
+	c.certs.hasSCT.WithLabelValues(commonName, fileName).Set(value)
+}
+
+// SetCertKeyUsage marks a key usage as set on a certificate
+func (c *Collector) SetCertKeyUsage(commonName, fileName, usage string) {
+	c.certs.keyUsage.WithLabelValues(commonName, fileName, usage).Set(1)
+}
+
+// SetCertExtKeyUsage marks an extended key usage as set on a certificate
+func (c *Collector) SetCertExtKeyUsage(commonName, fileName, eku string) {
+	c.certs.extKeyUsage.WithLabelValues(commonName, fileName, eku).Set(1)
+}
+
+// SetCertExcessiveValidity sets the validity period in days of a certificate
+// valid for longer than allowed
+func (c *Collector) SetCertExcessiveValidity(commonName, fileName string, days float64) {
+	c.certs.excessiveValidity.WithLabelValues(commonName, fileName).Set(days)
+}
+
+// SetCertPolicyViolation sets the validity period in days of a certificate
+// valid for longer than the named validity policy allows
+func (c *Collector) SetCertPolicyViolation(commonName, fileName, policy string, days float64) {
+	c.certs.policyViolation.WithLabelValues(commonName, fileName, policy).Set(days)
+}
+
+// SetCertByDomain sets the expiration metric of a certificate grouped by base
+// domain
+func (c *Collector) SetCertByDomain(baseDomain, commonName, fileName string, timestamp float64) {
+	c.certs.byDomain.WithLabelValues(baseDomain, commonName, fileName).Set(timestamp)
+}
+
+// SetCertSPKI sets the SPKI pin metric of a certificate
+func (c *Collector) SetCertSPKI(commonName, fileName, spkiSHA256 string) {
+	c.certs.spki.WithLabelValues(commonName, fileName, spkiSHA256).Set(1)
+}
+
+// SetCertOrganization sets the subject organization metric of a certificate
+func (c *Collector) SetCertOrganization(commonName, fileName, organization string) {
+	c.certs.organization.WithLabelValues(commonName, fileName, organization).Set(1)
+}
+
+// SetCertFormat sets the file encoding metric of a certificate
+func (c *Collector) SetCertFormat(commonName, fileName, format string) {
+	c.certs.format.WithLabelValues(commonName, fileName, format).Set(1)
+}
+
+// ObserveCertExpiryDays records a certificate's days until expiry in the
+// expiry histogram
+func (c *Collector) ObserveCertExpiryDays(days float64) {
+	c.certs.expiryDays.WithLabelValues().Observe(days)
+}
+
+// SetCertChainDepth sets the chain depth metric
+func (c *Collector) SetCertChainDepth(commonName, fileName, dir string, depth float64) {
+	c.certs.chainDepth.WithLabelValues(c.certs.labelValues(dir, commonName, fileName)...).Set(depth)
 }
 
 // SetWeakKeyTotal sets weak key total metric
@@ -240,6 +580,11 @@ func (c *Collector) SetDeprecatedSigAlgTotal(total float64) {
 	c.deprecatedSigAlg.Set(total)
 }
 
+// SetDisallowedSigAlgTotal sets disallowed signature algorithm total metric
+func (c *Collector) SetDisallowedSigAlgTotal(total float64) {
+	c.disallowedSigAlg.Set(total)
+}
+
 // SetCertFilesTotal sets total certificate files metric
 func (c *Collector) SetCertFilesTotal(total float64) {
 	c.certFilesTotal.Set(total)
@@ -255,6 +600,11 @@ func (c *Collector) SetCertParseErrorsTotal(total float64) {
 	c.certParseErrorsTotal.Set(total)
 }
 
+// SetCertParseErrors sets the parse errors of the last scan for a reason
+func (c *Collector) SetCertParseErrors(reason string, count float64) {
+	c.certParseErrors.WithLabelValues(reason).Set(count)
+}
+
 // SetScanDuration sets scan duration metric
 func (c *Collector) SetScanDuration(seconds float64) {
 	c.scanDuration.Set(seconds)
@@ -265,6 +615,142 @@ func (c *Collector) SetLastScanTimestamp(timestamp float64) {
 	c.lastScanTimestamp.Set(timestamp)
 }
 
+// IncScansInFlight counts a scan that started running
+func (c *Collector) IncScansInFlight() {
+	c.scansInFlight.Inc()
+}
+
+// DecScansInFlight counts a running scan that finished
+func (c *Collector) DecScansInFlight() {
+	c.scansInFlight.Dec()
+}
+
+// SetDegraded sets whether scanning is falling behind the scan interval
+func (c *Collector) SetDegraded(degraded bool) {
+	value := 0.0
+	if degraded {
+		value = 1
+	}
+	c.degraded.Set(value)
+}
+
+// IncOversizedFiles increments the oversized files counter
+func (c *Collector) IncOversizedFiles() {
+	c.oversizedFilesTotal.Inc()
+}
+
+// IncCacheHits increments the certificate cache hit counter
+func (c *Collector) IncCacheHits() {
+	c.cacheHitsTotal.Inc()
+}
+
+// IncCacheMisses increments the certificate cache miss counter
+func (c *Collector) IncCacheMisses() {
+	c.cacheMissesTotal.Inc()
+}
+
+// IncScanTimeouts increments the abandoned scan counter
+func (c *Collector) IncScanTimeouts() {
+	c.scanTimeouts.Inc()
+}
+
+// IncConfigReloadErrors increments the rejected configuration reload counter
+func (c *Collector) IncConfigReloadErrors() {
+	c.configReloadErrors.Inc()
+}
+
+// IncConfigReloads increments the configuration reload counter for a result
+func (c *Collector) IncConfigReloads(result string) {
+	c.configReloads.WithLabelValues(result).Inc()
+}
+
+// IncDuplicateViolations increments the duplicate policy violation counter
+func (c *Collector) IncDuplicateViolations() {
+	c.duplicateViolations.Inc()
+}
+
+// IncWalkPermissionErrors increments the permission error counter for a certificate directory
+func (c *Collector) IncWalkPermissionErrors(dir string) {
+	c.walkPermissionErrors.WithLabelValues(dir).Inc()
+}
+
+// IncCertRotations increments the rotation counter of a certificate file
+func (c *Collector) IncCertRotations(commonName, fileName string) {
+	c.certRotations.WithLabelValues(commonName, fileName).Inc()
+}
+
+// SetWatchedDirs sets the number of directories watched for changes
+func (c *Collector) SetWatchedDirs(count float64) {
+	c.watchedDirs.Set(count)
+}
+
+// SetMonitoredFiles sets the number of files holding a certificate
+func (c *Collector) SetMonitoredFiles(count float64) {
+	c.monitoredFiles.Set(count)
+}
+
+// SetInsecureDir sets whether a certificate directory is group or world writable
+func (c *Collector) SetInsecureDir(dir string, insecure bool) {
+	value := 0.0
+	if insecure {
+		value = 1
+	}
+	c.insecureDir.WithLabelValues(dir).Set(value)
+}
+
+// SetDirBackoff sets whether scans skip a directory after parse errors
+func (c *Collector) SetDirBackoff(dir string, backingOff bool) {
+	value := 0.0
+	if backingOff {
+		value = 1
+	}
+	c.dirBackoff.WithLabelValues(dir).Set(value)
+}
+
+// SetCertTypeCounts replaces the certificate type counts with the given
+// counts per directory and type
+func (c *Collector) SetCertTypeCounts(counts map[string]map[string]int) {
+	c.certTypeCount.Reset()
+	for dir, types := range counts {
+		for certType, count := range types {
+			c.certTypeCount.WithLabelValues(dir, certType).Set(float64(count))
+		}
+	}
+}
+
+// ResetEndpointSNIMatches removes the server name matches of all endpoints
+func (c *Collector) ResetEndpointSNIMatches() {
+	c.endpointSNIMatch.Reset()
+}
+
+// SetEndpointSNIMatch sets whether the certificate presented by an endpoint
+// is valid for the server name requested from it
+func (c *Collector) SetEndpointSNIMatch(endpoint, serverName, commonName string, matched bool) {
+	value := 0.0
+	if matched {
+		value = 1
+	}
+	c.endpointSNIMatch.WithLabelValues(endpoint, serverName, commonName).Set(value)
+}
+
+// SetDirLastChange sets the last change timestamp of a certificate directory
+func (c *Collector) SetDirLastChange(dir string, timestamp float64) {
+	c.dirLastChange.WithLabelValues(dir).Set(timestamp)
+}
+
+// SetBuildInfo sets build information metric
+func (c *Collector) SetBuildInfo(version, commit string) {
+	c.buildInfo.Reset()
+	c.buildInfo.WithLabelValues(version, commit, runtime.Version()).Set(1)
+}
+
+// SetSelfCertExpiration sets the expiration timestamp of the certificate the
+// monitor serves HTTPS with
+func (c *Collector) SetSelfCertExpiration(path string, timestamp float64) {
+	c.selfCertExpiry.Reset()
+	c.selfCertExpiry.WithLabelValues(path).Set(timestamp)
+}
+
 // GetMetrics returns current metric values for health checks
 func (c *Collector) GetMetrics() map[string]float64 {
 	c.mu.RLock()
@@ -278,11 +764,29 @@ func (c *Collector) GetMetrics() map[string]float64 {
 	metrics["cert_parse_errors_total"] = c.getGaugeValue(c.certParseErrorsTotal)
 	metrics["weak_key_total"] = c.getGaugeValue(c.weakKeyTotal)
 	metrics["deprecated_sigalg_total"] = c.getGaugeValue(c.deprecatedSigAlg)
+	metrics["disallowed_sigalg_total"] = c.getGaugeValue(c.disallowedSigAlg)
 	metrics["last_scan_timestamp"] = c.getGaugeValue(c.lastScanTimestamp)
+	metrics["degraded"] = c.getGaugeValue(c.degraded)
+	metrics["watched_dirs"] = c.getGaugeValue(c.watchedDirs)
+	metrics["monitored_files"] = c.getGaugeValue(c.monitoredFiles)
+	metrics["cache_hits_total"] = c.getCounterValue(c.cacheHitsTotal)
+	metrics["cache_misses_total"] = c.getCounterValue(c.cacheMissesTotal)
+	metrics["config_reload_errors_total"] = c.getCounterValue(c.configReloadErrors)
+	metrics["scan_timeouts_total"] = c.getCounterValue(c.scanTimeouts)
 
 	return metrics
 }
 
+// getCounterValue safely retrieves a counter value
+func (c *Collector) getCounterValue(counter prometheus.Counter) float64 {
+	metric := &dto.Metric{}
+	counter.Write(metric)
+	if metric.Counter != nil && metric.Counter.Value != nil {
+		return *metric.Counter.Value
+	}
+	return 0
+}
+
 // getGaugeValue safely retrieves a gauge value
 func (c *Collector) getGaugeValue(gauge prometheus.Gauge) float64 {
 	metric := &dto.Metric{}
@@ -0,0 +1,25 @@
+// internal/metrics/push.go
+
+package metrics
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// Push sends every metric from gatherer to a Pushgateway, replacing the
+// metrics previously pushed for the same job and, if not empty, instance
+func Push(ctx context.Context, gatherer prometheus.Gatherer, url, job, instance string, client push.HTTPDoer) error {
+	pusher := push.New(url, job).Gatherer(gatherer).Client(client)
+	if instance != "" {
+		pusher = pusher.Grouping("instance", instance)
+	}
+
+	if err := pusher.PushContext(ctx); err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+	return nil
+}
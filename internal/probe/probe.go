@@ -0,0 +1,101 @@
+// internal/probe/probe.go
+
+package probe
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Scheme prefixes the URL an endpoint is identified by in place of a file
+// path, e.g. tls://example.com:443
+const Scheme = "tls://"
+
+// URL returns the URL of endpoint
+func URL(endpoint string) string {
+	return Scheme + endpoint
+}
+
+// Endpoint returns the endpoint of url, and whether url names one
+func Endpoint(url string) (string, bool) {
+	return strings.CutPrefix(url, Scheme)
+}
+
+// ParseEndpoint splits an endpoint, host:port optionally followed by
+// @server_name, into the address to dial and the SNI server name, which
+// defaults to the host
+func ParseEndpoint(endpoint string) (address, serverName string, err error) {
+	address, serverName, hasServerName := strings.Cut(endpoint, "@")
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	if host == "" || port == "" {
+		return "", "", fmt.Errorf("invalid endpoint %q: host and port are required", endpoint)
+	}
+
+	if !hasServerName {
+		return address, host, nil
+	}
+	if serverName == "" {
+		return "", "", fmt.Errorf("invalid endpoint %q: empty server name after @", endpoint)
+	}
+	return address, serverName, nil
+}
+
+// Fetch connects to endpoint (host:port[@server_name]), completes a TLS
+// handshake requesting the endpoint's server name and returns the
+// certificates presented by the server PEM encoded, leaf first. The chain is
+// not verified: expired, self-signed and privately issued certificates are
+// exactly what should be reported.
+func Fetch(ctx context.Context, endpoint string, timeout time.Duration) ([]byte, error) {
+	address, serverName, err := ParseEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config: &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: true,
+		},
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", endpoint, err)
+	}
+	defer conn.Close()
+
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil, errors.New("unexpected connection type")
+	}
+
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate presented by %s", endpoint)
+	}
+
+	var buf bytes.Buffer
+	for _, c := range certs {
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw}); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
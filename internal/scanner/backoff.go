@@ -0,0 +1,74 @@
+// internal/scanner/backoff.go
+
+package scanner
+
+import "go.uber.org/zap"
+
+// maxBackoffScans caps how many scans in a row skip a failing directory
+const maxBackoffScans = 16
+
+// dirBackoff tracks a directory whose scans yield only parse errors
+type dirBackoff struct {
+	// Scans skipped after the last failure, and scans left to skip
+	length int
+	skip   int
+}
+
+// registerScanFailure records that a scan of dir yielded only parse errors,
+// doubling the number of following scans that skip it
+func (s *Scanner) registerScanFailure(dir string, parseErrors int) {
+	s.backoffMu.Lock()
+	defer s.backoffMu.Unlock()
+
+	backoff, ok := s.backoffs[dir]
+	if !ok {
+		backoff = &dirBackoff{}
+		s.backoffs[dir] = backoff
+	}
+	backoff.length = min(max(backoff.length*2, 1), maxBackoffScans)
+	backoff.skip = backoff.length
+
+	s.metrics.SetDirBackoff(dir, true)
+	s.logger.Warn("Directory yielded only parse errors, skipping it in the next scans",
+		zap.String("dir", dir),
+		zap.Int("parse_errors", parseErrors),
+		zap.Int("skipped_scans", backoff.skip))
+}
+
+// registerScanSuccess ends the backoff of dir, if any
+func (s *Scanner) registerScanSuccess(dir string) {
+	s.backoffMu.Lock()
+	defer s.backoffMu.Unlock()
+
+	if _, ok := s.backoffs[dir]; !ok {
+		return
+	}
+	delete(s.backoffs, dir)
+	s.metrics.SetDirBackoff(dir, false)
+}
+
+// skipForBackoff reports whether this scan should skip dir, using up one of
+// its skipped scans. The directory is scanned again once none are left.
+func (s *Scanner) skipForBackoff(dir string) bool {
+	s.backoffMu.Lock()
+	defer s.backoffMu.Unlock()
+
+	backoff, ok := s.backoffs[dir]
+	if !ok || backoff.skip == 0 {
+		return false
+	}
+	backoff.skip--
+	return true
+}
+
+// updateBackoffs applies the parse results of a finished scan to the backoff
+// of each scanned directory
+func (s *Scanner) updateBackoffs(dirs []string, parsed, parseErrors map[string]int) {
+	for _, dir := range dirs {
+		if parsed[dir] == 0 && parseErrors[dir] > s.config.ParseErrorThreshold {
+			s.registerScanFailure(dir, parseErrors[dir])
+		} else {
+			s.registerScanSuccess(dir)
+		}
+	}
+}
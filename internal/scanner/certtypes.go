@@ -0,0 +1,34 @@
+// internal/scanner/certtypes.go
+
+package scanner
+
+import "github.com/brandonhon/tls-cert-monitor/internal/cert"
+
+// certTypes lists the certificate types counted per directory
+var certTypes = []string{cert.TypeLeaf, cert.TypeIntermediate, cert.TypeRoot}
+
+// certTypeCounts counts the leaf, intermediate and root certificates in each
+// directory, including every certificate of bundle files. Entries cached
+// before types were recorded don't count.
+func (s *Scanner) certTypeCounts(certInfos []*CertificateInfo) map[string]map[string]int {
+	counts := make(map[string]map[string]int)
+	for _, certInfo := range certInfos {
+		if len(certInfo.ChainTypes) == 0 {
+			continue
+		}
+
+		dir := s.directoryFor(certInfo.Path)
+		dirCounts, ok := counts[dir]
+		if !ok {
+			dirCounts = make(map[string]int, len(certTypes))
+			for _, certType := range certTypes {
+				dirCounts[certType] = 0
+			}
+			counts[dir] = dirCounts
+		}
+		for _, certType := range certInfo.ChainTypes {
+			dirCounts[certType]++
+		}
+	}
+	return counts
+}
@@ -0,0 +1,68 @@
+// internal/scanner/debounce.go
+
+package scanner
+
+import (
+	"sync"
+	"time"
+)
+
+// fileDebouncer delays handling a file until its events stop for a while, so
+// a burst of writes during a certificate rotation is handled once
+type fileDebouncer struct {
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+}
+
+// newFileDebouncer creates a debouncer without pending work
+func newFileDebouncer() *fileDebouncer {
+	return &fileDebouncer{timers: make(map[string]*time.Timer)}
+}
+
+// schedule runs fn after delay, replacing a pending run for the same path
+func (d *fileDebouncer) schedule(path string, delay time.Duration, fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if timer, ok := d.timers[path]; ok {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		d.mu.Lock()
+		current := d.timers[path] == timer
+		if current {
+			delete(d.timers, path)
+		}
+		d.mu.Unlock()
+
+		// A replaced timer may fire before Stop takes effect
+		if current {
+			fn()
+		}
+	})
+	d.timers[path] = timer
+}
+
+// cancel drops a pending run for path
+func (d *fileDebouncer) cancel(path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if timer, ok := d.timers[path]; ok {
+		timer.Stop()
+		delete(d.timers, path)
+	}
+}
+
+// stop drops all pending runs
+func (d *fileDebouncer) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for path, timer := range d.timers {
+		timer.Stop()
+		delete(d.timers, path)
+	}
+}
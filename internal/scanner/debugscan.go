@@ -0,0 +1,91 @@
+// internal/scanner/debugscan.go
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/source"
+)
+
+// Outcomes of a file in ScanDirectory
+const (
+	FileParsed  = "parsed"
+	FileFailed  = "failed"
+	FileSkipped = "skipped"
+	FileIgnored = "ignored"
+)
+
+// FileResult is what ScanDirectory made of one file
+type FileResult struct {
+	Path        string
+	Status      string
+	Reason      string
+	Err         error
+	Certificate *CertificateInfo
+}
+
+// ScanDirectory walks dir and parses each file there and then, for
+// troubleshooting files missing from the results. Files that don't look like
+// certificates are reported as ignored rather than left out. The cache, the
+// published results and the certificate metrics aren't touched.
+func (s *Scanner) ScanDirectory(ctx context.Context, dir string) ([]FileResult, error) {
+	var results []FileResult
+	err := source.NewFilesystem(dir, 0).Walk(ctx, func(object source.Object, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		path := object.Key
+
+		if err != nil {
+			// The directory itself must be readable
+			if path == dir {
+				return err
+			}
+			results = append(results, FileResult{Path: path, Status: FileFailed, Reason: reasonReadError, Err: err})
+			return nil
+		}
+
+		if object.Dir {
+			return nil
+		}
+
+		if s.isExcluded(s.directoryFor(path), path) {
+			results = append(results, FileResult{Path: path, Status: FileIgnored, Reason: "excluded by exclude_paths"})
+			return nil
+		}
+		if s.config.MaxDepth > 0 && walkDepth(s.directoryFor(path), path) > s.config.MaxDepth {
+			results = append(results, FileResult{Path: path, Status: FileIgnored, Reason: "deeper than max_depth"})
+			return nil
+		}
+		if !s.isCertificateFile(path) {
+			results = append(results, FileResult{Path: path, Status: FileIgnored, Reason: "not a certificate file name"})
+			return nil
+		}
+
+		data, err := s.readCertificateData(ctx, path)
+		if err != nil {
+			results = append(results, FileResult{Path: path, Status: FileFailed, Reason: parseErrorReason(err), Err: err})
+			return nil
+		}
+		if data == nil {
+			results = append(results, FileResult{Path: path, Status: FileSkipped, Reason: "oversized or without a certificate"})
+			return nil
+		}
+
+		certInfo, err := s.parseCertificate(path, data)
+		if err != nil {
+			results = append(results, FileResult{Path: path, Status: FileFailed, Reason: parseErrorReason(err), Err: err})
+			return nil
+		}
+		results = append(results, FileResult{Path: path, Status: FileParsed, Certificate: certInfo})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,82 @@
+// internal/scanner/dirchange.go
+
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/fileutil"
+	"github.com/brandonhon/tls-cert-monitor/internal/probe"
+	"go.uber.org/zap"
+)
+
+// recordDirChange records that a certificate in dir changed at the given time.
+// The timestamp only moves forward, so an older file modification time seen
+// during a scan never hides a change the watcher already reported.
+func (s *Scanner) recordDirChange(dir string, changed time.Time) {
+	s.dirChangesMu.Lock()
+	defer s.dirChangesMu.Unlock()
+
+	if last, ok := s.dirChanges[dir]; ok && !changed.After(last) {
+		return
+	}
+	s.dirChanges[dir] = changed
+	s.metrics.SetDirLastChange(dir, float64(changed.Unix()))
+}
+
+// checkDirPermissions reports whether a certificate directory is group or
+// world writable, which lets other users plant or swap certificates.
+// require_secure_dirs refuses such directories at startup; this catches
+// permissions loosened while the monitor runs. ssl_cert_insecure_dir carries
+// the state on every scan; it is only logged when it changes.
+func (s *Scanner) checkDirPermissions(dir string) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return
+	}
+
+	insecure := fileutil.IsGroupOrWorldWritable(info.Mode())
+	s.metrics.SetInsecureDir(dir, insecure)
+
+	s.insecureDirsMu.Lock()
+	changed := s.insecureDirs[dir] != insecure
+	s.insecureDirs[dir] = insecure
+	s.insecureDirsMu.Unlock()
+	if !changed {
+		return
+	}
+
+	if insecure {
+		s.logger.Warn("Certificate directory is group or world writable",
+			zap.String("dir", dir),
+			zap.String("mode", info.Mode().Perm().String()))
+	} else {
+		s.logger.Info("Certificate directory is no longer group or world writable",
+			zap.String("dir", dir),
+			zap.String("mode", info.Mode().Perm().String()))
+	}
+}
+
+// directoryFor returns the root of the source holding path: the configured
+// certificate directory containing it, preferring the most specific one, or
+// the object store. Manifest entries and paths outside every configured
+// directory map to their parent directory and network endpoints to tls://.
+func (s *Scanner) directoryFor(path string) string {
+	if _, ok := probe.Endpoint(path); ok {
+		return probe.Scheme
+	}
+
+	match := ""
+	for _, src := range s.currentSources() {
+		if _, ok := src.Key(path); ok && len(src.Root()) > len(match) {
+			match = src.Root()
+		}
+	}
+
+	if match == "" {
+		return filepath.Dir(path)
+	}
+	return match
+}
@@ -0,0 +1,86 @@
+// internal/scanner/endpoints.go
+
+package scanner
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/probe"
+	"go.uber.org/zap"
+)
+
+// Values of the source label on ssl_cert_info
+const (
+	sourceFile    = "file"
+	sourceNetwork = "network"
+)
+
+// scanEndpoints hands the URL of each configured endpoint to scanFile. The
+// presented certificate can change at any time, so each scan fetches it
+// again; unchanged certificates still reuse their parse by content.
+func (s *Scanner) scanEndpoints(ctx context.Context, scanFile func(string)) {
+	for _, endpoint := range s.config.Endpoints {
+		if ctx.Err() != nil {
+			return
+		}
+
+		path := probe.URL(endpoint)
+		s.cache.Delete(path)
+		scanFile(path)
+	}
+}
+
+// readEndpoint fetches the certificates presented by an endpoint, PEM
+// encoded, so they are parsed like a certificate file
+func (s *Scanner) readEndpoint(ctx context.Context, endpoint string) ([]byte, error) {
+	return probe.Fetch(ctx, endpoint, s.config.EndpointTimeout)
+}
+
+// certSource returns whether the certificate at path was read from a file
+// or fetched from a network endpoint
+func certSource(path string) string {
+	if _, ok := probe.Endpoint(path); ok {
+		return sourceNetwork
+	}
+	return sourceFile
+}
+
+// updateEndpointSNIMetrics reports, for each endpoint certificate of the
+// last scan, the common name presented and whether the certificate is valid
+// for the server name requested through SNI
+func (s *Scanner) updateEndpointSNIMetrics(certInfos []*CertificateInfo) {
+	s.metrics.ResetEndpointSNIMatches()
+	for _, certInfo := range certInfos {
+		endpoint, ok := probe.Endpoint(certInfo.Path)
+		if !ok {
+			continue
+		}
+		address, serverName, err := probe.ParseEndpoint(endpoint)
+		if err != nil {
+			continue
+		}
+
+		matched := matchesServerName(certInfo, serverName)
+		if !matched {
+			s.logger.Warn("Endpoint presented a certificate not valid for the requested server name",
+				zap.String("endpoint", address),
+				zap.String("server_name", serverName),
+				zap.String("common_name", certInfo.CommonName()))
+		}
+		s.metrics.SetEndpointSNIMatch(address, serverName, certInfo.CommonName(), matched)
+	}
+}
+
+// matchesServerName reports whether a certificate's SANs cover serverName,
+// a host name (wildcards allowed) or IP address
+func matchesServerName(certInfo *CertificateInfo, serverName string) bool {
+	c := &x509.Certificate{DNSNames: certInfo.DNSNames}
+	for _, ip := range certInfo.IPAddresses {
+		if parsed := net.ParseIP(ip); parsed != nil {
+			c.IPAddresses = append(c.IPAddresses, parsed)
+		}
+	}
+	return c.VerifyHostname(serverName) == nil
+}
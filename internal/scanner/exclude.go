@@ -0,0 +1,30 @@
+// internal/scanner/exclude.go
+
+package scanner
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// isExcludedPath reports whether path is, or lies below, one of excludes.
+// Relative entries are taken relative to root, the certificate directory
+// path was found in. Paths are compared whole component by component, so
+// /certs/staging doesn't exclude /certs/staging-old.
+func isExcludedPath(excludes []string, root, path string) bool {
+	for _, excluded := range excludes {
+		if !filepath.IsAbs(excluded) {
+			excluded = filepath.Join(root, excluded)
+		}
+		if path == excluded || strings.HasPrefix(path, excluded+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isExcluded reports whether a path under a certificate directory is excluded
+// by exclude_paths
+func (s *Scanner) isExcluded(root, path string) bool {
+	return isExcludedPath(s.config.ExcludePaths, root, path)
+}
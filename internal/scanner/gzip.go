@@ -0,0 +1,66 @@
+// internal/scanner/gzip.go
+
+package scanner
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// gzipSuffix marks gzip-compressed certificate files, e.g. cert.pem.gz
+const gzipSuffix = ".gz"
+
+// isGzipFile reports whether a file is gzip-compressed by its name
+func isGzipFile(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), gzipSuffix)
+}
+
+// uncompressedName returns the path without a trailing .gz, so a compressed
+// file is classified by its real extension
+func uncompressedName(path string) string {
+	if isGzipFile(path) {
+		return path[:len(path)-len(gzipSuffix)]
+	}
+	return path
+}
+
+// decompressCertificate decompresses a gzip-compressed certificate file. The
+// decompressed size is held to MaxCertFileSize so a small archive can't expand
+// into a huge buffer. Returns nil data if the file was skipped.
+func (s *Scanner) decompressCertificate(path string, data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress certificate: %w", err)
+	}
+	defer reader.Close()
+
+	maxSize := s.config.MaxCertFileSize
+	if maxSize <= 0 {
+		decompressed, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress certificate: %w", err)
+		}
+		return decompressed, nil
+	}
+
+	// Read one byte past the limit to detect oversized content
+	decompressed, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress certificate: %w", err)
+	}
+
+	if int64(len(decompressed)) > maxSize {
+		s.logger.Warn("Skipping certificate file that decompresses past the maximum file size",
+			zap.String("path", path),
+			zap.Int64("max_size", maxSize))
+		s.metrics.IncOversizedFiles()
+		return nil, nil
+	}
+
+	return decompressed, nil
+}
@@ -0,0 +1,47 @@
+// internal/scanner/include.go
+
+package scanner
+
+import (
+	"regexp"
+)
+
+// cnFilter holds the compiled include_cn_patterns
+type cnFilter []*regexp.Regexp
+
+// newCNFilter compiles the patterns limiting per-certificate metrics.
+// Invalid patterns, already reported by config validation, are dropped.
+func newCNFilter(patterns []string) *cnFilter {
+	filter := make(cnFilter, 0, len(patterns))
+	for _, pattern := range patterns {
+		if re, err := regexp.Compile(pattern); err == nil {
+			filter = append(filter, re)
+		}
+	}
+	return &filter
+}
+
+// matches reports whether the common name or a SAN of a certificate matches
+// one of the patterns. An empty filter matches everything.
+func (f *cnFilter) matches(certInfo *CertificateInfo) bool {
+	if f == nil || len(*f) == 0 {
+		return true
+	}
+
+	names := append([]string{certInfo.CommonName()}, certInfo.DNSNames...)
+	names = append(names, certInfo.IPAddresses...)
+	for _, re := range *f {
+		for _, name := range names {
+			if name != "" && re.MatchString(name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// exportsMetrics reports whether per-certificate metrics are exported for a
+// certificate, as selected by include_cn_patterns
+func (s *Scanner) exportsMetrics(certInfo *CertificateInfo) bool {
+	return s.includeCN.Load().matches(certInfo)
+}
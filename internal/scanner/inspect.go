@@ -0,0 +1,104 @@
+// internal/scanner/inspect.go
+
+package scanner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/config"
+	"go.uber.org/zap"
+)
+
+// Inspection is the report for a single certificate checked outside a scan
+type Inspection struct {
+	Name               string    `json:"name"`
+	Subject            string    `json:"subject"`
+	CommonName         string    `json:"common_name"`
+	Issuer             string    `json:"issuer"`
+	IssuerCode         int       `json:"issuer_code"`
+	SerialNumber       string    `json:"serial_number"`
+	Fingerprint        string    `json:"fingerprint"`
+	SPKISHA256         string    `json:"spki_sha256"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	DaysRemaining      int       `json:"days_remaining"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	KeySize            int       `json:"key_size"`
+	DNSNames           []string  `json:"dns_names"`
+	IPAddresses        []string  `json:"ip_addresses"`
+	ChainDepth         int       `json:"chain_depth"`
+	IsSelfSigned       bool      `json:"is_self_signed"`
+	Problems           []string  `json:"problems"`
+}
+
+// Inspect parses certificate data that didn't come from a monitored
+// directory, such as stdin, and checks it against the configured expiry,
+// key and signature algorithm rules. Nothing is cached or exported.
+func Inspect(cfg *config.Config, logger *zap.Logger, name string, data []byte) (*Inspection, error) {
+	s := &Scanner{config: cfg, logger: logger}
+	if err := s.loadIssuerBundle(cfg); err != nil {
+		return nil, err
+	}
+
+	certInfo, err := s.parseCertificate(name, data)
+	if err != nil {
+		return nil, err
+	}
+
+	inspection := &Inspection{
+		Name:               name,
+		Subject:            certInfo.Subject,
+		CommonName:         certInfo.CommonName(),
+		Issuer:             certInfo.Issuer,
+		IssuerCode:         s.issuerCode(certInfo),
+		SerialNumber:       certInfo.SerialNumber,
+		Fingerprint:        certInfo.Fingerprint,
+		SPKISHA256:         certInfo.SPKISHA256,
+		NotBefore:          certInfo.NotBefore,
+		NotAfter:           certInfo.NotAfter,
+		DaysRemaining:      int(time.Until(certInfo.NotAfter).Hours() / 24),
+		SignatureAlgorithm: certInfo.SignatureAlgorithm,
+		KeySize:            certInfo.KeySize,
+		DNSNames:           certInfo.DNSNames,
+		IPAddresses:        certInfo.IPAddresses,
+		ChainDepth:         certInfo.ChainDepth,
+		IsSelfSigned:       certInfo.IsSelfSigned,
+		Problems:           []string{},
+	}
+	if inspection.DNSNames == nil {
+		inspection.DNSNames = []string{}
+	}
+
+	// Same checks that feed the scan metrics
+	switch {
+	case certInfo.IsExpired:
+		inspection.Problems = append(inspection.Problems, "certificate has expired")
+	case s.IsExpiringSoon(certInfo):
+		inspection.Problems = append(inspection.Problems,
+			fmt.Sprintf("certificate expires within %s", cfg.ExpiryThreshold))
+	}
+	if certInfo.IsWeakKey {
+		inspection.Problems = append(inspection.Problems,
+			fmt.Sprintf("weak key: %s", strings.Join(certInfo.KeyWeaknesses, ", ")))
+	}
+	if certInfo.IsDeprecatedAlg {
+		inspection.Problems = append(inspection.Problems,
+			fmt.Sprintf("deprecated signature algorithm: %s", certInfo.SignatureAlgorithm))
+	}
+	if s.hasExcessiveValidity(certInfo) {
+		inspection.Problems = append(inspection.Problems,
+			fmt.Sprintf("validity period of %d days exceeds %d", certInfo.ValidityDays(), cfg.MaxValidityDays))
+	}
+	if cfg.IsCurveDeprecated(certInfo.Curve) {
+		inspection.Problems = append(inspection.Problems,
+			fmt.Sprintf("deprecated curve: %s", certInfo.Curve))
+	}
+	if !cfg.IsSignatureAlgorithmAllowed(certInfo.SignatureAlgorithm) {
+		inspection.Problems = append(inspection.Problems,
+			fmt.Sprintf("signature algorithm not allowed: %s", certInfo.SignatureAlgorithm))
+	}
+
+	return inspection, nil
+}
@@ -0,0 +1,66 @@
+// internal/scanner/inventory.go
+
+package scanner
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/fileutil"
+)
+
+// inventoryHeader lists the columns of the certificate inventory CSV
+var inventoryHeader = []string{
+	"cn", "issuer", "not_before", "not_after", "days_remaining", "sans", "fingerprint", "filepath",
+}
+
+// writeInventoryCSV writes the certificate inventory to path. The file is
+// replaced atomically, so readers never see a partial file.
+func writeInventoryCSV(path string, certInfos []*CertificateInfo) error {
+	sorted := make([]*CertificateInfo, len(certInfos))
+	copy(sorted, certInfos)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(inventoryHeader); err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+
+	now := time.Now()
+	for _, certInfo := range sorted {
+		sans := append(append([]string{}, certInfo.DNSNames...), certInfo.IPAddresses...)
+		record := []string{
+			extractCommonName(certInfo.Subject),
+			certInfo.Issuer,
+			certInfo.NotBefore.UTC().Format(time.RFC3339),
+			certInfo.NotAfter.UTC().Format(time.RFC3339),
+			strconv.Itoa(int(certInfo.NotAfter.Sub(now).Hours() / 24)),
+			strings.Join(sans, ";"),
+			certInfo.Fingerprint,
+			certInfo.Path,
+		}
+		if err := w.Write(record); err != nil {
+			return fmt.Errorf("failed to write inventory: %w", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+
+	// The inventory is meant to be shared
+	if err := fileutil.WriteAtomic(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to replace inventory file: %w", err)
+	}
+
+	return nil
+}
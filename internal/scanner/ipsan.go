@@ -0,0 +1,75 @@
+// internal/scanner/ipsan.go
+
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/cert"
+	"go.uber.org/zap"
+)
+
+// withIPSANValidation returns a copy of certInfo with IPSANMismatches filled in
+// from reverse DNS lookups. The cached certificate info is shared and never
+// modified, since lookup results describe the network rather than the file.
+func (s *Scanner) withIPSANValidation(ctx context.Context, certInfo *CertificateInfo) *CertificateInfo {
+	if !s.config.ValidateIPSANs || len(certInfo.IPAddresses) == 0 {
+		return certInfo
+	}
+
+	validated := *certInfo
+	validated.IPSANMismatches = s.ipSANMismatches(ctx, certInfo)
+	return &validated
+}
+
+// ipSANMismatches returns the IP SANs whose reverse DNS names match none of the
+// certificate's DNS SANs. IPs without a PTR record count as mismatches; lookups
+// that fail for other reasons are logged and skipped.
+func (s *Scanner) ipSANMismatches(ctx context.Context, certInfo *CertificateInfo) []string {
+	var mismatches []string
+
+	for _, ip := range certInfo.IPAddresses {
+		// Lookups share the network limiter
+		select {
+		case s.networkLimiter <- struct{}{}:
+		case <-ctx.Done():
+			return mismatches
+		}
+		names, err := net.DefaultResolver.LookupAddr(ctx, ip)
+		<-s.networkLimiter
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return mismatches
+			}
+			var dnsErr *net.DNSError
+			if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+				s.logger.Warn("Reverse lookup failed for IP SAN",
+					zap.String("path", certInfo.Path),
+					zap.String("ip", ip),
+					zap.Error(err))
+				continue
+			}
+		}
+
+		matched := false
+		for _, name := range names {
+			if len(cert.MatchingDNSNames(certInfo.DNSNames, name)) > 0 {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			s.logger.Debug("IP SAN does not resolve to a DNS SAN",
+				zap.String("path", certInfo.Path),
+				zap.String("ip", ip),
+				zap.Strings("names", names))
+			mismatches = append(mismatches, ip)
+		}
+	}
+
+	return mismatches
+}
@@ -0,0 +1,86 @@
+// internal/scanner/issuer_bundle.go
+
+package scanner
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// bundleIssuerCodeBase is the issuer code of the first CA in the bundle. Each
+// following CA gets the next code, so codes stay stable as long as new CAs are
+// appended to the bundle.
+const bundleIssuerCodeBase = 100
+
+// issuerBundle classifies issuers against a CA bundle by key identifier and
+// subject
+type issuerBundle struct {
+	byKeyID   map[string]int
+	bySubject map[string]int
+}
+
+// loadIssuerBundle reads the CERTIFICATE blocks of a PEM CA bundle
+func loadIssuerBundle(path string) (*issuerBundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	bundle := &issuerBundle{
+		byKeyID:   make(map[string]int),
+		bySubject: make(map[string]int),
+	}
+
+	code := bundleIssuerCodeBase
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		ca, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CA bundle certificate %d: %w", code-bundleIssuerCodeBase+1, err)
+		}
+
+		// The first CA wins when several share a key or subject
+		if len(ca.SubjectKeyId) > 0 {
+			keyID := hex.EncodeToString(ca.SubjectKeyId)
+			if _, exists := bundle.byKeyID[keyID]; !exists {
+				bundle.byKeyID[keyID] = code
+			}
+		}
+		subject := ca.Subject.String()
+		if _, exists := bundle.bySubject[subject]; !exists {
+			bundle.bySubject[subject] = code
+		}
+		code++
+	}
+
+	if code == bundleIssuerCodeBase {
+		return nil, fmt.Errorf("CA bundle %s contains no certificates", path)
+	}
+
+	return bundle, nil
+}
+
+// classify returns the code of the bundle CA that issued a certificate,
+// preferring the authority key identifier over the issuer DN
+func (b *issuerBundle) classify(certInfo *CertificateInfo) (int, bool) {
+	if certInfo.AuthorityKeyID != "" {
+		if code, ok := b.byKeyID[certInfo.AuthorityKeyID]; ok {
+			return code, true
+		}
+	}
+
+	code, ok := b.bySubject[certInfo.Issuer]
+	return code, ok
+}
@@ -0,0 +1,56 @@
+// internal/scanner/k8s.go
+
+package scanner
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// k8sSecret is the subset of a Kubernetes Secret manifest we care about
+type k8sSecret struct {
+	Data       map[string]string `yaml:"data"`
+	StringData map[string]string `yaml:"stringData"`
+}
+
+// isSecretManifest checks if a file is a YAML manifest that may hold a TLS secret
+func isSecretManifest(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// extractSecretCertificate extracts the tls.crt value from a Kubernetes secret manifest.
+// Returns false if no document in the manifest carries a tls.crt entry.
+func extractSecretCertificate(data []byte) ([]byte, bool, error) {
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+
+	for {
+		var secret k8sSecret
+		if err := decoder.Decode(&secret); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, false, nil
+			}
+			return nil, false, fmt.Errorf("failed to parse secret manifest: %w", err)
+		}
+
+		// stringData holds the PEM as-is
+		if crt, ok := secret.StringData["tls.crt"]; ok && crt != "" {
+			return []byte(crt), true, nil
+		}
+
+		// data holds the PEM base64-encoded
+		if crt, ok := secret.Data["tls.crt"]; ok && crt != "" {
+			decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(crt), ""))
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to decode tls.crt: %w", err)
+			}
+			return decoded, true, nil
+		}
+	}
+}
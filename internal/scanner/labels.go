@@ -0,0 +1,56 @@
+// internal/scanner/labels.go
+
+package scanner
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/config"
+)
+
+// labelName returns the common_name label value of a certificate, taken from
+// the source chosen by primary_identifier and falling back to the other
+func (s *Scanner) labelName(certInfo *CertificateInfo) string {
+	if strings.EqualFold(s.config.PrimaryIdentifier, config.IdentifierFirstSAN) {
+		if san := certInfo.FirstSAN(); san != "" {
+			return san
+		}
+	}
+	return certInfo.CommonName()
+}
+
+// sanitizeLabelValue makes certificate text safe to export as a Prometheus
+// label value. Prometheus rejects label values that aren't valid UTF-8, and
+// control characters garble dashboards, so invalid bytes are escaped as \xNN
+// and non-printable characters as \uNNNN. Leading and trailing whitespace is
+// trimmed.
+func sanitizeLabelValue(value string) string {
+	clean := true
+	for _, r := range value {
+		if r == utf8.RuneError || !unicode.IsPrint(r) {
+			clean = false
+			break
+		}
+	}
+	if clean {
+		return strings.TrimSpace(value)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); {
+		r, size := utf8.DecodeRuneInString(value[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, `\x%02x`, value[i])
+		case !unicode.IsPrint(r):
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+		i += size
+	}
+	return strings.TrimSpace(b.String())
+}
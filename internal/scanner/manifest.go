@@ -0,0 +1,73 @@
+// internal/scanner/manifest.go
+
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// manifestReloadDelay coalesces bursts of events on the manifest file
+const manifestReloadDelay = 500 * time.Millisecond
+
+// readManifest reads the certificate paths listed in a manifest file. The
+// manifest is either a JSON array of paths or plain text with one path per
+// line, where blank lines and lines starting with # are ignored. Relative
+// paths are resolved against the manifest's directory.
+func readManifest(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var entries []string
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+	} else {
+		lines := bufio.NewScanner(bytes.NewReader(data))
+		for lines.Scan() {
+			line := strings.TrimSpace(lines.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			entries = append(entries, line)
+		}
+		if err := lines.Err(); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+	}
+
+	baseDir := filepath.Dir(path)
+	paths := make([]string, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !filepath.IsAbs(entry) {
+			entry = filepath.Join(baseDir, entry)
+		}
+		entry = filepath.Clean(entry)
+
+		if !seen[entry] {
+			seen[entry] = true
+			paths = append(paths, entry)
+		}
+	}
+
+	return paths, nil
+}
+
+// isManifestEvent reports whether a file event concerns the configured manifest
+func (s *Scanner) isManifestEvent(name string) bool {
+	return s.config.ManifestFile != "" && filepath.Clean(name) == s.config.ManifestFile
+}
@@ -0,0 +1,55 @@
+// internal/scanner/parseerrors.go
+
+package scanner
+
+import (
+	"errors"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/cert"
+)
+
+// Parse error reasons, the reason label of ssl_cert_parse_errors_by_reason
+const (
+	reasonReadError      = "read_error"
+	reasonDecompress     = "decompress_error"
+	reasonSecretManifest = "invalid_secret_manifest"
+	reasonNoPEMBlock     = "no_pem_block"
+	reasonNotCertificate = "not_certificate"
+	reasonX509Parse      = "x509_parse"
+)
+
+// parseErrorReasons lists every reason so each scan reports all of them,
+// including the ones that didn't occur
+var parseErrorReasons = []string{
+	reasonReadError,
+	reasonDecompress,
+	reasonSecretManifest,
+	reasonNoPEMBlock,
+	reasonNotCertificate,
+	reasonX509Parse,
+}
+
+// readError tags an error from reading a certificate file with its reason
+type readError struct {
+	reason string
+	err    error
+}
+
+func (e *readError) Error() string { return e.err.Error() }
+
+func (e *readError) Unwrap() error { return e.err }
+
+// parseErrorReason classifies an error returned by processCertificate
+func parseErrorReason(err error) string {
+	var re *readError
+	switch {
+	case errors.As(err, &re):
+		return re.reason
+	case errors.Is(err, cert.ErrNoPEMBlock):
+		return reasonNoPEMBlock
+	case errors.Is(err, cert.ErrNotCertificate):
+		return reasonNotCertificate
+	default:
+		return reasonX509Parse
+	}
+}
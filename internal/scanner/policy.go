@@ -0,0 +1,17 @@
+// internal/scanner/policy.go
+
+package scanner
+
+import "time"
+
+// violatedValidityPolicy returns the name of the validity policy in effect
+// now if the certificate's validity period exceeds its limit, or an empty
+// string. A certificate issued before a policy took effect is reported once
+// it does, as its renewal has to comply.
+func (s *Scanner) violatedValidityPolicy(certInfo *CertificateInfo) string {
+	policy, ok := s.config.ValidityPolicyAt(time.Now())
+	if !ok || certInfo.ValidityDays() <= policy.MaxValidityDays {
+		return ""
+	}
+	return policy.Name
+}
@@ -0,0 +1,35 @@
+// internal/scanner/prune.go
+
+package scanner
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// isLongExpired checks if a certificate expired more than
+// prune_expired_after ago
+func (s *Scanner) isLongExpired(certInfo *CertificateInfo) bool {
+	return s.config.PruneExpiredAfter > 0 && time.Since(certInfo.NotAfter) > s.config.PruneExpiredAfter
+}
+
+// pruneExpiredCache drops the cache entries of certificates expired for
+// longer than prune_expired_after, such as entries loaded from disk or cached
+// before they crossed the line. Such certificates are still scanned and
+// reported; they are just re-read instead of kept in memory.
+func (s *Scanner) pruneExpiredCache() {
+	if s.config.PruneExpiredAfter <= 0 {
+		return
+	}
+
+	pruned := s.cache.Prune(func(_ string, value interface{}) bool {
+		certInfo, ok := value.(*CertificateInfo)
+		return ok && s.isLongExpired(certInfo)
+	})
+	if pruned > 0 {
+		s.logger.Info("Pruned expired certificates from cache",
+			zap.Int("entries", pruned),
+			zap.Duration("prune_expired_after", s.config.PruneExpiredAfter))
+	}
+}
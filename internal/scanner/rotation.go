@@ -0,0 +1,31 @@
+// internal/scanner/rotation.go
+
+package scanner
+
+import (
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+// recordRotation logs and counts a certificate file whose certificate was
+// replaced, i.e. its fingerprint changed since the previous result for the
+// same path. New files and unchanged certificates are ignored.
+func (s *Scanner) recordRotation(previous, current *CertificateInfo) {
+	if previous == nil || previous.Fingerprint == current.Fingerprint {
+		return
+	}
+
+	commonName := s.labelName(current)
+	s.metrics.IncCertRotations(commonName, filepath.Base(current.Path))
+	s.logger.Info("Certificate rotated",
+		zap.String("event", "certificate_rotated"),
+		zap.String("path", current.Path),
+		zap.String("common_name", commonName),
+		zap.String("previous_fingerprint", previous.Fingerprint),
+		zap.String("fingerprint", current.Fingerprint),
+		zap.String("previous_serial", previous.SerialNumber),
+		zap.String("serial", current.SerialNumber),
+		zap.Time("previous_not_after", previous.NotAfter),
+		zap.Time("not_after", current.NotAfter))
+}
@@ -7,19 +7,30 @@ import (
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/gob"
 	"encoding/hex"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"math/rand"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/brandonhon/tls-cert-monitor/internal/cache"
+	"github.com/brandonhon/tls-cert-monitor/internal/cert"
 	"github.com/brandonhon/tls-cert-monitor/internal/config"
+	"github.com/brandonhon/tls-cert-monitor/internal/httpclient"
 	"github.com/brandonhon/tls-cert-monitor/internal/metrics"
+	"github.com/brandonhon/tls-cert-monitor/internal/probe"
+	"github.com/brandonhon/tls-cert-monitor/internal/source"
 	"github.com/fsnotify/fsnotify"
 	"go.uber.org/zap"
 )
@@ -34,29 +45,153 @@ type Scanner struct {
 	mu       sync.RWMutex
 	stopChan chan struct{}
 	wg       sync.WaitGroup
+
+	// Overlapping scan tracking
+	inFlight    atomic.Int32
+	scanStarted atomic.Int64
+
+	// Running scans, and a channel closed once none are left, for Wait
+	scansActive int
+	scansIdle   chan struct{}
+	scansMu     sync.Mutex
+
+	// Certificates found by the last scan, keyed by path
+	results   map[string]*CertificateInfo
+	resultsMu sync.RWMutex
+
+	// Bounds concurrent outbound network lookups
+	networkLimiter chan struct{}
+
+	// CA bundle used for issuer classification, if configured
+	issuers atomic.Pointer[issuerBundle]
+
+	// Certificates to export per-certificate metrics for
+	includeCN atomic.Pointer[cnFilter]
+
+	// Directories skipped by scans after yielding only parse errors
+	backoffs  map[string]*dirBackoff
+	backoffMu sync.Mutex
+
+	// When a certificate in each directory last changed
+	dirChanges   map[string]time.Time
+	dirChangesMu sync.Mutex
+
+	// Directories last found group or world writable, so the warning is
+	// logged when a directory's permissions change rather than every scan
+	insecureDirs   map[string]bool
+	insecureDirsMu sync.Mutex
+
+	// Weak crypto findings already sent to the webhook
+	weakCryptoNotified map[string]bool
+	weakCryptoMu       sync.Mutex
+
+	// Transport shared by outbound HTTP requests
+	transport http.RoundTripper
+
+	// Sources walked by a scan, replaced on a configuration reload, and the
+	// ETag of each object store certificate seen by the last scan
+	sources       atomic.Pointer[[]source.Source]
+	objectETags   map[string]string
+	objectETagsMu sync.Mutex
+
+	// Whether a canceled scan publishes what it found instead of keeping the
+	// previous results
+	partialResults atomic.Bool
 }
 
+// longScanThreshold is how long a scan may run before an overlapping
+// scan request is reported as a warning
+const longScanThreshold = 30 * time.Second
+
+// cancelGracePeriod is how long a canceled scan waits for its workers to
+// unwind before abandoning them
+const cancelGracePeriod = 10 * time.Second
+
+// ErrPartialScan is returned by a canceled scan that published the
+// certificates found before it was canceled
+var ErrPartialScan = errors.New("scan canceled, partial results published")
+
 // CertificateInfo contains certificate details
 type CertificateInfo struct {
 	Path               string
 	Subject            string
 	Issuer             string
+	AuthorityKeyID     string
 	SerialNumber       string
 	NotBefore          time.Time
 	NotAfter           time.Time
 	SignatureAlgorithm string
 	KeySize            int
 	IsWeakKey          bool
+	KeyWeaknesses      []string
+	Curve              string
+	BaseDomain         string
+	IPSANMismatches    []string
 	IsExpired          bool
 	IsDeprecatedAlg    bool
+	IsSelfSigned       bool
+	HasSCT             bool
+	KeyUsages          []string
+	ExtKeyUsages       []string
 	SANCount           int
+	ChainDepth         int
+	ChainTypes         []string
+	DNSNames           []string
+	IPAddresses        []string
 	Fingerprint        string
+	SPKISHA256         string
+	Format             string
+	Organization       string
+}
+
+// ValidityDays returns the length of the certificate's validity period in
+// whole days
+func (c *CertificateInfo) ValidityDays() int {
+	return int(c.NotAfter.Sub(c.NotBefore).Hours() / 24)
+}
+
+// CommonName returns the subject common name, made safe for use as a metric
+// label. Certificates without one, such as SAN-only certificates, are named
+// by their first DNS or IP SAN, or "unknown" if they have neither.
+func (c *CertificateInfo) CommonName() string {
+	if name := c.PrimaryName(); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// PrimaryName returns the name the certificate is known by: the subject
+// common name, or else its first SAN, made safe for use as a metric label.
+// Empty if it has none of them.
+func (c *CertificateInfo) PrimaryName() string {
+	if commonName := sanitizeLabelValue(extractCommonName(c.Subject)); commonName != "" {
+		return commonName
+	}
+	return c.FirstSAN()
+}
+
+// FirstSAN returns the first DNS SAN, or the first IP SAN without DNS SANs,
+// made safe for use as a metric label. Empty if there is neither.
+func (c *CertificateInfo) FirstSAN() string {
+	if len(c.DNSNames) > 0 {
+		return sanitizeLabelValue(c.DNSNames[0])
+	}
+	if len(c.IPAddresses) > 0 {
+		return c.IPAddresses[0]
+	}
+	return ""
+}
+
+func init() {
+	// Cached certificate info is stored as an interface value and must be
+	// registered for the cache to be persisted
+	gob.Register(&CertificateInfo{})
 }
 
 // New creates a new certificate scanner
 func New(cfg *config.Config, metrics *metrics.Collector, logger *zap.Logger) (*Scanner, error) {
 	// Initialize cache
-	cacheInstance, err := cache.New(cfg.CacheDir, cfg.CacheTTL, cfg.CacheMaxSize)
+	cacheInstance, err := cache.NewWithSaveInterval(cfg.CacheDir, cfg.CacheTTL, cfg.CacheMaxSize, cfg.CacheSaveInterval)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize cache: %w", err)
 	}
@@ -67,13 +202,47 @@ func New(cfg *config.Config, metrics *metrics.Collector, logger *zap.Logger) (*S
 		return nil, fmt.Errorf("failed to create file watcher: %w", err)
 	}
 
+	transport, err := httpclient.NewTransport(httpOptions(cfg))
+	if err != nil {
+		watcher.Close()
+		cacheInstance.Close()
+		return nil, err
+	}
+
+	networkConcurrency := cfg.NetworkConcurrency
+	if networkConcurrency < 1 {
+		networkConcurrency = 1
+	}
+
 	s := &Scanner{
-		config:   cfg,
-		metrics:  metrics,
-		logger:   logger,
-		cache:    cacheInstance,
-		watcher:  watcher,
-		stopChan: make(chan struct{}),
+		config:             cfg,
+		metrics:            metrics,
+		logger:             logger,
+		cache:              cacheInstance,
+		watcher:            watcher,
+		stopChan:           make(chan struct{}),
+		results:            make(map[string]*CertificateInfo),
+		dirChanges:         make(map[string]time.Time),
+		insecureDirs:       make(map[string]bool),
+		backoffs:           make(map[string]*dirBackoff),
+		weakCryptoNotified: make(map[string]bool),
+		networkLimiter:     make(chan struct{}, networkConcurrency),
+		transport:          transport,
+		objectETags:        make(map[string]string),
+	}
+	sources := newSources(cfg, transport)
+	s.sources.Store(&sources)
+
+	if err := s.loadIssuerBundle(cfg); err != nil {
+		watcher.Close()
+		cacheInstance.Close()
+		return nil, err
+	}
+	s.includeCN.Store(newCNFilter(cfg.IncludeCNPatterns))
+
+	// Serve per-certificate metrics from the last scan results on each scrape
+	if cfg.CollectorMode {
+		metrics.SetCertificateSource(s.CertificateSnapshots)
 	}
 
 	return s, nil
@@ -84,128 +253,439 @@ func (s *Scanner) Scan(ctx context.Context) error {
 	s.logger.Info("Starting certificate scan")
 	startTime := time.Now()
 
-	// MOVED: Reset certificate metrics BEFORE starting workers to avoid race condition
-	// This ensures we start with a clean slate
-	s.metrics.ResetCertificateMetrics()
+	// Warn when a new scan arrives while a long-running one is still busy
+	if running := s.inFlight.Load(); running > 0 {
+		runningFor := time.Since(time.Unix(0, s.scanStarted.Load()))
+		if runningFor > longScanThreshold {
+			s.logger.Warn("Scan requested while a previous scan is still running",
+				zap.Int32("in_flight", running),
+				zap.Duration("running_for", runningFor))
+		}
+	}
+
+	// Track in-flight scans
+	s.beginScan()
+	defer s.endScan()
+	if s.inFlight.Add(1) == 1 {
+		s.scanStarted.Store(startTime.UnixNano())
+	}
+	s.metrics.IncScansInFlight()
+	defer func() {
+		s.inFlight.Add(-1)
+		s.metrics.DecScansInFlight()
+	}()
+
+	// Read the file list up front so a broken manifest leaves the previous
+	// results in place
+	var manifestPaths []string
+	if s.config.ManifestFile != "" {
+		paths, err := readManifest(s.config.ManifestFile)
+		if err != nil {
+			s.logger.Error("Failed to read certificate manifest",
+				zap.String("manifest", s.config.ManifestFile),
+				zap.Error(err))
+			return err
+		}
+		manifestPaths = paths
+	}
+
+	// Abandon scans that outlast scan_timeout
+	parent := ctx
+	if s.config.ScanTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.ScanTimeout)
+		defer cancel()
+	}
+
+	collectorMode := s.config.CollectorMode
+	backoffOnParseErrors := s.config.BackoffOnParseErrors
+
+	// Directories walked by this scan, for the parse error backoff
+	var scannedDirs []string
 
 	var (
-		totalFiles     int
-		parsedCerts    int
-		parseErrors    int
-		weakKeys       int
-		deprecatedAlgs int
-		duplicates     = make(map[string]int)
-		certsMu        sync.Mutex
-		wg             sync.WaitGroup
-		semaphore      = make(chan struct{}, s.config.Workers)
+		totalFiles          int
+		parsedCerts         int
+		parseErrors         int
+		parseErrorsByReason = make(map[string]int)
+		weakKeys            int
+		deprecatedAlgs      int
+		disallowedAlgs      int
+		duplicates          = make(map[string]int)
+		parsedByDir         = make(map[string]int)
+		parseErrorsByDir    = make(map[string]int)
+		certsMu             sync.Mutex
+		wg                  sync.WaitGroup
+		semaphore           = make(chan struct{}, s.config.Workers)
 	)
 
 	// Collect all certificate info for later metric updates
 	var allCertInfos []*CertificateInfo
 	var certInfosMu sync.Mutex
 
-	// Scan each configured directory
-	for _, dir := range s.config.CertificateDirectories {
-		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				s.logger.Warn("Error accessing path", zap.String("path", path), zap.Error(err))
-				return nil
+	// Paths skipped for permission errors, reported once per scan, and the
+	// newest certificate modification time per directory; a parallel walk
+	// updates both, and scannedDirs, concurrently
+	permissionDenied := make(map[string]bool)
+	dirModTimes := make(map[string]time.Time)
+	var walkMu sync.Mutex
+	trackModTime := func(dir string, modTime time.Time) {
+		walkMu.Lock()
+		defer walkMu.Unlock()
+		if modTime.After(dirModTimes[dir]) {
+			dirModTimes[dir] = modTime
+		}
+	}
+
+	// Process certificate in worker pool
+	scanFile := func(path string) {
+		wg.Add(1)
+		go func(certPath string) {
+			defer wg.Done()
+
+			// Acquire semaphore
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			// Check context cancellation
+			select {
+			case <-ctx.Done():
+				return
+			default:
 			}
 
-			// Skip directories
-			if d.IsDir() {
-				return nil
+			certsMu.Lock()
+			totalFiles++
+			certsMu.Unlock()
+
+			// Process certificate
+			if certInfo, err := s.processCertificate(ctx, certPath); err != nil {
+				reason := parseErrorReason(err)
+				s.logger.Error("Failed to process certificate",
+					zap.String("path", certPath),
+					zap.String("reason", reason),
+					zap.Error(err))
+				certsMu.Lock()
+				parseErrors++
+				parseErrorsByReason[reason]++
+				parseErrorsByDir[s.directoryFor(certPath)]++
+				certsMu.Unlock()
+			} else if certInfo != nil {
+				certInfo = s.withIPSANValidation(ctx, certInfo)
+
+				certsMu.Lock()
+				parsedCerts++
+				parsedByDir[s.directoryFor(certPath)]++
+
+				// Track duplicates
+				duplicates[certInfo.Fingerprint]++
+
+				// Track weak keys
+				if certInfo.IsWeakKey {
+					weakKeys++
+				}
+
+				// Track deprecated algorithms
+				if certInfo.IsDeprecatedAlg {
+					deprecatedAlgs++
+				}
+
+				// Track algorithms outside the allow-list
+				if !s.config.IsSignatureAlgorithmAllowed(certInfo.SignatureAlgorithm) {
+					s.logger.Debug("Signature algorithm not allowed",
+						zap.String("path", certInfo.Path),
+						zap.String("signature_algorithm", certInfo.SignatureAlgorithm))
+					disallowedAlgs++
+				}
+				certsMu.Unlock()
+
+				// Store certificate info for later metric updates
+				certInfosMu.Lock()
+				allCertInfos = append(allCertInfos, certInfo)
+				certInfosMu.Unlock()
 			}
+		}(path)
+	}
 
-			// Check if file is a certificate (this now excludes private keys)
-			if !s.isCertificateFile(path) {
-				return nil
+	// Walk and process in the background so a scan stuck on unresponsive
+	// storage can be abandoned; system calls don't observe the context
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		// A manifest replaces the directory walk with an explicit file list;
+		// its sources leave the directories out
+		if s.config.ManifestFile != "" {
+			for _, path := range manifestPaths {
+				if ctx.Err() != nil {
+					break
+				}
+				if info, err := os.Stat(path); err == nil {
+					trackModTime(s.directoryFor(path), info.ModTime())
+				}
+				scanFile(path)
 			}
+		}
 
-			// Process certificate in worker pool
-			wg.Add(1)
-			go func(certPath string) {
-				defer wg.Done()
+		// ETags of the object store certificates seen by this scan
+		objectETags := make(map[string]string)
 
-				// Acquire semaphore
-				semaphore <- struct{}{}
-				defer func() { <-semaphore }()
+		// Walk each source: a certificate directory, or the object store
+		scanSource := func(src source.Source) {
+			dir := src.Root()
+			if _, local := src.(*source.Filesystem); local {
+				s.checkDirPermissions(dir)
+			}
 
-				// Check context cancellation
-				select {
-				case <-ctx.Done():
-					return
-				default:
+			if backoffOnParseErrors && s.skipForBackoff(dir) {
+				s.logger.Debug("Skipping directory backing off after parse errors", zap.String("dir", dir))
+				return
+			}
+			walkMu.Lock()
+			scannedDirs = append(scannedDirs, dir)
+			walkMu.Unlock()
+
+			err := src.Walk(ctx, func(object source.Object, err error) error {
+				// Stop walking on shutdown instead of finishing a large tree
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return ctxErr
 				}
 
-				certsMu.Lock()
-				totalFiles++
-				certsMu.Unlock()
+				path := src.URL(object.Key)
+				if err != nil {
+					if errors.Is(err, fs.ErrPermission) {
+						walkMu.Lock()
+						reported := permissionDenied[path]
+						permissionDenied[path] = true
+						walkMu.Unlock()
+						if !reported {
+							s.metrics.IncWalkPermissionErrors(dir)
+							s.logger.Warn("Permission denied, skipping path", zap.String("path", path), zap.Error(err))
+						}
+						return nil
+					}
+					s.logger.Warn("Error accessing path", zap.String("path", path), zap.Error(err))
+					return nil
+				}
 
-				// Process certificate
-				if certInfo, err := s.processCertificate(certPath); err != nil {
-					s.logger.Error("Failed to process certificate",
-						zap.String("path", certPath),
-						zap.Error(err))
-					certsMu.Lock()
-					parseErrors++
-					certsMu.Unlock()
-				} else if certInfo != nil {
-					certsMu.Lock()
-					parsedCerts++
-
-					// Track duplicates
-					duplicates[certInfo.Fingerprint]++
-
-					// Track weak keys
-					if certInfo.IsWeakKey {
-						weakKeys++
+				// Leave out excluded paths and everything below them
+				if s.isExcluded(dir, path) {
+					if object.Dir {
+						return fs.SkipDir
 					}
+					return nil
+				}
 
-					// Track deprecated algorithms
-					if certInfo.IsDeprecatedAlg {
-						deprecatedAlgs++
+				// Skip directories, without descending past max_depth
+				if object.Dir {
+					if s.config.MaxDepth > 0 && walkDepth(dir, path) >= s.config.MaxDepth {
+						return fs.SkipDir
 					}
-					certsMu.Unlock()
+					return nil
+				}
 
-					// Store certificate info for later metric updates
-					certInfosMu.Lock()
-					allCertInfos = append(allCertInfos, certInfo)
-					certInfosMu.Unlock()
+				// Check if file is a certificate (this now excludes private keys)
+				if !s.isCertificateFile(path) {
+					return nil
 				}
-			}(path)
 
-			return nil
-		})
+				if modTime, ok := object.ModTime(); ok {
+					trackModTime(dir, modTime)
+				}
 
-		if err != nil {
-			s.logger.Error("Failed to scan directory", zap.String("dir", dir), zap.Error(err))
+				// Object stores produce no file events; an object replaced in
+				// place is noticed by its ETag
+				if object.ETag != "" {
+					if s.objectChanged(path, object.ETag) {
+						s.cache.Delete(path)
+					}
+					walkMu.Lock()
+					objectETags[path] = object.ETag
+					walkMu.Unlock()
+				}
+
+				scanFile(path)
+				return nil
+			})
+
+			if err != nil && ctx.Err() == nil {
+				s.logger.Error("Failed to scan directory", zap.String("dir", dir), zap.Error(err))
+			}
+		}
+
+		// Walk up to max_concurrent_dirs sources at once, independently of
+		// the parse workers, so directories sharing a disk don't thrash it
+		dirSemaphore := make(chan struct{}, max(s.config.MaxConcurrentDirs, 1))
+		var dirWg sync.WaitGroup
+		for _, src := range s.currentSources() {
+			if ctx.Err() != nil {
+				break
+			}
+			dirSemaphore <- struct{}{}
+			dirWg.Add(1)
+			go func(src source.Source) {
+				defer dirWg.Done()
+				defer func() { <-dirSemaphore }()
+				scanSource(src)
+			}(src)
+		}
+		dirWg.Wait()
+
+		s.objectETagsMu.Lock()
+		s.objectETags = objectETags
+		s.objectETagsMu.Unlock()
+
+		// Fetch the certificates presented by network endpoints
+		s.scanEndpoints(ctx, scanFile)
+
+		// Wait for all workers to complete
+		wg.Wait()
+	}()
+
+	walked := false
+	select {
+	case <-done:
+		walked = true
+	case <-ctx.Done():
+		// A walk stuck past scan_timeout is abandoned at once; a canceled
+		// scan gives its workers a grace period to unwind so Wait covers
+		// them before Close, without hanging shutdown on stuck storage
+		if parent.Err() != nil {
+			select {
+			case <-done:
+				walked = true
+			case <-time.After(cancelGracePeriod):
+				s.logger.Warn("Canceled scan workers still busy, abandoning them",
+					zap.Duration("grace_period", cancelGracePeriod))
+			}
+		}
+	}
+
+	// A canceled or timed out scan saw only part of the files; keep the
+	// previous results unless partial results were asked for
+	partial := false
+	if err := ctx.Err(); err != nil {
+		certsMu.Lock()
+		scanned := totalFiles
+		certsMu.Unlock()
+
+		if parent.Err() == nil {
+			s.metrics.IncScanTimeouts()
+			s.logger.Warn("Certificate scan timed out, abandoning it",
+				zap.Int("total_files", scanned),
+				zap.Duration("scan_timeout", s.config.ScanTimeout))
+			return fmt.Errorf("scan timed out after %s: %w", s.config.ScanTimeout, err)
 		}
+
+		if !walked || !s.partialResults.Load() {
+			s.logger.Info("Certificate scan canceled",
+				zap.Int("total_files", scanned),
+				zap.Duration("duration", time.Since(startTime)))
+			return fmt.Errorf("scan canceled: %w", err)
+		}
+
+		s.logger.Info("Certificate scan canceled, publishing partial results",
+			zap.Int("total_files", scanned),
+			zap.Duration("duration", time.Since(startTime)))
+		partial = true
 	}
 
-	// Wait for all workers to complete
-	wg.Wait()
+	for dir, modTime := range dirModTimes {
+		s.recordDirChange(dir, modTime)
+	}
+
+	// A partial scan says nothing about the directories it didn't finish
+	if backoffOnParseErrors && !partial {
+		s.updateBackoffs(scannedDirs, parsedByDir, parseErrorsByDir)
+	}
 
-	// NOW update all certificate-specific metrics AFTER all workers are done
-	// This ensures no race condition with ResetCertificateMetrics
-	s.logger.Debug("Updating certificate-specific metrics", zap.Int("certificates", len(allCertInfos)))
+	// Publish the results in one step so scrapes never see a partial scan
+	results := make(map[string]*CertificateInfo, len(allCertInfos))
 	for _, certInfo := range allCertInfos {
-		s.updateMetrics(certInfo)
+		results[certInfo.Path] = certInfo
+	}
+	s.resultsMu.Lock()
+	previous := s.results
+	s.results = results
+	s.resultsMu.Unlock()
+	s.metrics.SetMonitoredFiles(float64(len(results)))
+	s.updateWatchedDirsMetric()
+	s.metrics.SetCertTypeCounts(s.certTypeCounts(allCertInfos))
+	s.updateEndpointSNIMetrics(allCertInfos)
+
+	for path, certInfo := range results {
+		s.recordRotation(previous[path], certInfo)
+	}
+
+	s.pruneExpiredCache()
+
+	// Persist what this scan parsed; the periodic save skips an unchanged cache
+	if err := s.cache.Save(); err != nil {
+		s.logger.Warn("Failed to save cache", zap.Error(err))
+	}
+
+	// Replace the certificate-specific metrics once all workers are done.
+	// Resetting only now leaves the previous metrics in place when a scan is
+	// canceled or abandoned.
+	if !collectorMode {
+		s.metrics.ResetCertificateMetrics()
+		s.logger.Debug("Updating certificate-specific metrics", zap.Int("certificates", len(allCertInfos)))
+		for _, certInfo := range allCertInfos {
+			if s.exportsMetrics(certInfo) {
+				s.updateMetrics(certInfo)
+
+				// Observed once per scan, not again for watcher updates,
+				// so the histogram counts each certificate once
+				s.metrics.ObserveCertExpiryDays(time.Until(certInfo.NotAfter).Hours() / 24)
+			}
+		}
 	}
 
 	// Update operational metrics
 	s.metrics.SetCertFilesTotal(float64(totalFiles))
 	s.metrics.SetCertsParsedTotal(float64(parsedCerts))
 	s.metrics.SetCertParseErrorsTotal(float64(parseErrors))
+	for _, reason := range parseErrorReasons {
+		s.metrics.SetCertParseErrors(reason, float64(parseErrorsByReason[reason]))
+	}
 	s.metrics.SetWeakKeyTotal(float64(weakKeys))
 	s.metrics.SetDeprecatedSigAlgTotal(float64(deprecatedAlgs))
-	s.metrics.SetScanDuration(time.Since(startTime).Seconds())
+	s.metrics.SetDisallowedSigAlgTotal(float64(disallowedAlgs))
+	scanDuration := time.Since(startTime)
+	s.metrics.SetScanDuration(scanDuration.Seconds())
 	s.metrics.SetLastScanTimestamp(float64(time.Now().Unix()))
 
+	// A scan outlasting the interval means the monitor is always scanning
+	degraded := scanDuration > s.config.ScanInterval
+	s.metrics.SetDegraded(degraded)
+	if degraded {
+		s.logger.Warn("Scan took longer than the scan interval; consider more workers or fewer directories",
+			zap.Duration("duration", scanDuration),
+			zap.Duration("scan_interval", s.config.ScanInterval),
+			zap.Int("workers", s.config.Workers))
+	}
+
 	// Update duplicate metrics
-	for fingerprint, count := range duplicates {
-		if count > 1 {
-			s.metrics.SetCertDuplicateCount(fingerprint, float64(count))
+	if !collectorMode {
+		for fingerprint, count := range duplicates {
+			if count > 1 {
+				s.metrics.SetCertDuplicateCount(fingerprint, float64(count))
+			}
+		}
+	}
+
+	// Report duplicates according to the configured policy
+	s.applyDuplicatePolicy(allCertInfos)
+
+	// Notify about newly found weak keys and deprecated algorithms
+	s.notifyWeakCrypto(ctx, allCertInfos)
+
+	// Export the inventory for this scan
+	if s.config.InventoryCSVPath != "" {
+		if err := writeInventoryCSV(s.config.InventoryCSVPath, allCertInfos); err != nil {
+			s.logger.Error("Failed to write certificate inventory",
+				zap.String("path", s.config.InventoryCSVPath),
+				zap.Error(err))
 		}
 	}
 
@@ -215,24 +695,63 @@ func (s *Scanner) Scan(ctx context.Context) error {
 		zap.Int("parse_errors", parseErrors),
 		zap.Int("weak_keys", weakKeys),
 		zap.Int("deprecated_algorithms", deprecatedAlgs),
+		zap.Int("disallowed_algorithms", disallowedAlgs),
 		zap.Duration("duration", time.Since(startTime)))
 
+	if partial {
+		return fmt.Errorf("%w: %w", ErrPartialScan, ctx.Err())
+	}
 	return nil
 }
 
+// SetPartialResults sets whether a canceled scan publishes the certificates
+// it found so far, in results, metrics, the cache and the inventory, rather
+// than keeping the previous results. Single scan runs use it so an
+// interrupted run still reports what it found.
+func (s *Scanner) SetPartialResults(enabled bool) {
+	s.partialResults.Store(enabled)
+}
+
 // WatchFiles watches certificate directories for changes
 func (s *Scanner) WatchFiles(ctx context.Context) {
 	s.wg.Add(1)
 	defer s.wg.Done()
 
 	// Add directories to watcher
-	for _, dir := range s.config.CertificateDirectories {
+	for _, dir := range watchedDirectories(s.config) {
 		if err := s.watcher.Add(dir); err != nil {
 			s.logger.Error("Failed to watch directory", zap.String("dir", dir), zap.Error(err))
 			continue
 		}
 		s.logger.Info("Watching directory for changes", zap.String("dir", dir))
 	}
+	s.updateWatchedDirsMetric()
+
+	// Pending rescan after a manifest change
+	var manifestTimer *time.Timer
+	defer func() {
+		if manifestTimer != nil {
+			manifestTimer.Stop()
+		}
+	}()
+
+	// Pending certificate updates when watch_debounce is set. Without it, the
+	// debouncer still runs updates waiting for watch_stable_check, so a file
+	// being written doesn't hold up the events of other files.
+	debouncer := newFileDebouncer()
+	defer debouncer.stop()
+	handleChange := func(path string) {
+		delay := s.config.WatchDebounce
+		if delay <= 0 && s.config.WatchStableCheck <= 0 {
+			s.handleFileChange(ctx, path)
+			return
+		}
+		debouncer.schedule(path, max(delay, 0), func() {
+			if ctx.Err() == nil {
+				s.handleFileChange(ctx, path)
+			}
+		})
+	}
 
 	for {
 		select {
@@ -243,23 +762,50 @@ func (s *Scanner) WatchFiles(ctx context.Context) {
 				return
 			}
 
+			// Rescan with the new file list when the manifest changes
+			if s.config.ManifestFile != "" {
+				if s.isManifestEvent(event.Name) && event.Op != fsnotify.Chmod {
+					if manifestTimer != nil {
+						manifestTimer.Stop()
+					}
+					manifestTimer = time.AfterFunc(manifestReloadDelay, func() {
+						s.logger.Info("Certificate manifest changed, rescanning",
+							zap.String("manifest", s.config.ManifestFile))
+						if err := s.Scan(ctx); err != nil {
+							s.logger.Error("Rescan after manifest change failed", zap.Error(err))
+						}
+					})
+				}
+				continue
+			}
+
 			// Check if it's a certificate file
-			if !s.isCertificateFile(event.Name) {
+			if !s.isCertificateFile(event.Name) || s.isExcluded(s.directoryFor(event.Name), event.Name) {
 				continue
 			}
 
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove) != 0 {
+				s.recordDirChange(s.directoryFor(event.Name), time.Now())
+			}
+
 			// Handle file events
 			switch {
 			case event.Op&fsnotify.Write == fsnotify.Write:
 				s.logger.Debug("Certificate file modified", zap.String("path", event.Name))
-				s.handleFileChange(event.Name)
+				handleChange(event.Name)
 			case event.Op&fsnotify.Create == fsnotify.Create:
 				s.logger.Debug("Certificate file created", zap.String("path", event.Name))
-				s.handleFileChange(event.Name)
+				handleChange(event.Name)
 			case event.Op&fsnotify.Remove == fsnotify.Remove:
 				s.logger.Debug("Certificate file removed", zap.String("path", event.Name))
+				debouncer.cancel(event.Name)
 				// Invalidate cache for removed file
-				s.cache.Set(event.Name, nil)
+				s.cache.Delete(event.Name)
+				s.resultsMu.Lock()
+				delete(s.results, event.Name)
+				monitored := len(s.results)
+				s.resultsMu.Unlock()
+				s.metrics.SetMonitoredFiles(float64(monitored))
 			}
 
 		case err, ok := <-s.watcher.Errors:
@@ -276,13 +822,41 @@ func (s *Scanner) UpdateConfig(cfg *config.Config) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// Switch between push-on-scan and scrape-time certificate metrics
+	if s.config.CollectorMode != cfg.CollectorMode {
+		if cfg.CollectorMode {
+			s.metrics.SetCertificateSource(s.CertificateSnapshots)
+		} else {
+			s.metrics.SetCertificateSource(nil)
+		}
+	}
+
+	// Reload the CA bundle, which may have changed even if its path didn't
+	if err := s.loadIssuerBundle(cfg); err != nil {
+		return err
+	}
+
+	// Rebuild the outbound transport, whose CA bundle may have changed too
+	transport, err := httpclient.NewTransport(httpOptions(cfg))
+	if err != nil {
+		return err
+	}
+
+	s.includeCN.Store(newCNFilter(cfg.IncludeCNPatterns))
+
+	// Watch directories added by the new configuration, e.g. new glob matches
+	s.updateWatchedDirectories(watchedDirectories(s.config), watchedDirectories(cfg))
+
 	// Update configuration
 	s.config = cfg
+	s.transport = transport
+	sources := newSources(cfg, transport)
+	s.sources.Store(&sources)
 
 	// Reinitialize cache if directory changed
 	if s.config.CacheDir != cfg.CacheDir {
 		s.cache.Close()
-		newCache, err := cache.New(cfg.CacheDir, cfg.CacheTTL, cfg.CacheMaxSize)
+		newCache, err := cache.NewWithSaveInterval(cfg.CacheDir, cfg.CacheTTL, cfg.CacheMaxSize, cfg.CacheSaveInterval)
 		if err != nil {
 			return fmt.Errorf("failed to reinitialize cache: %w", err)
 		}
@@ -292,6 +866,101 @@ func (s *Scanner) UpdateConfig(cfg *config.Config) error {
 	return nil
 }
 
+// httpOptions returns the outbound HTTP settings of a configuration
+func httpOptions(cfg *config.Config) httpclient.Options {
+	return httpclient.Options{
+		UserAgent:    cfg.HTTPUserAgent,
+		CABundleFile: cfg.HTTPCABundleFile,
+	}
+}
+
+// watchedDirectories returns the directories to watch for a configuration. In
+// manifest mode only the manifest's directory is watched, so that the watch
+// survives the manifest being replaced by a rename. Certificate directories
+// under exclude_paths aren't watched.
+func watchedDirectories(cfg *config.Config) []string {
+	if cfg.ManifestFile != "" {
+		return []string{filepath.Dir(cfg.ManifestFile)}
+	}
+
+	dirs := make([]string, 0, len(cfg.CertificateDirectories))
+	for _, dir := range cfg.CertificateDirectories {
+		if !isExcludedPath(cfg.ExcludePaths, dir, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// updateWatchedDirectories adjusts the file watcher to a new set of directories
+func (s *Scanner) updateWatchedDirectories(oldDirs, newDirs []string) {
+	watched := make(map[string]bool, len(oldDirs))
+	for _, dir := range oldDirs {
+		watched[dir] = true
+	}
+
+	for _, dir := range newDirs {
+		if watched[dir] {
+			delete(watched, dir)
+			continue
+		}
+		if err := s.watcher.Add(dir); err != nil {
+			s.logger.Error("Failed to watch directory", zap.String("dir", dir), zap.Error(err))
+			continue
+		}
+		s.logger.Info("Watching directory for changes", zap.String("dir", dir))
+	}
+
+	// Directories left over are no longer configured
+	for dir := range watched {
+		if err := s.watcher.Remove(dir); err != nil {
+			s.logger.Debug("Failed to stop watching directory", zap.String("dir", dir), zap.Error(err))
+		}
+	}
+	s.updateWatchedDirsMetric()
+}
+
+// updateWatchedDirsMetric exports how many directories the file watcher
+// holds. Directories that disappear drop out of the watcher on their own.
+func (s *Scanner) updateWatchedDirsMetric() {
+	s.metrics.SetWatchedDirs(float64(len(s.watcher.WatchList())))
+}
+
+// ClearCache drops all cached certificate data so the next scan re-parses
+// every file. Returns the number of entries removed.
+func (s *Scanner) ClearCache() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.cache.Clear()
+}
+
+// DumpCache writes the cached certificate entries to w for debugging, with
+// the fingerprint and expiry of each certificate and the current
+// modification time of its file
+func (s *Scanner) DumpCache(w io.Writer) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.cache.Dump(w, describeCacheEntry)
+}
+
+// describeCacheEntry renders a cached certificate for DumpCache. A file that
+// is gone, or an object store certificate, has no modification time.
+func describeCacheEntry(entry cache.Entry) string {
+	certInfo, ok := entry.Value.(*CertificateInfo)
+	if !ok {
+		return fmt.Sprintf("%T", entry.Value)
+	}
+
+	modTime := "-"
+	if info, err := os.Stat(entry.Key); err == nil {
+		modTime = info.ModTime().UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("fingerprint=%s not_after=%s mod_time=%s",
+		certInfo.Fingerprint, certInfo.NotAfter.UTC().Format(time.RFC3339), modTime)
+}
+
 // Close shuts down the scanner
 func (s *Scanner) Close() {
 	close(s.stopChan)
@@ -300,111 +969,291 @@ func (s *Scanner) Close() {
 	s.wg.Wait()
 }
 
+// Jitter sleeps for a random time up to scan_jitter, so instances sharing a
+// certificate store don't all scan at once. Returns early with the context's
+// error if ctx is done first.
+func (s *Scanner) Jitter(ctx context.Context) error {
+	s.mu.RLock()
+	jitter := s.config.ScanJitter
+	s.mu.RUnlock()
+	if jitter <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(jitter))))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Wait blocks until no scan is running or ctx is done. Call it after
+// canceling the scan context and before Close, so the final cache save
+// doesn't race a scan that is still winding down.
+func (s *Scanner) Wait(ctx context.Context) error {
+	s.scansMu.Lock()
+	if s.scansActive == 0 {
+		s.scansMu.Unlock()
+		return nil
+	}
+	idle := s.scansIdle
+	s.scansMu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// beginScan records the start of a scan for Wait
+func (s *Scanner) beginScan() {
+	s.scansMu.Lock()
+	defer s.scansMu.Unlock()
+
+	if s.scansActive == 0 {
+		s.scansIdle = make(chan struct{})
+	}
+	s.scansActive++
+}
+
+// endScan records the end of a scan, waking Wait after the last one
+func (s *Scanner) endScan() {
+	s.scansMu.Lock()
+	defer s.scansMu.Unlock()
+
+	s.scansActive--
+	if s.scansActive == 0 {
+		close(s.scansIdle)
+	}
+}
+
 // processCertificate processes a single certificate file
-func (s *Scanner) processCertificate(path string) (*CertificateInfo, error) {
-	// Check cache first
-	if cached := s.cache.Get(path); cached != nil {
+func (s *Scanner) processCertificate(ctx context.Context, path string) (*CertificateInfo, error) {
+	// Check cache first; a symlink shares the entry of its target
+	key := s.cacheKey(path)
+	if cached := s.cache.Get(key); cached != nil {
 		if certInfo, ok := cached.(*CertificateInfo); ok {
+			s.metrics.IncCacheHits()
+			if certInfo.Path != path {
+				linked := *certInfo
+				linked.Path = path
+				return &linked, nil
+			}
 			return certInfo, nil
 		}
 	}
 
 	// Read certificate file
-	data, err := os.ReadFile(path)
+	data, err := s.readCertificateData(ctx, path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read certificate: %w", err)
+		return nil, err
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	// Identical content seen under another path, e.g. after a rename, doesn't
+	// need to be parsed again
+	digest := sha256.Sum256(data)
+	contentKey := hex.EncodeToString(digest[:])
+	if cached := s.cache.GetByIndex(contentKey); cached != nil {
+		if known, ok := cached.(*CertificateInfo); ok {
+			certInfo := *known
+			certInfo.Path = path
+			s.logger.Debug("Reusing parsed certificate with identical content",
+				zap.String("path", path),
+				zap.String("previous_path", known.Path))
+			s.cache.SetWithIndex(key, contentKey, &certInfo)
+			s.metrics.IncCacheHits()
+			return &certInfo, nil
+		}
 	}
 
 	// Parse certificate
+	s.metrics.IncCacheMisses()
 	certInfo, err := s.parseCertificate(path, data)
 	if err != nil {
 		return nil, err
 	}
 
-	// Cache the result
-	s.cache.Set(path, certInfo)
+	// Logged once per parse rather than on every scan
+	if s.hasExcessiveValidity(certInfo) {
+		s.logger.Warn("Certificate validity period exceeds max_validity_days",
+			zap.String("path", path),
+			zap.Int("validity_days", certInfo.ValidityDays()),
+			zap.Int("max_validity_days", s.config.MaxValidityDays))
+	}
+
+	// Cache the result, unless it expired too long ago to be worth the memory
+	if !s.isLongExpired(certInfo) {
+		s.cache.SetWithIndex(key, contentKey, certInfo)
+	}
 
 	return certInfo, nil
 }
 
-// parseCertificate parses certificate data
-func (s *Scanner) parseCertificate(path string, data []byte) (*CertificateInfo, error) {
-	// Decode PEM block
-	block, _ := pem.Decode(data)
-	if block == nil {
-		// Try to parse as DER
-		cert, err := x509.ParseCertificate(data)
+// readCertificateData reads the certificate data of a file, decompressing
+// gzip files and unwrapping Kubernetes TLS secret manifests. Returns nil data
+// if the file was skipped.
+func (s *Scanner) readCertificateData(ctx context.Context, path string) ([]byte, error) {
+	data, err := s.readCertificateFile(ctx, path)
+	if err != nil {
+		return nil, &readError{reason: reasonReadError, err: err}
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	if isGzipFile(path) {
+		data, err = s.decompressCertificate(path, data)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+			return nil, &readError{reason: reasonDecompress, err: err}
+		}
+		if data == nil {
+			return nil, nil
 		}
-		return s.extractCertInfo(path, cert), nil
 	}
 
-	// Parse PEM certificate
-	cert, err := x509.ParseCertificate(block.Bytes)
+	// Unwrap certificates embedded in Kubernetes TLS secret manifests
+	if s.config.ParseK8sSecrets && isSecretManifest(uncompressedName(path)) {
+		certData, found, err := extractSecretCertificate(data)
+		if err != nil {
+			return nil, &readError{reason: reasonSecretManifest, err: err}
+		}
+		if !found {
+			s.logger.Debug("Skipping manifest without tls.crt", zap.String("path", path))
+			return nil, nil
+		}
+		data = certData
+	}
+
+	return data, nil
+}
+
+// readCertificateFile reads a certificate file from its source, or the
+// certificates presented by a network endpoint. Returns nil data if the
+// file was skipped.
+func (s *Scanner) readCertificateFile(ctx context.Context, path string) ([]byte, error) {
+	if endpoint, ok := probe.Endpoint(path); ok {
+		return s.readEndpoint(ctx, endpoint)
+	}
+	return s.readSource(ctx, path)
+}
+
+// parseCertificate parses certificate data
+func (s *Scanner) parseCertificate(path string, data []byte) (*CertificateInfo, error) {
+	c, err := cert.Parse(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse PEM certificate: %w", err)
+		return nil, err
 	}
 
-	return s.extractCertInfo(path, cert), nil
+	certInfo := s.extractCertInfo(path, c)
+	certInfo.ChainDepth = cert.ChainDepth(data)
+	certInfo.ChainTypes = chainTypes(c, data)
+	certInfo.Format = cert.Format(data)
+
+	return certInfo, nil
+}
+
+// chainTypes classifies every certificate in the data, leaf first. Only the
+// leaf is classified when another certificate in the file is corrupt.
+func chainTypes(leaf *x509.Certificate, data []byte) []string {
+	chain, err := cert.ParseChain(data)
+	if err != nil {
+		return []string{cert.Classify(leaf)}
+	}
+
+	types := make([]string, 0, len(chain))
+	for _, c := range chain {
+		types = append(types, cert.Classify(c))
+	}
+	return types
 }
 
 // extractCertInfo extracts information from a certificate
-func (s *Scanner) extractCertInfo(path string, cert *x509.Certificate) *CertificateInfo {
+func (s *Scanner) extractCertInfo(path string, c *x509.Certificate) *CertificateInfo {
 	// Calculate fingerprint
-	hash := sha256.Sum256(cert.Raw)
+	hash := sha256.Sum256(c.Raw)
 	fingerprint := hex.EncodeToString(hash[:])
 
 	// Determine key size
 	keySize := 0
-	isWeakKey := false
-
-	if cert.PublicKeyAlgorithm == x509.RSA {
-		if rsaKey, ok := cert.PublicKey.(*rsa.PublicKey); ok {
+	if c.PublicKeyAlgorithm == x509.RSA {
+		if rsaKey, ok := c.PublicKey.(*rsa.PublicKey); ok {
 			keySize = rsaKey.N.BitLen()
-			isWeakKey = keySize < 2048
 		}
 	}
 
+	// Any key weakness marks the key as weak
+	keyWeaknesses := cert.KeyWeaknesses(c)
+
 	// Check for deprecated signature algorithms
 	isDeprecatedAlg := false
-	switch cert.SignatureAlgorithm {
+	switch c.SignatureAlgorithm {
 	case x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
 		isDeprecatedAlg = true
 	}
 
 	// Count SANs
-	sanCount := len(cert.DNSNames) + len(cert.IPAddresses) + len(cert.EmailAddresses) + len(cert.URIs)
+	sanCount := len(c.DNSNames) + len(c.IPAddresses) + len(c.EmailAddresses) + len(c.URIs)
 
-	return &CertificateInfo{
+	ipAddresses := make([]string, 0, len(c.IPAddresses))
+	for _, ip := range c.IPAddresses {
+		ipAddresses = append(ipAddresses, ip.String())
+	}
+
+	certInfo := &CertificateInfo{
 		Path:               path,
-		Subject:            cert.Subject.String(),
-		Issuer:             cert.Issuer.String(),
-		SerialNumber:       cert.SerialNumber.String(),
-		NotBefore:          cert.NotBefore,
-		NotAfter:           cert.NotAfter,
-		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		Subject:            sanitizeLabelValue(c.Subject.String()),
+		Issuer:             sanitizeLabelValue(c.Issuer.String()),
+		AuthorityKeyID:     hex.EncodeToString(c.AuthorityKeyId),
+		SerialNumber:       c.SerialNumber.String(),
+		NotBefore:          c.NotBefore,
+		NotAfter:           c.NotAfter,
+		SignatureAlgorithm: c.SignatureAlgorithm.String(),
 		KeySize:            keySize,
-		IsWeakKey:          isWeakKey,
-		IsExpired:          time.Now().After(cert.NotAfter),
+		IsWeakKey:          len(keyWeaknesses) > 0,
+		KeyWeaknesses:      keyWeaknesses,
+		Curve:              cert.CurveName(c),
+		IsExpired:          time.Now().After(c.NotAfter),
 		IsDeprecatedAlg:    isDeprecatedAlg,
+		IsSelfSigned:       cert.IsSelfSigned(c),
+		HasSCT:             cert.HasSCT(c),
+		KeyUsages:          cert.KeyUsages(c),
+		ExtKeyUsages:       cert.ExtKeyUsages(c),
 		SANCount:           sanCount,
+		DNSNames:           c.DNSNames,
+		IPAddresses:        ipAddresses,
 		Fingerprint:        fingerprint,
+		SPKISHA256:         cert.SPKISHA256(c),
+		Organization:       sanitizeLabelValue(strings.Join(c.Subject.Organization, ", ")),
 	}
+	certInfo.BaseDomain = cert.BaseDomain(certInfo.PrimaryName())
+	return certInfo
 }
 
 // updateMetrics updates Prometheus metrics for a certificate
 func (s *Scanner) updateMetrics(certInfo *CertificateInfo) {
+	// Directory label value, used only when metrics_dir_label is enabled
+	dir := s.directoryFor(certInfo.Path)
+
 	// Certificate expiration
 	s.metrics.SetCertExpiration(
 		certInfo.Path,
 		certInfo.Subject,
 		certInfo.Issuer,
+		dir,
 		float64(certInfo.NotAfter.Unix()),
 	)
 
 	// SAN count
-	s.metrics.SetCertSANCount(certInfo.Path, float64(certInfo.SANCount))
+	s.metrics.SetCertSANCount(certInfo.Path, dir, float64(certInfo.SANCount))
 
 	// Certificate info
 	s.metrics.SetCertInfo(
@@ -413,20 +1262,319 @@ func (s *Scanner) updateMetrics(certInfo *CertificateInfo) {
 		certInfo.Issuer,
 		certInfo.SerialNumber,
 		certInfo.SignatureAlgorithm,
+		certSource(certInfo.Path),
+		dir,
 	)
 
-	// Extract common name from subject
-	commonName := extractCommonName(certInfo.Subject)
-	if commonName == "" {
-		commonName = "unknown"
-	}
+	// Common name label, or the first SAN with primary_identifier
+	commonName := s.labelName(certInfo)
 
 	// Extract filename from path
 	fileName := filepath.Base(certInfo.Path)
 
 	// Issuer classification with additional labels
-	issuerCode := s.classifyIssuer(certInfo.Issuer)
-	s.metrics.SetCertIssuerCodeWithLabels(certInfo.Issuer, commonName, fileName, float64(issuerCode))
+	issuerCode := s.issuerCode(certInfo)
+	s.metrics.SetCertIssuerCodeWithLabels(certInfo.Issuer, commonName, fileName, dir, float64(issuerCode))
+
+	// Expiring soon, and due for renewal by the issuer's window
+	s.metrics.SetCertExpiringSoon(certInfo.Path, dir, s.IsExpiringSoon(certInfo))
+	s.metrics.SetCertRenewalDue(commonName, fileName, s.isRenewalDue(certInfo))
+
+	// Expiration grouped by base domain
+	if certInfo.BaseDomain != "" {
+		s.metrics.SetCertByDomain(certInfo.BaseDomain, commonName, fileName, float64(certInfo.NotAfter.Unix()))
+	}
+
+	// Key weaknesses
+	for _, reason := range certInfo.KeyWeaknesses {
+		s.metrics.SetCertKeyWeakness(commonName, fileName, reason)
+	}
+	if s.config.IsCurveDeprecated(certInfo.Curve) {
+		s.metrics.SetCertDeprecatedCurve(commonName, fileName, certInfo.Curve)
+	}
+
+	// Implausibly long validity
+	if s.hasExcessiveValidity(certInfo) {
+		s.metrics.SetCertExcessiveValidity(commonName, fileName, float64(certInfo.ValidityDays()))
+	}
+
+	// Validity longer than the validity policy in effect allows
+	if policy := s.violatedValidityPolicy(certInfo); policy != "" {
+		s.metrics.SetCertPolicyViolation(commonName, fileName, policy, float64(certInfo.ValidityDays()))
+	}
+
+	// IP SANs failing reverse lookup validation
+	for _, ip := range certInfo.IPSANMismatches {
+		s.metrics.SetCertIPSANMismatch(commonName, fileName, ip)
+	}
+
+	// Certificate Transparency
+	if s.config.CheckSCT {
+		s.metrics.SetCertHasSCT(commonName, fileName, certInfo.HasSCT)
+	}
+
+	// Chain depth
+	s.metrics.SetCertChainDepth(commonName, fileName, dir, float64(certInfo.ChainDepth))
+
+	// Key usage and extended key usage
+	if s.config.ExportKeyUsage {
+		for _, usage := range certInfo.KeyUsages {
+			s.metrics.SetCertKeyUsage(commonName, fileName, usage)
+		}
+		for _, eku := range certInfo.ExtKeyUsages {
+			s.metrics.SetCertExtKeyUsage(commonName, fileName, eku)
+		}
+	}
+
+	// Public key pin
+	if s.config.ExportSPKI && certInfo.SPKISHA256 != "" {
+		s.metrics.SetCertSPKI(commonName, fileName, certInfo.SPKISHA256)
+	}
+
+	// Subject organization, absent for entries cached before it was recorded
+	if s.config.ExportOrganization && certInfo.Organization != "" {
+		s.metrics.SetCertOrganization(commonName, fileName, certInfo.Organization)
+	}
+
+	// File encoding, absent for entries cached before it was recorded
+	if certInfo.Format != "" {
+		s.metrics.SetCertFormat(commonName, fileName, certInfo.Format)
+	}
+}
+
+// Certificates returns the certificates found by the last scan, sorted by path
+func (s *Scanner) Certificates() []*CertificateInfo {
+	s.resultsMu.RLock()
+	defer s.resultsMu.RUnlock()
+
+	certInfos := make([]*CertificateInfo, 0, len(s.results))
+	for _, certInfo := range s.results {
+		certInfos = append(certInfos, certInfo)
+	}
+	sort.Slice(certInfos, func(i, j int) bool {
+		return certInfos[i].Path < certInfos[j].Path
+	})
+
+	return certInfos
+}
+
+// CertificatePEM re-reads a certificate's file and returns its leaf PEM
+// encoded. Fails if the file no longer holds the certificate from the last scan.
+func (s *Scanner) CertificatePEM(certInfo *CertificateInfo) (string, error) {
+	data, err := s.readCertificateData(context.Background(), certInfo.Path)
+	if err != nil {
+		return "", err
+	}
+	if data == nil {
+		return "", fmt.Errorf("certificate file skipped: %s", certInfo.Path)
+	}
+
+	leaf, err := cert.Parse(data)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(leaf.Raw)
+	if hex.EncodeToString(hash[:]) != certInfo.Fingerprint {
+		return "", fmt.Errorf("certificate changed since the last scan: %s", certInfo.Path)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})), nil
+}
+
+// CertificateSnapshots returns the certificates found by the last scan as
+// metric snapshots. Expiry status is evaluated at call time.
+func (s *Scanner) CertificateSnapshots() []metrics.CertificateSnapshot {
+	s.resultsMu.RLock()
+	defer s.resultsMu.RUnlock()
+
+	snapshots := make([]metrics.CertificateSnapshot, 0, len(s.results))
+	for _, certInfo := range s.results {
+		if !s.exportsMetrics(certInfo) {
+			continue
+		}
+
+		// Usage series are only exported when enabled, given their cardinality
+		var keyUsages, extKeyUsages []string
+		if s.config.ExportKeyUsage {
+			keyUsages = certInfo.KeyUsages
+			extKeyUsages = certInfo.ExtKeyUsages
+		}
+		var spki string
+		if s.config.ExportSPKI {
+			spki = certInfo.SPKISHA256
+		}
+		var organization string
+		if s.config.ExportOrganization {
+			organization = certInfo.Organization
+		}
+		var deprecatedCurve string
+		if s.config.IsCurveDeprecated(certInfo.Curve) {
+			deprecatedCurve = certInfo.Curve
+		}
+		var excessiveValidityDays int
+		if s.hasExcessiveValidity(certInfo) {
+			excessiveValidityDays = certInfo.ValidityDays()
+		}
+
+		snapshots = append(snapshots, metrics.CertificateSnapshot{
+			Path:               certInfo.Path,
+			Subject:            certInfo.Subject,
+			Issuer:             certInfo.Issuer,
+			SerialNumber:       certInfo.SerialNumber,
+			SignatureAlgorithm: certInfo.SignatureAlgorithm,
+			Source:             certSource(certInfo.Path),
+			CommonName:         s.labelName(certInfo),
+			FileName:           filepath.Base(certInfo.Path),
+			Dir:                s.directoryFor(certInfo.Path),
+			Fingerprint:        certInfo.Fingerprint,
+			BaseDomain:         certInfo.BaseDomain,
+			NotAfter:           certInfo.NotAfter,
+			SANCount:           certInfo.SANCount,
+			IssuerCode:         s.issuerCode(certInfo),
+			ExpiringSoon:       s.IsExpiringSoon(certInfo),
+			RenewalDue:         s.isRenewalDue(certInfo),
+			KeyWeaknesses:      certInfo.KeyWeaknesses,
+			DeprecatedCurve:    deprecatedCurve,
+			ExcessiveValidity:  excessiveValidityDays,
+			PolicyViolation:    s.violatedValidityPolicy(certInfo),
+			ValidityDays:       certInfo.ValidityDays(),
+			IPSANMismatches:    certInfo.IPSANMismatches,
+			SCTChecked:         s.config.CheckSCT,
+			HasSCT:             certInfo.HasSCT,
+			ChainDepth:         certInfo.ChainDepth,
+			KeyUsages:          keyUsages,
+			ExtKeyUsages:       extKeyUsages,
+			SPKISHA256:         spki,
+			Organization:       organization,
+			Format:             certInfo.Format,
+		})
+	}
+
+	return snapshots
+}
+
+// duplicateKey groups the files of a certificate within one directory
+type duplicateKey struct {
+	dir         string
+	fingerprint string
+}
+
+// applyDuplicatePolicy logs certificates found in more than one file of the
+// same directory and, in error mode, counts each one as a violation. A
+// certificate deployed once in each of several directories, such as a shared
+// chain, is not a duplicate. The count policy only reports duplicates through
+// ssl_cert_duplicate_count.
+func (s *Scanner) applyDuplicatePolicy(certInfos []*CertificateInfo) {
+	policy := strings.ToLower(s.config.DuplicatePolicy)
+	if policy != config.DuplicatePolicyWarn && policy != config.DuplicatePolicyError {
+		return
+	}
+
+	paths := make(map[duplicateKey][]string)
+	for _, certInfo := range certInfos {
+		key := duplicateKey{dir: s.directoryFor(certInfo.Path), fingerprint: certInfo.Fingerprint}
+		paths[key] = append(paths[key], certInfo.Path)
+	}
+
+	for key, files := range paths {
+		if len(files) < 2 {
+			continue
+		}
+		sort.Strings(files)
+
+		fields := []zap.Field{
+			zap.String("dir", key.dir),
+			zap.String("fingerprint", key.fingerprint),
+			zap.Strings("paths", files),
+		}
+		if policy == config.DuplicatePolicyError {
+			s.metrics.IncDuplicateViolations()
+			s.logger.Error("Duplicate certificate violates duplicate policy", fields...)
+		} else {
+			s.logger.Warn("Duplicate certificate found", fields...)
+		}
+	}
+}
+
+// IsExpiringSoon checks if a certificate expires within the configured threshold.
+// Certificates issued within the IgnoreNewerThan grace period are exempt so that
+// deliberately short-lived certificates don't alert as soon as they are deployed.
+func (s *Scanner) IsExpiringSoon(certInfo *CertificateInfo) bool {
+	return s.expiresWithin(certInfo, s.config.ExpiryThreshold)
+}
+
+// isRenewalDue checks if a certificate expires within the renewal window of
+// its issuer code, falling back to the global threshold for issuers without
+// one in renewal_thresholds
+func (s *Scanner) isRenewalDue(certInfo *CertificateInfo) bool {
+	return s.expiresWithin(certInfo, s.renewalThreshold(certInfo))
+}
+
+// renewalThreshold returns the renewal window for a certificate's issuer code
+func (s *Scanner) renewalThreshold(certInfo *CertificateInfo) time.Duration {
+	if threshold, ok := s.config.RenewalThresholds[s.issuerCode(certInfo)]; ok {
+		return threshold
+	}
+	return s.config.ExpiryThreshold
+}
+
+// expiresWithin checks if a certificate that hasn't expired yet expires
+// within threshold, skipping certificates issued within IgnoreNewerThan
+func (s *Scanner) expiresWithin(certInfo *CertificateInfo, threshold time.Duration) bool {
+	remaining := time.Until(certInfo.NotAfter)
+	if remaining <= 0 || remaining > threshold {
+		return false
+	}
+
+	if s.config.IgnoreNewerThan > 0 && time.Since(certInfo.NotBefore) < s.config.IgnoreNewerThan {
+		return false
+	}
+
+	return true
+}
+
+// hasExcessiveValidity checks if a certificate's validity period exceeds
+// max_validity_days, as with test certificates issued for 100 years
+func (s *Scanner) hasExcessiveValidity(certInfo *CertificateInfo) bool {
+	return s.config.MaxValidityDays > 0 && certInfo.ValidityDays() > s.config.MaxValidityDays
+}
+
+// issuerCode returns the issuer classification code for a certificate.
+// Certificates verifiably signed by their own key are always self-signed.
+// Certificates issued by a CA in the configured bundle get that CA's code;
+// everything else is classified by issuer name.
+func (s *Scanner) issuerCode(certInfo *CertificateInfo) int {
+	if certInfo.IsSelfSigned {
+		return 33 // Self-signed
+	}
+
+	if bundle := s.issuers.Load(); bundle != nil {
+		if code, ok := bundle.classify(certInfo); ok {
+			return code
+		}
+	}
+
+	return s.classifyIssuer(certInfo.Issuer)
+}
+
+// loadIssuerBundle loads the configured CA bundle, or clears it when none is set
+func (s *Scanner) loadIssuerBundle(cfg *config.Config) error {
+	if cfg.CABundleFile == "" {
+		s.issuers.Store(nil)
+		return nil
+	}
+
+	bundle, err := loadIssuerBundle(cfg.CABundleFile)
+	if err != nil {
+		return err
+	}
+	s.issuers.Store(bundle)
+
+	s.logger.Info("Loaded CA bundle for issuer classification",
+		zap.String("path", cfg.CABundleFile),
+		zap.Int("subjects", len(bundle.bySubject)))
+	return nil
 }
 
 // classifyIssuer classifies certificate issuer with updated classification codes
@@ -497,8 +1645,15 @@ func (s *Scanner) classifyIssuer(issuer string) int {
 
 // isCertificateFile checks if a file is likely a certificate (excluding private keys)
 func (s *Scanner) isCertificateFile(path string) bool {
-	ext := strings.ToLower(filepath.Ext(path))
-	basename := strings.ToLower(filepath.Base(path))
+	// Compressed files are classified by their name without .gz
+	name := uncompressedName(path)
+	ext := strings.ToLower(filepath.Ext(name))
+	basename := strings.ToLower(filepath.Base(name))
+
+	// Kubernetes secret manifests are only considered when enabled
+	if isSecretManifest(name) {
+		return s.config.ParseK8sSecrets
+	}
 
 	// FIRST: Exclude private key files by extension
 	privateKeyExts := []string{".key", ".pem.key", ".private", ".priv"}
@@ -541,6 +1696,16 @@ func (s *Scanner) isCertificateFile(path string) bool {
 	return false
 }
 
+// walkDepth returns how many levels below root path is. Files directly in
+// root are at depth 1.
+func walkDepth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
 // extractCommonName extracts the common name from a certificate subject string
 func extractCommonName(subject string) string {
 	// Subject format is typically: CN=example.com,O=Organization,C=US
@@ -555,9 +1720,21 @@ func extractCommonName(subject string) string {
 }
 
 // handleFileChange handles certificate file changes
-func (s *Scanner) handleFileChange(path string) {
+func (s *Scanner) handleFileChange(ctx context.Context, path string) {
+	// A file caught mid-write is left to the event completing the write
+	if interval := s.config.WatchStableCheck; interval > 0 && !s.waitForStableFile(ctx, path, interval) {
+		s.logger.Debug("Certificate file still being written, waiting for its next event", zap.String("path", path))
+		return
+	}
+
+	// The cached entry describes the file before the change
+	s.cache.Delete(path)
+	if key := s.cacheKey(path); key != path {
+		s.cache.Delete(key)
+	}
+
 	// Process the changed certificate
-	certInfo, err := s.processCertificate(path)
+	certInfo, err := s.processCertificate(ctx, path)
 	if err != nil {
 		s.logger.Error("Failed to process changed certificate",
 			zap.String("path", path),
@@ -566,8 +1743,25 @@ func (s *Scanner) handleFileChange(path string) {
 	}
 
 	if certInfo != nil {
+		certInfo = s.withIPSANValidation(ctx, certInfo)
+
+		s.resultsMu.Lock()
+		previous := s.results[path]
+		s.results[path] = certInfo
+		monitored := len(s.results)
+		s.resultsMu.Unlock()
+		s.metrics.SetMonitoredFiles(float64(monitored))
+
+		s.recordRotation(previous, certInfo)
+
+		// A certificate in a directory backing off after parse errors
+		// means it no longer holds only unparseable files
+		s.registerScanSuccess(s.directoryFor(path))
+
 		// Update metrics for the changed certificate
-		s.updateMetrics(certInfo)
+		if !s.config.CollectorMode && s.exportsMetrics(certInfo) {
+			s.updateMetrics(certInfo)
+		}
 		s.logger.Info("Certificate updated",
 			zap.String("path", path),
 			zap.String("subject", certInfo.Subject))
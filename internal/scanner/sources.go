@@ -0,0 +1,98 @@
+// internal/scanner/sources.go
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/config"
+	"github.com/brandonhon/tls-cert-monitor/internal/source"
+	"go.uber.org/zap"
+)
+
+// objectRequestTimeout bounds each object store request
+const objectRequestTimeout = 30 * time.Second
+
+// localFiles reads certificate files outside every configured source, such
+// as manifest entries
+var localFiles = source.NewFilesystem("", 0)
+
+// newSources returns the sources a scan walks for cfg: each certificate
+// directory, unless a manifest replaces the directory walk, and the object
+// store if a bucket is configured. Requests to the object store go through
+// transport.
+func newSources(cfg *config.Config, transport http.RoundTripper) []source.Source {
+	var sources []source.Source
+	if cfg.ManifestFile == "" {
+		for _, dir := range cfg.CertificateDirectories {
+			sources = append(sources, source.NewFilesystem(dir, cfg.WalkWorkers))
+		}
+	}
+
+	if cfg.S3Bucket != "" {
+		client := &http.Client{Transport: transport, Timeout: objectRequestTimeout}
+		sources = append(sources, source.NewS3(cfg.S3Bucket, cfg.S3Prefix, cfg.S3Region, cfg.S3Endpoint, client))
+	}
+
+	return sources
+}
+
+// currentSources returns the sources of the current configuration
+func (s *Scanner) currentSources() []source.Source {
+	return *s.sources.Load()
+}
+
+// sourceFor returns the source holding the certificate at path; local files
+// outside every configured directory are read directly
+func (s *Scanner) sourceFor(path string) source.Source {
+	for _, src := range s.currentSources() {
+		if _, ok := src.Key(path); ok {
+			return src
+		}
+	}
+	return localFiles
+}
+
+// readSource reads a certificate from its source, refusing files larger
+// than MaxCertFileSize so a huge file can't exhaust memory. Returns nil data
+// if the file was skipped.
+func (s *Scanner) readSource(ctx context.Context, path string) ([]byte, error) {
+	src := s.sourceFor(path)
+	key, _ := src.Key(path)
+	maxSize := s.config.MaxCertFileSize
+
+	// Read one byte past the limit to tell an oversized file apart
+	var limit int64
+	if maxSize > 0 {
+		limit = maxSize + 1
+	}
+	data, err := src.Read(ctx, key, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+
+	if maxSize > 0 && int64(len(data)) > maxSize {
+		s.logger.Warn("Skipping oversized certificate file",
+			zap.String("path", path),
+			zap.Int64("max_size", maxSize))
+		s.metrics.IncOversizedFiles()
+		return nil, nil
+	}
+
+	return data, nil
+}
+
+// objectChanged reports whether an object store certificate may differ from
+// its cached parse. The path cache can't see an object replaced in place, so
+// objects are compared by ETag with the last scan; unknown objects are parsed
+// once as an entry may predate a restart.
+func (s *Scanner) objectChanged(path, etag string) bool {
+	s.objectETagsMu.Lock()
+	defer s.objectETagsMu.Unlock()
+
+	previous, ok := s.objectETags[path]
+	return !ok || previous != etag
+}
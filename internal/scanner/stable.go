@@ -0,0 +1,42 @@
+// internal/scanner/stable.go
+
+package scanner
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// maxStableChecks bounds how many checks a file that keeps changing is
+// waited for
+const maxStableChecks = 20
+
+// waitForStableFile checks a changed file interval apart until two checks
+// see the same non-zero size and modification time. Returns false if the
+// file is gone, still empty or still changing after maxStableChecks, or ctx
+// is done.
+func (s *Scanner) waitForStableFile(ctx context.Context, path string, interval time.Duration) bool {
+	previous, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	for check := 0; check < maxStableChecks; check++ {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(interval):
+		}
+
+		current, err := os.Stat(path)
+		if err != nil {
+			return false
+		}
+		if current.Size() > 0 && current.Size() == previous.Size() && current.ModTime().Equal(previous.ModTime()) {
+			return true
+		}
+		previous = current
+	}
+	return false
+}
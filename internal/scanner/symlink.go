@@ -0,0 +1,28 @@
+// internal/scanner/symlink.go
+
+package scanner
+
+import (
+	"path/filepath"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/source"
+)
+
+// cacheKey returns the key a certificate file is cached under. With
+// resolve_symlinks it is the file the path resolves to, so a Kubernetes
+// secret mount whose ..data link is swapped to a new directory misses the
+// cache, and a change reported for the target invalidates every link to it.
+func (s *Scanner) cacheKey(path string) string {
+	if !s.config.ResolveSymlinks {
+		return path
+	}
+	if _, local := s.sourceFor(path).(*source.Filesystem); !local {
+		return path
+	}
+
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return path
+	}
+	return target
+}
@@ -0,0 +1,128 @@
+// internal/scanner/weakcrypto.go
+
+package scanner
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/notify"
+	"go.uber.org/zap"
+)
+
+// weakCryptoWebhookTimeout bounds each weak crypto webhook request
+const weakCryptoWebhookTimeout = 10 * time.Second
+
+// Weak crypto issues reported by webhook
+const (
+	issueWeakKey       = "weak_key"
+	issueDeprecatedAlg = "deprecated_signature_algorithm"
+)
+
+// weakCryptoFinding is a weak key or deprecated signature algorithm found on
+// a certificate
+type weakCryptoFinding struct {
+	CommonName  string `json:"common_name"`
+	FileName    string `json:"file_name"`
+	Path        string `json:"path"`
+	Fingerprint string `json:"fingerprint"`
+	Issue       string `json:"issue"`
+	Detail      string `json:"detail"`
+}
+
+// weakCryptoNotification is the webhook payload
+type weakCryptoNotification struct {
+	Findings []weakCryptoFinding `json:"findings"`
+}
+
+// weakCryptoFindings lists the weak crypto issues of a certificate
+func weakCryptoFindings(certInfo *CertificateInfo) []weakCryptoFinding {
+	newFinding := func(issue, detail string) weakCryptoFinding {
+		return weakCryptoFinding{
+			CommonName:  certInfo.CommonName(),
+			FileName:    filepath.Base(certInfo.Path),
+			Path:        certInfo.Path,
+			Fingerprint: certInfo.Fingerprint,
+			Issue:       issue,
+			Detail:      detail,
+		}
+	}
+
+	var findings []weakCryptoFinding
+	for _, weakness := range certInfo.KeyWeaknesses {
+		findings = append(findings, newFinding(issueWeakKey, weakness))
+	}
+	if certInfo.IsDeprecatedAlg {
+		findings = append(findings, newFinding(issueDeprecatedAlg, certInfo.SignatureAlgorithm))
+	}
+	return findings
+}
+
+// notifyWeakCrypto posts findings not reported before to the weak crypto
+// webhook. Findings are remembered per fingerprint and issue, so the same
+// certificate is reported once however many files or scans it appears in.
+// Delivery happens in the background; failed findings are retried on the
+// next scan.
+func (s *Scanner) notifyWeakCrypto(ctx context.Context, certInfos []*CertificateInfo) {
+	url := s.config.WeakCryptoWebhookURL
+	if url == "" {
+		return
+	}
+
+	var (
+		findings []weakCryptoFinding
+		keys     []string
+	)
+	s.weakCryptoMu.Lock()
+	for _, certInfo := range certInfos {
+		for _, finding := range weakCryptoFindings(certInfo) {
+			key := finding.Fingerprint + "/" + finding.Issue + "/" + finding.Detail
+			if s.weakCryptoNotified[key] {
+				continue
+			}
+			s.weakCryptoNotified[key] = true
+			findings = append(findings, finding)
+			keys = append(keys, key)
+		}
+	}
+	s.weakCryptoMu.Unlock()
+
+	if len(findings) == 0 {
+		return
+	}
+
+	// Delivery outlives the scan, whose context is canceled when Scan
+	// returns; only shutting down the scanner abandons it
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		go func() {
+			select {
+			case <-s.stopChan:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		client := &http.Client{Transport: s.transport, Timeout: weakCryptoWebhookTimeout}
+		webhook := notify.NewWebhook(url, client)
+		if err := webhook.Send(ctx, weakCryptoNotification{Findings: findings}); err != nil {
+			s.logger.Error("Failed to send weak crypto notification",
+				zap.Int("findings", len(findings)),
+				zap.Error(err))
+
+			s.weakCryptoMu.Lock()
+			for _, key := range keys {
+				delete(s.weakCryptoNotified, key)
+			}
+			s.weakCryptoMu.Unlock()
+			return
+		}
+
+		s.logger.Info("Sent weak crypto notification", zap.Int("findings", len(findings)))
+	}()
+}
@@ -0,0 +1,74 @@
+// internal/server/alerts.go
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/scanner"
+)
+
+// alert is a certificate alert shaped like an Alertmanager API alert
+type alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+}
+
+// handleAlerts returns expiring and expired certificates as Alertmanager alerts
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if s.scanner == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "scanner not available")
+		return
+	}
+
+	alerts := []alert{}
+	now := time.Now()
+	for _, certInfo := range s.scanner.Certificates() {
+		switch {
+		case !now.Before(certInfo.NotAfter):
+			alerts = append(alerts, s.newCertificateAlert(certInfo, "CertificateExpired", "critical", certInfo.NotAfter))
+		case s.scanner.IsExpiringSoon(certInfo):
+			// The alert starts when the certificate entered the expiry window
+			startsAt := certInfo.NotAfter.Add(-s.getConfig().ExpiryThreshold)
+			alerts = append(alerts, s.newCertificateAlert(certInfo, "CertificateExpiringSoon", "warning", startsAt))
+		}
+	}
+
+	s.writeJSON(w, http.StatusOK, alerts)
+}
+
+// newCertificateAlert builds an alert for a certificate
+func (s *Server) newCertificateAlert(certInfo *scanner.CertificateInfo, name, severity string, startsAt time.Time) alert {
+	commonName := certInfo.CommonName()
+	remaining := time.Until(certInfo.NotAfter).Round(time.Hour)
+
+	summary := fmt.Sprintf("Certificate %s expires in %s", commonName, remaining)
+	if remaining <= 0 {
+		summary = fmt.Sprintf("Certificate %s has expired", commonName)
+	}
+
+	return alert{
+		Labels: map[string]string{
+			"alertname":   name,
+			"severity":    severity,
+			"common_name": commonName,
+			"file_name":   filepath.Base(certInfo.Path),
+			"path":        certInfo.Path,
+			"issuer":      certInfo.Issuer,
+		},
+		Annotations: map[string]string{
+			"summary":     summary,
+			"description": fmt.Sprintf("%s (serial %s) is valid until %s", certInfo.Path, certInfo.SerialNumber, certInfo.NotAfter.UTC().Format(time.RFC3339)),
+		},
+		StartsAt: startsAt,
+	}
+}
@@ -0,0 +1,163 @@
+// internal/server/certs.go
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/scanner"
+	"go.uber.org/zap"
+)
+
+// certInfoResponse describes a certificate found by the last scan
+type certInfoResponse struct {
+	Path               string    `json:"path"`
+	CommonName         string    `json:"common_name"`
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	SerialNumber       string    `json:"serial_number"`
+	Fingerprint        string    `json:"fingerprint"`
+	SPKISHA256         string    `json:"spki_sha256"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	KeySize            int       `json:"key_size"`
+	DNSNames           []string  `json:"dns_names"`
+	IPAddresses        []string  `json:"ip_addresses"`
+	IsExpired          bool      `json:"is_expired"`
+	ExpiringSoon       bool      `json:"expiring_soon"`
+	PEM                string    `json:"pem,omitempty"`
+	PEMError           string    `json:"pem_error,omitempty"`
+}
+
+// handleCerts lists the certificates found by the last scan. With
+// include_pem=true each leaf is re-read from disk and embedded PEM encoded.
+func (s *Server) handleCerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if s.scanner == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "scanner not available")
+		return
+	}
+
+	includePEM := false
+	if value := r.URL.Query().Get("include_pem"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "include_pem must be a boolean")
+			return
+		}
+		includePEM = parsed
+	}
+
+	certs := []certInfoResponse{}
+	now := time.Now()
+	for _, certInfo := range s.scanner.Certificates() {
+		response := newCertInfoResponse(certInfo, now)
+		response.ExpiringSoon = s.scanner.IsExpiringSoon(certInfo)
+
+		if includePEM {
+			pemData, err := s.scanner.CertificatePEM(certInfo)
+			if err != nil {
+				s.logger.Warn("Failed to read certificate PEM",
+					zap.String("path", certInfo.Path),
+					zap.Error(err))
+				response.PEMError = err.Error()
+			}
+			response.PEM = pemData
+		}
+
+		certs = append(certs, response)
+	}
+
+	s.writeJSON(w, http.StatusOK, certs)
+}
+
+// handleCertSearch lists the certificates from the last scan whose common
+// name contains cn, ignoring case. With exact=true the common name must match
+// cn exactly, still ignoring case.
+func (s *Server) handleCertSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if s.scanner == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "scanner not available")
+		return
+	}
+
+	query := r.URL.Query()
+	cn := query.Get("cn")
+	if cn == "" {
+		s.writeError(w, http.StatusBadRequest, "cn parameter is required")
+		return
+	}
+
+	exact := false
+	if value := query.Get("exact"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "exact must be a boolean")
+			return
+		}
+		exact = parsed
+	}
+
+	certs := []certInfoResponse{}
+	now := time.Now()
+	needle := strings.ToLower(cn)
+	for _, certInfo := range s.scanner.Certificates() {
+		commonName := strings.ToLower(certInfo.CommonName())
+		if exact && commonName != needle || !exact && !strings.Contains(commonName, needle) {
+			continue
+		}
+
+		response := newCertInfoResponse(certInfo, now)
+		response.ExpiringSoon = s.scanner.IsExpiringSoon(certInfo)
+		certs = append(certs, response)
+	}
+
+	if len(certs) == 0 {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("no certificates match common name %q", cn))
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, certs)
+}
+
+// newCertInfoResponse builds the response entry for a certificate
+func newCertInfoResponse(certInfo *scanner.CertificateInfo, now time.Time) certInfoResponse {
+	dnsNames := certInfo.DNSNames
+	if dnsNames == nil {
+		dnsNames = []string{}
+	}
+	ipAddresses := certInfo.IPAddresses
+	if ipAddresses == nil {
+		ipAddresses = []string{}
+	}
+
+	return certInfoResponse{
+		Path:               certInfo.Path,
+		CommonName:         certInfo.CommonName(),
+		Subject:            certInfo.Subject,
+		Issuer:             certInfo.Issuer,
+		SerialNumber:       certInfo.SerialNumber,
+		Fingerprint:        certInfo.Fingerprint,
+		SPKISHA256:         certInfo.SPKISHA256,
+		NotBefore:          certInfo.NotBefore,
+		NotAfter:           certInfo.NotAfter,
+		SignatureAlgorithm: certInfo.SignatureAlgorithm,
+		KeySize:            certInfo.KeySize,
+		DNSNames:           dnsNames,
+		IPAddresses:        ipAddresses,
+		IsExpired:          !now.Before(certInfo.NotAfter),
+	}
+}
@@ -0,0 +1,93 @@
+// internal/server/debugscan.go
+
+package server
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/scanner"
+)
+
+// debugScanFile is what a debug scan made of one file
+type debugScanFile struct {
+	Path        string            `json:"path"`
+	Status      string            `json:"status"`
+	Reason      string            `json:"reason,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	Certificate *certInfoResponse `json:"certificate,omitempty"`
+}
+
+// debugScanResponse is the result of a debug scan
+type debugScanResponse struct {
+	Dir      string          `json:"dir"`
+	Duration string          `json:"duration"`
+	Files    []debugScanFile `json:"files"`
+}
+
+// handleDebugScan scans a directory inside the monitored directories in the
+// request and reports what became of every file in it, including those the
+// regular scans ignore or fail to parse
+func (s *Server) handleDebugScan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if s.scanner == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "scanner not available")
+		return
+	}
+
+	dir := r.URL.Query().Get("dir")
+	if dir == "" {
+		s.writeError(w, http.StatusBadRequest, "dir parameter is required")
+		return
+	}
+
+	// Only allow directories inside the monitored directories
+	dir = filepath.Clean(dir)
+	if !s.getConfig().IsPathAllowed(dir) {
+		s.writeError(w, http.StatusForbidden, "dir is outside the monitored directories")
+		return
+	}
+
+	startTime := time.Now()
+	results, err := s.scanner.ScanDirectory(r.Context(), dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			s.writeError(w, http.StatusNotFound, "dir not found")
+			return
+		}
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := debugScanResponse{
+		Dir:      dir,
+		Duration: time.Since(startTime).String(),
+		Files:    []debugScanFile{},
+	}
+	now := time.Now()
+	for _, result := range results {
+		file := debugScanFile{
+			Path:   result.Path,
+			Status: result.Status,
+			Reason: result.Reason,
+		}
+		if result.Err != nil {
+			file.Error = result.Err.Error()
+		}
+		if result.Status == scanner.FileParsed {
+			certInfo := newCertInfoResponse(result.Certificate, now)
+			certInfo.ExpiringSoon = s.scanner.IsExpiringSoon(result.Certificate)
+			file.Certificate = &certInfo
+		}
+		response.Files = append(response.Files, file)
+	}
+
+	s.writeJSON(w, http.StatusOK, response)
+}
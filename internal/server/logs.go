@@ -0,0 +1,41 @@
+// internal/server/logs.go
+
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/logbuffer"
+)
+
+// handleDebugLogs returns the most recent log entries, oldest first. The
+// limit parameter keeps only the last entries.
+func (s *Server) handleDebugLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if s.logs == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "log buffer not available")
+		return
+	}
+
+	entries := s.logs.Entries()
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			s.writeError(w, http.StatusBadRequest, "limit must be a non-negative integer")
+			return
+		}
+		if limit < len(entries) {
+			entries = entries[len(entries)-limit:]
+		}
+	}
+	if entries == nil {
+		entries = []logbuffer.Entry{}
+	}
+
+	s.writeJSON(w, http.StatusOK, entries)
+}
@@ -0,0 +1,20 @@
+// internal/server/pprof.go
+
+package server
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// registerPprof adds the runtime profiling endpoints under /debug/pprof/ to
+// mux, behind the auth token. Importing net/http/pprof also registers them on
+// http.DefaultServeMux, which the server never serves, so they are only
+// reachable here while enable_pprof is on.
+func (s *Server) registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", s.requireEnabled(pprofEnabled, s.requireToken(pprof.Index)))
+	mux.HandleFunc("/debug/pprof/cmdline", s.requireEnabled(pprofEnabled, s.requireToken(pprof.Cmdline)))
+	mux.HandleFunc("/debug/pprof/profile", s.requireEnabled(pprofEnabled, s.requireToken(pprof.Profile)))
+	mux.HandleFunc("/debug/pprof/symbol", s.requireEnabled(pprofEnabled, s.requireToken(pprof.Symbol)))
+	mux.HandleFunc("/debug/pprof/trace", s.requireEnabled(pprofEnabled, s.requireToken(pprof.Trace)))
+}
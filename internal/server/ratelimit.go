@@ -0,0 +1,84 @@
+// internal/server/ratelimit.go
+
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/config"
+)
+
+// rateLimiter is a token bucket refilled at a fixed rate per second and
+// holding up to burst tokens
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a full bucket allowing rate requests per second,
+// with bursts of up to one second's worth of requests
+func newRateLimiter(rate float64) *rateLimiter {
+	burst := math.Max(1, math.Ceil(rate))
+	return &rateLimiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// allow takes a token if one is available. Otherwise it returns how long
+// until the next token.
+func (l *rateLimiter) allow() (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// newDiskLimiter creates the limiter shared by the endpoints reading
+// certificate files, or nil when disk_endpoint_rate_limit is off
+func newDiskLimiter(cfg *config.Config) *rateLimiter {
+	if cfg.DiskEndpointRateLimit <= 0 {
+		return nil
+	}
+	return newRateLimiter(cfg.DiskEndpointRateLimit)
+}
+
+// rateLimit rejects requests with 429 Too Many Requests once the disk
+// endpoint limiter runs dry. Requests pass through unchanged without a
+// limiter.
+func (s *Server) rateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		limiter := s.diskLimiter
+		s.mu.RUnlock()
+		if limiter == nil {
+			next(w, r)
+			return
+		}
+
+		if ok, wait := limiter.allow(); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			s.writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+
+		next(w, r)
+	}
+}
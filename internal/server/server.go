@@ -2,20 +2,39 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/brandonhon/tls-cert-monitor/internal/cert"
 	"github.com/brandonhon/tls-cert-monitor/internal/config"
 	"github.com/brandonhon/tls-cert-monitor/internal/health"
+	"github.com/brandonhon/tls-cert-monitor/internal/logbuffer"
 	"github.com/brandonhon/tls-cert-monitor/internal/metrics"
+	"github.com/brandonhon/tls-cert-monitor/internal/scanner"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 )
 
+// Scanner is the part of the certificate scanner used by the server
+type Scanner interface {
+	Scan(ctx context.Context) error
+	ClearCache() int
+	Certificates() []*scanner.CertificateInfo
+	CertificatePEM(certInfo *scanner.CertificateInfo) (string, error)
+	IsExpiringSoon(certInfo *scanner.CertificateInfo) bool
+	ScanDirectory(ctx context.Context, dir string) ([]scanner.FileResult, error)
+}
+
 // Server represents the HTTP server
 type Server struct {
 	config   *config.Config
@@ -24,47 +43,130 @@ type Server struct {
 	logger   *zap.Logger
 	server   *http.Server
 	registry *prometheus.Registry
+	scanner  Scanner
+	logs     *logbuffer.Buffer
+
+	// mu guards config and diskLimiter, replaced on a configuration reload
+	mu          sync.RWMutex
+	diskLimiter *rateLimiter
+
+	// Background work started by requests, such as the rescan after a cache
+	// clear, canceled and waited for by Shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
 // New creates a new HTTP server
 func New(cfg *config.Config, metrics *metrics.Collector, health *health.Checker, logger *zap.Logger) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Server{
-		config:   cfg,
-		metrics:  metrics,
-		health:   health,
-		logger:   logger,
-		registry: nil, // Will use default prometheus.Handler()
+		config:      cfg,
+		metrics:     metrics,
+		health:      health,
+		logger:      logger,
+		registry:    nil, // Will use default prometheus.Handler()
+		diskLimiter: newDiskLimiter(cfg),
+		ctx:         ctx,
+		cancel:      cancel,
 	}
 }
 
 // NewWithRegistry creates a new HTTP server with a custom registry
 func NewWithRegistry(cfg *config.Config, metrics *metrics.Collector, health *health.Checker, logger *zap.Logger, registry *prometheus.Registry) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Server{
-		config:   cfg,
-		metrics:  metrics,
-		health:   health,
-		logger:   logger,
-		registry: registry,
+		config:      cfg,
+		metrics:     metrics,
+		health:      health,
+		logger:      logger,
+		registry:    registry,
+		diskLimiter: newDiskLimiter(cfg),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+// UpdateConfig applies a reloaded configuration to /config, the auth token,
+// the disk endpoint rate limit and the endpoints enabled by configuration.
+// The listen address, TLS and metrics format take effect on restart.
+func (s *Server) UpdateConfig(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cfg.DiskEndpointRateLimit != s.config.DiskEndpointRateLimit {
+		s.diskLimiter = newDiskLimiter(cfg)
 	}
+	s.config = cfg
+}
+
+// getConfig returns the current configuration
+func (s *Server) getConfig() *config.Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
+// SetScanner sets the scanner used by the cache management, certificate and alerts endpoints
+func (s *Server) SetScanner(scanner Scanner) {
+	s.scanner = scanner
+}
+
+// SetLogBuffer sets the buffer of recent log entries served on /debug/logs
+func (s *Server) SetLogBuffer(logs *logbuffer.Buffer) {
+	s.logs = logs
 }
 
 // Start starts the HTTP server
 func (s *Server) Start() error {
+	cfg := s.getConfig()
 	mux := http.NewServeMux()
 
 	// Health check endpoint
 	mux.HandleFunc("/healthz", s.handleHealth)
 
-	// Metrics endpoint - use HandlerFor with the custom registry if provided
+	// Metrics endpoint - use HandlerFor with the custom registry if provided.
+	// OpenMetrics is served to scrapers asking for it when enabled.
 	if s.registry != nil {
 		mux.Handle("/metrics", promhttp.HandlerFor(
 			s.registry,
 			promhttp.HandlerOpts{
-				ErrorHandling: promhttp.ContinueOnError,
+				ErrorHandling:     promhttp.ContinueOnError,
+				EnableOpenMetrics: cfg.EnableOpenMetrics,
 			},
 		))
 	} else {
-		mux.Handle("/metrics", promhttp.Handler())
+		mux.Handle("/metrics", promhttp.InstrumentMetricHandler(
+			prometheus.DefaultRegisterer,
+			promhttp.HandlerFor(
+				prometheus.DefaultGatherer,
+				promhttp.HandlerOpts{EnableOpenMetrics: cfg.EnableOpenMetrics},
+			),
+		))
+	}
+
+	// Hostname verification endpoint
+	mux.HandleFunc("/verify", s.rateLimit(s.handleVerify))
+
+	// Cache management endpoint
+	mux.HandleFunc("/cache/clear", s.requireToken(s.handleCacheClear))
+
+	// Effective configuration endpoint
+	mux.HandleFunc("/config", s.requireToken(s.handleConfig))
+
+	// Certificate inventory endpoint
+	mux.HandleFunc("/certs", s.requireToken(s.rateLimit(s.handleCerts)))
+	mux.HandleFunc("/certs/search", s.requireToken(s.handleCertSearch))
+
+	// Alertmanager-style alerts endpoint
+	mux.HandleFunc("/alerts", s.requireEnabled(alertsEnabled, s.requireToken(s.handleAlerts)))
+
+	// Runtime profiling and troubleshooting endpoints
+	s.registerPprof(mux)
+	mux.HandleFunc("/debug/scan", s.requireEnabled(pprofEnabled, s.requireToken(s.rateLimit(s.handleDebugScan))))
+	mux.HandleFunc("/debug/logs", s.requireEnabled(pprofEnabled, s.requireToken(s.handleDebugLogs)))
+	if cfg.EnablePprof {
+		s.logger.Warn("Debug endpoints enabled on /debug/pprof/, /debug/scan and /debug/logs")
 	}
 
 	// Root endpoint
@@ -72,7 +174,7 @@ func (s *Server) Start() error {
 
 	// Create server
 	s.server = &http.Server{
-		Addr:         fmt.Sprintf("%s:%d", s.config.BindAddress, s.config.Port),
+		Addr:         fmt.Sprintf("%s:%d", cfg.BindAddress, cfg.Port),
 		Handler:      s.loggingMiddleware(mux),
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
@@ -80,21 +182,35 @@ func (s *Server) Start() error {
 	}
 
 	// Configure TLS if certificates are provided
-	if s.config.TLSCert != "" && s.config.TLSKey != "" {
+	if cfg.TLSCert != "" && cfg.TLSKey != "" {
 		tlsConfig := &tls.Config{
 			MinVersion:               tls.VersionTLS12,
 			CurvePreferences:         []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
 			PreferServerCipherSuites: true,
 			CipherSuites: []uint16{
 				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+				// Required by HTTP/2, which refuses to start without it
+				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
 				tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
 				tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
 				tls.TLS_RSA_WITH_AES_256_CBC_SHA,
 			},
 		}
 
+		// Loaded here rather than by ListenAndServeTLS so the expiry of the
+		// certificate actually served can be exported. The files are read
+		// once, so a renewed certificate is only served after a restart.
+		certificate, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+		if leaf, err := x509.ParseCertificate(certificate.Certificate[0]); err == nil {
+			s.metrics.SetSelfCertExpiration(cfg.TLSCert, float64(leaf.NotAfter.Unix()))
+		}
+
 		s.server.TLSConfig = tlsConfig
-		return s.server.ListenAndServeTLS(s.config.TLSCert, s.config.TLSKey)
+		return s.server.ListenAndServeTLS("", "")
 	}
 
 	return s.server.ListenAndServe()
@@ -102,10 +218,27 @@ func (s *Server) Start() error {
 
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
-	if s.server == nil {
-		return nil
+	s.cancel()
+
+	var err error
+	if s.server != nil {
+		err = s.server.Shutdown(ctx)
 	}
-	return s.server.Shutdown(ctx)
+
+	// Wait for background work to observe the cancellation
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	return err
 }
 
 // loggingMiddleware logs HTTP requests
@@ -151,6 +284,7 @@ func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cfg := s.getConfig()
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	fmt.Fprintf(w, `<!DOCTYPE html>
 <html>
@@ -208,6 +342,42 @@ func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
             <strong><a href="/healthz">/healthz</a></strong><br>
             Health check endpoint with detailed system status
         </div>
+        <div class="endpoint">
+            <strong>/verify?file=&lt;path&gt;&amp;name=&lt;host&gt;</strong><br>
+            Check whether a certificate covers a hostname or IP address
+        </div>
+        <div class="endpoint">
+            <strong>POST /cache/clear</strong><br>
+            Clear the certificate cache and trigger a full rescan
+        </div>
+        <div class="endpoint">
+            <strong><a href="/config">/config</a></strong><br>
+            Effective configuration with the source of each setting
+        </div>
+        <div class="endpoint">
+            <strong><a href="/certs">/certs</a></strong><br>
+            Certificates found by the last scan; add <code>?include_pem=true</code> to embed each leaf
+        </div>
+        <div class="endpoint">
+            <strong>/certs/search?cn=&lt;name&gt;</strong><br>
+            Certificates whose common name contains the name; add <code>&amp;exact=true</code> for an exact match
+        </div>
+        <div class="endpoint">
+            <strong>/alerts</strong><br>
+            Expiring and expired certificates as Alertmanager alerts (when enabled)
+        </div>
+        <div class="endpoint">
+            <strong>/debug/pprof/</strong><br>
+            Go runtime profiles (when enabled)
+        </div>
+        <div class="endpoint">
+            <strong>POST /debug/scan?dir=&lt;path&gt;</strong><br>
+            Scan a monitored directory now and report every file in it (when enabled)
+        </div>
+        <div class="endpoint">
+            <strong>/debug/logs</strong><br>
+            Recent log entries as JSON (when enabled)
+        </div>
         <h2>Configuration</h2>
         <div class="endpoint">
             <strong>Port:</strong> <code>%d</code><br>
@@ -219,11 +389,11 @@ func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
     </div>
 </body>
 </html>`,
-		s.config.Port,
-		s.config.TLSCert != "" && s.config.TLSKey != "",
-		s.config.Workers,
-		s.config.ScanInterval,
-		s.config.CertificateDirectories,
+		cfg.Port,
+		cfg.TLSCert != "" && cfg.TLSKey != "",
+		cfg.Workers,
+		cfg.ScanInterval,
+		cfg.CertificateDirectories,
 	)
 }
 
@@ -248,3 +418,183 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		s.logger.Error("Failed to encode health response", zap.Error(err))
 	}
 }
+
+// verifyResponse is the result of a hostname verification
+type verifyResponse struct {
+	File        string   `json:"file"`
+	Name        string   `json:"name"`
+	Matched     bool     `json:"matched"`
+	MatchedSANs []string `json:"matched_sans"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// handleVerify checks whether a certificate file covers the requested hostname
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	file := r.URL.Query().Get("file")
+	name := r.URL.Query().Get("name")
+	if file == "" || name == "" {
+		s.writeError(w, http.StatusBadRequest, "file and name parameters are required")
+		return
+	}
+
+	// Only allow files inside the monitored directories
+	file = filepath.Clean(file)
+	if !s.getConfig().IsPathAllowed(file) {
+		s.writeError(w, http.StatusForbidden, "file is outside the monitored directories")
+		return
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			s.writeError(w, http.StatusNotFound, "file not found")
+			return
+		}
+		s.writeError(w, http.StatusInternalServerError, "failed to read file")
+		return
+	}
+
+	leaf, err := cert.Parse(data)
+	if err != nil {
+		s.writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	response := verifyResponse{
+		File:        file,
+		Name:        name,
+		MatchedSANs: cert.MatchingSANs(leaf, name),
+	}
+	if err := leaf.VerifyHostname(name); err != nil {
+		response.Error = err.Error()
+	} else {
+		response.Matched = true
+	}
+	if response.MatchedSANs == nil {
+		response.MatchedSANs = []string{}
+	}
+
+	s.writeJSON(w, http.StatusOK, response)
+}
+
+// requireToken rejects requests without the configured bearer token.
+// Requests pass through unchanged when no token is configured.
+func (s *Server) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authToken := s.getConfig().AuthToken
+		if authToken == "" {
+			next(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(authToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="tls-cert-monitor"`)
+			s.writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// requireEnabled answers 404 Not Found while the configuration leaves an
+// endpoint disabled, so enabling or disabling it takes effect on reload
+func (s *Server) requireEnabled(enabled func(*config.Config) bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !enabled(s.getConfig()) {
+			http.NotFound(w, r)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// alertsEnabled reports whether /alerts is enabled
+func alertsEnabled(cfg *config.Config) bool {
+	return cfg.EnableAlertsEndpoint
+}
+
+// pprofEnabled reports whether the debug endpoints are enabled
+func pprofEnabled(cfg *config.Config) bool {
+	return cfg.EnablePprof
+}
+
+// cacheClearResponse is the result of clearing the cache
+type cacheClearResponse struct {
+	Cleared       int  `json:"cleared"`
+	RescanStarted bool `json:"rescan_started"`
+}
+
+// handleCacheClear clears the certificate cache and triggers a rescan
+func (s *Server) handleCacheClear(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if s.scanner == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "scanner not available")
+		return
+	}
+
+	cleared := s.scanner.ClearCache()
+	s.logger.Info("Certificate cache cleared", zap.Int("entries", cleared))
+
+	// Rescan in the background; a full re-parse can outlast the request
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.scanner.Scan(s.ctx); err != nil {
+			s.logger.Error("Rescan after cache clear failed", zap.Error(err))
+		}
+	}()
+
+	s.writeJSON(w, http.StatusOK, cacheClearResponse{
+		Cleared:       cleared,
+		RescanStarted: true,
+	})
+}
+
+// configResponse is the effective configuration and where each value came from
+type configResponse struct {
+	ConfigFile string                 `json:"config_file"`
+	Config     map[string]interface{} `json:"config"`
+	Sources    map[string]string      `json:"sources"`
+}
+
+// handleConfig returns the effective configuration with secrets redacted
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	cfg := s.getConfig()
+	s.writeJSON(w, http.StatusOK, configResponse{
+		ConfigFile: cfg.ConfigFile(),
+		Config:     cfg.Effective(),
+		Sources:    cfg.Sources(),
+	})
+}
+
+// writeJSON writes a JSON response with the given status code
+func (s *Server) writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+// writeError writes a JSON error response
+func (s *Server) writeError(w http.ResponseWriter, statusCode int, message string) {
+	s.writeJSON(w, statusCode, map[string]string{"error": message})
+}
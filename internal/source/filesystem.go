@@ -0,0 +1,82 @@
+// internal/source/filesystem.go
+
+package source
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Filesystem walks and reads the files below a local directory. Keys and
+// URLs are the file paths themselves.
+type Filesystem struct {
+	root    string
+	workers int
+}
+
+// NewFilesystem creates a source for the files below root, listing up to
+// workers directories at once (0 or 1 walks serially). A source with an
+// empty root reads any local path but has nothing to walk.
+func NewFilesystem(root string, workers int) *Filesystem {
+	return &Filesystem{root: root, workers: workers}
+}
+
+// Root returns the directory
+func (f *Filesystem) Root() string {
+	return f.root
+}
+
+// URL returns the path of the file at key, which is that path
+func (f *Filesystem) URL(key string) string {
+	return key
+}
+
+// Key returns path, and whether it is inside the directory
+func (f *Filesystem) Key(path string) (string, bool) {
+	if f.root == "" {
+		return path, true
+	}
+
+	rel, err := filepath.Rel(f.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return path, true
+}
+
+// Walk calls fn for the directory and everything below it. With one worker
+// it is filepath.WalkDir; with more, directories are listed concurrently and
+// fn is called concurrently, a directory still coming before its entries.
+func (f *Filesystem) Walk(ctx context.Context, fn WalkFunc) error {
+	walkFn := func(path string, d fs.DirEntry, err error) error {
+		object := Object{Key: path, entry: d}
+		if d != nil {
+			object.Dir = d.IsDir()
+		}
+		return fn(object, err)
+	}
+
+	if f.workers <= 1 {
+		return filepath.WalkDir(f.root, walkFn)
+	}
+	return walkParallel(ctx, f.root, f.workers, walkFn)
+}
+
+// Read returns the content of the file at path
+func (f *Filesystem) Read(ctx context.Context, path string, limit int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if limit > 0 {
+		reader = io.LimitReader(file, limit)
+	}
+	return io.ReadAll(reader)
+}
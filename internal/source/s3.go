@@ -0,0 +1,180 @@
+// internal/source/s3.go
+
+package source
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// S3 lists and reads objects below a prefix of an S3 bucket. Requests use
+// path-style URLs, so S3-compatible stores such as MinIO work as well.
+type S3 struct {
+	bucket   string
+	prefix   string
+	region   string
+	endpoint string
+	creds    Credentials
+	client   *http.Client
+}
+
+// NewS3 creates a source for the objects below prefix in bucket, sending
+// requests with client. An empty endpoint selects the AWS endpoint of region.
+// Credentials are taken from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN; without them requests are sent unsigned, which suits
+// public buckets.
+func NewS3(bucket, prefix, region, endpoint string, client *http.Client) *S3 {
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+
+	return &S3{
+		bucket:   bucket,
+		prefix:   prefix,
+		region:   region,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		creds: Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		client: client,
+	}
+}
+
+// Root returns the s3:// URL of the bucket prefix
+func (s *S3) Root() string {
+	return s.URL(s.prefix)
+}
+
+// URL returns the s3:// URL of the object stored under key
+func (s *S3) URL(key string) string {
+	return "s3://" + s.bucket + "/" + key
+}
+
+// Key returns the key of the object at an s3:// URL of the bucket
+func (s *S3) Key(objectURL string) (string, bool) {
+	key, ok := strings.CutPrefix(objectURL, "s3://"+s.bucket+"/")
+	return key, ok && strings.HasPrefix(key, s.prefix)
+}
+
+// listBucketResult is the ListObjectsV2 response
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		ETag         string    `xml:"ETag"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// Walk calls fn for each object below the prefix, following continuation
+// tokens until the listing is complete. A flat listing has no directories, so
+// fs.SkipDir from fn only skips the rest of the current page.
+func (s *S3) Walk(ctx context.Context, fn WalkFunc) error {
+	var token string
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if s.prefix != "" {
+			query.Set("prefix", s.prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		body, err := s.get(ctx, "/"+s.bucket, query, 0)
+		if err != nil {
+			return fmt.Errorf("failed to list s3://%s/%s: %w", s.bucket, s.prefix, err)
+		}
+
+		var result listBucketResult
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return fmt.Errorf("failed to decode listing of s3://%s/%s: %w", s.bucket, s.prefix, err)
+		}
+
+	page:
+		for _, content := range result.Contents {
+			// Keys ending in a slash are folder placeholders
+			if strings.HasSuffix(content.Key, "/") {
+				continue
+			}
+			err := fn(Object{
+				Key:          content.Key,
+				ETag:         strings.Trim(content.ETag, `"`),
+				Size:         content.Size,
+				LastModified: content.LastModified,
+			}, nil)
+			switch {
+			case errors.Is(err, fs.SkipDir):
+				break page
+			case errors.Is(err, fs.SkipAll):
+				return nil
+			case err != nil:
+				return err
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Read returns the content of the object stored under key
+func (s *S3) Read(ctx context.Context, key string, limit int64) ([]byte, error) {
+	data, err := s.get(ctx, "/"+s.bucket+"/"+key, nil, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.URL(key), err)
+	}
+	return data, nil
+}
+
+// get sends a GET request for path and returns the response body, reading
+// at most limit bytes when limit is positive
+func (s *S3) get(ctx context.Context, path string, query url.Values, limit int64) ([]byte, error) {
+	u, err := url.Parse(s.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.creds.AccessKeyID != "" {
+		SignV4(req, s.creds, s.region, "s3", time.Now())
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Drain the body so the connection can be reused
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("s3 returned status %d", resp.StatusCode)
+	}
+
+	var reader io.Reader = resp.Body
+	if limit > 0 {
+		reader = io.LimitReader(resp.Body, limit)
+	}
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,66 @@
+// internal/source/source.go
+
+package source
+
+import (
+	"context"
+	"io/fs"
+	"time"
+)
+
+// Object is a file or directory walked by a Source
+type Object struct {
+	Key          string
+	ETag         string
+	Size         int64
+	LastModified time.Time
+
+	// Dir marks a directory of a hierarchical source; returning fs.SkipDir
+	// for it skips its contents
+	Dir bool
+
+	// entry is the directory entry of a local file, statted on demand
+	entry fs.DirEntry
+}
+
+// ModTime returns when the object was last modified, and whether that is
+// known. A local file is only statted when asked.
+func (o Object) ModTime() (time.Time, bool) {
+	if o.entry != nil {
+		info, err := o.entry.Info()
+		if err != nil {
+			return time.Time{}, false
+		}
+		return info.ModTime(), true
+	}
+	return o.LastModified, !o.LastModified.IsZero()
+}
+
+// WalkFunc is called by Source.Walk for each object, like fs.WalkDirFunc: a
+// non-nil err reports that object.Key couldn't be listed or reached.
+// Returning fs.SkipDir skips a directory, or the rest of the directory
+// holding a file; fs.SkipAll ends the walk and any other error aborts it.
+type WalkFunc func(object Object, err error) error
+
+// Source lists and reads certificate files: a local directory, or a
+// location in an object store. Each object is identified to the scanner by
+// its URL, which is the file path for local files and stands in for it in
+// results and metric labels otherwise.
+type Source interface {
+	// Root returns the URL of the location the source walks
+	Root() string
+
+	// URL returns the URL of the object stored under key
+	URL(key string) string
+
+	// Key returns the key of the object at url, and whether url belongs
+	// to the source
+	Key(url string) (string, bool)
+
+	// Walk calls fn for each object below the source's root
+	Walk(ctx context.Context, fn WalkFunc) error
+
+	// Read returns the content of the object stored under key, reading at
+	// most limit bytes when limit is positive
+	Read(ctx context.Context, key string, limit int64) ([]byte, error)
+}
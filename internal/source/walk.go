@@ -0,0 +1,145 @@
+// internal/source/walk.go
+
+package source
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// walkParallel walks the tree below root like filepath.WalkDir, but lists up
+// to workers directories concurrently, so trees with millions of files aren't
+// bound by one goroutine waiting on directory reads and stats. fn is called
+// concurrently and in no particular order beyond a directory coming before its
+// entries. fs.SkipDir from fn skips a directory, or the rest of the directory
+// holding a file; any other error stops the walk and is returned.
+func walkParallel(ctx context.Context, root string, workers int, fn fs.WalkDirFunc) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		if err := fn(root, nil, err); !errors.Is(err, fs.SkipDir) && !errors.Is(err, fs.SkipAll) {
+			return err
+		}
+		return nil
+	}
+
+	err = fn(root, fs.FileInfoToDirEntry(info), nil)
+	if errors.Is(err, fs.SkipDir) || errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	if err != nil || !info.IsDir() {
+		return err
+	}
+
+	w := &parallelWalk{fn: fn, queue: []walkItem{{path: root, entry: fs.FileInfoToDirEntry(info)}}}
+	w.cond = sync.NewCond(&w.mu)
+
+	// Wake waiting workers on cancellation; fn sees the context and stops
+	stop := context.AfterFunc(ctx, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.cond.Broadcast()
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work(ctx)
+		}()
+	}
+	wg.Wait()
+
+	if errors.Is(w.err, fs.SkipAll) {
+		return nil
+	}
+	return w.err
+}
+
+// walkItem is a directory waiting to be listed
+type walkItem struct {
+	path  string
+	entry fs.DirEntry
+}
+
+// parallelWalk is the shared state of the workers of walkParallel
+type parallelWalk struct {
+	fn fs.WalkDirFunc
+
+	// Directories left to list, directories being listed, and the error
+	// that stopped the walk
+	queue  []walkItem
+	active int
+	err    error
+
+	mu   sync.Mutex
+	cond *sync.Cond
+}
+
+// work lists queued directories until none are left or the walk stops
+func (w *parallelWalk) work(ctx context.Context) {
+	for {
+		w.mu.Lock()
+		for len(w.queue) == 0 && w.active > 0 && w.err == nil && ctx.Err() == nil {
+			w.cond.Wait()
+		}
+		if len(w.queue) == 0 || w.err != nil || ctx.Err() != nil {
+			w.cond.Broadcast()
+			w.mu.Unlock()
+			return
+		}
+		item := w.queue[len(w.queue)-1]
+		w.queue = w.queue[:len(w.queue)-1]
+		w.active++
+		w.mu.Unlock()
+
+		subdirs, err := w.list(item)
+
+		w.mu.Lock()
+		w.active--
+		w.queue = append(w.queue, subdirs...)
+		if err != nil && w.err == nil {
+			w.err = err
+		}
+		w.cond.Broadcast()
+		w.mu.Unlock()
+	}
+}
+
+// list calls fn for each entry of a directory and returns the subdirectories
+// to descend into
+func (w *parallelWalk) list(dir walkItem) ([]walkItem, error) {
+	entries, err := os.ReadDir(dir.path)
+	if err != nil {
+		// As with WalkDir, fn sees the directory again with the error and
+		// whatever entries were read are still walked
+		if err := w.fn(dir.path, dir.entry, err); err != nil {
+			if errors.Is(err, fs.SkipDir) {
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+
+	var subdirs []walkItem
+	for _, entry := range entries {
+		path := filepath.Join(dir.path, entry.Name())
+		err := w.fn(path, entry, nil)
+		switch {
+		case errors.Is(err, fs.SkipDir):
+			if !entry.IsDir() {
+				return subdirs, nil
+			}
+		case err != nil:
+			return nil, err
+		case entry.IsDir():
+			subdirs = append(subdirs, walkItem{path: path, entry: entry})
+		}
+	}
+	return subdirs, nil
+}
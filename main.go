@@ -1,21 +1,34 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/brandonhon/tls-cert-monitor/internal/config"
+	"github.com/brandonhon/tls-cert-monitor/internal/fileutil"
 	"github.com/brandonhon/tls-cert-monitor/internal/health"
+	"github.com/brandonhon/tls-cert-monitor/internal/httpclient"
+	"github.com/brandonhon/tls-cert-monitor/internal/logbuffer"
 	"github.com/brandonhon/tls-cert-monitor/internal/logger"
 	"github.com/brandonhon/tls-cert-monitor/internal/metrics"
 	"github.com/brandonhon/tls-cert-monitor/internal/scanner"
 	"github.com/brandonhon/tls-cert-monitor/internal/server"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var (
@@ -29,6 +42,11 @@ func main() {
 		configFile  = flag.String("config", "", "Path to configuration file")
 		showVersion = flag.Bool("version", false, "Show version information")
 		dryRun      = flag.Bool("dry-run", false, "Run in dry-run mode (validate config only)")
+		checkConfig = flag.Bool("check-config", false, "Validate configuration, report all problems and exit")
+		once        = flag.Bool("once", false, "Run a single scan with metrics and exports enabled, then exit")
+		fromStdin   = flag.Bool("stdin", false, "With --dry-run, check a certificate read from stdin and print a report")
+		output      = flag.String("output", "table", "Report format for --stdin: table or json")
+		printSchema = flag.Bool("print-config-schema", false, "Print a JSON Schema of the configuration file and exit")
 	)
 	flag.Parse()
 
@@ -38,13 +56,35 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *printSchema {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(config.Schema()); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to print configuration schema: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// Initialize configuration
 	cfg, err := config.Load(*configFile)
+	if *checkConfig {
+		os.Exit(reportConfigCheck(err))
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
 		os.Exit(1)
 	}
 
+	// Check a certificate from stdin and exit, keeping stdout for the report
+	if *fromStdin {
+		if !*dryRun && !cfg.DryRun {
+			fmt.Fprintln(os.Stderr, "--stdin requires --dry-run")
+			os.Exit(2)
+		}
+		os.Exit(inspectStdin(cfg, *output))
+	}
+
 	// Initialize logger
 	log, err := logger.New(cfg.LogFile, cfg.LogLevel)
 	if err != nil {
@@ -53,6 +93,25 @@ func main() {
 	}
 	defer log.Sync()
 
+	// Keep recent log entries in memory for /debug/logs
+	var logs *logbuffer.Buffer
+	if cfg.EnablePprof {
+		logs = logbuffer.New(logbuffer.DefaultSize)
+		log = log.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, logs.Core(core))
+		}))
+	}
+
+	for _, warning := range cfg.Warnings() {
+		log.Warn("Configuration warning", zap.String("warning", warning))
+	}
+
+	// Everything the process actually loaded, after file and environment
+	// overrides, with secrets redacted
+	log.Info("Effective configuration",
+		zap.String("config_file", cfg.ConfigFile()),
+		zap.Any("config", cfg.Effective()))
+
 	// Dry run mode - validate and exit
 	if *dryRun || cfg.DryRun {
 		log.Info("Dry run mode - configuration validated successfully")
@@ -64,7 +123,13 @@ func main() {
 	defer cancel()
 
 	// Initialize metrics collector
-	metricsCollector := metrics.NewCollector()
+	metricsCollector := metrics.NewCollectorWithOptions(prometheus.DefaultRegisterer, metrics.Options{
+		Prefix:        cfg.MetricsPrefix,
+		DirLabel:      cfg.MetricsDirLabel,
+		ExpiryBuckets: cfg.ExpiryHistogramBuckets,
+	})
+	metricsCollector.SetBuildInfo(version, gitCommit)
+	httpclient.SetVersion(version)
 
 	// Initialize health checker
 	healthChecker := health.New(cfg, metricsCollector)
@@ -75,22 +140,69 @@ func main() {
 		log.Fatal("Failed to initialize certificate scanner", zap.Error(err))
 	}
 
+	// In single scan mode, cancel the scan on SIGINT or SIGTERM so the results
+	// collected so far are still saved and pushed
+	if *once {
+		certScanner.SetPartialResults(true)
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			sig := <-sigChan
+			log.Info("Received shutdown signal, canceling scan", zap.String("signal", sig.String()))
+			cancel()
+		}()
+	}
+
 	// Start initial scan
 	log.Info("Starting initial certificate scan")
-	if err := certScanner.Scan(ctx); err != nil {
-		log.Error("Initial scan failed", zap.Error(err))
+	scanErr := certScanner.Scan(ctx)
+	if scanErr != nil && !errors.Is(scanErr, scanner.ErrPartialScan) {
+		log.Error("Initial scan failed", zap.Error(scanErr))
+	}
+
+	// Single scan mode - skip the watchers and server, flush and exit
+	if *once {
+		waitCtx, waitCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := certScanner.Wait(waitCtx); err != nil {
+			log.Warn("Timed out waiting for running scans to stop", zap.Error(err))
+		}
+		waitCancel()
+		certScanner.Close()
+
+		// Pushing replaces the whole group, so a scan that published nothing
+		// would wipe the metrics of the previous run
+		switch {
+		case cfg.PushgatewayURL == "":
+		case scanErr != nil && !errors.Is(scanErr, scanner.ErrPartialScan):
+			log.Warn("Skipping the Pushgateway push, the scan didn't publish any results")
+		default:
+			pushMetrics(cfg, log)
+		}
+		log.Info("Single scan complete")
+		return
+	}
+
+	// Initialize the HTTP server; configuration reloads update it
+	srv := server.New(cfg, metricsCollector, healthChecker, log)
+	srv.SetScanner(certScanner)
+	if logs != nil {
+		srv.SetLogBuffer(logs)
 	}
 
 	// Start configuration watcher for hot reload
 	configWatcher := config.NewWatcher(cfg, *configFile, log)
-	go configWatcher.Watch(ctx, func(newCfg *config.Config) {
+	configWatcher.SetReloadErrorHandler(func(error) {
+		metricsCollector.IncConfigReloadErrors()
+	})
+	configWatcher.SetReloadResultHandler(metricsCollector.IncConfigReloads)
+	go configWatcher.Watch(ctx, func(newCfg *config.Config) error {
 		log.Info("Configuration changed, reloading...")
 
-		// Update scanner with new config
+		// Update scanner with new config; the watcher counts a rejection
 		if err := certScanner.UpdateConfig(newCfg); err != nil {
-			log.Error("Failed to update scanner configuration", zap.Error(err))
-			return
+			return fmt.Errorf("failed to update scanner configuration: %w", err)
 		}
+		srv.UpdateConfig(newCfg)
 
 		// Trigger rescan
 		if err := certScanner.Scan(ctx); err != nil {
@@ -99,6 +211,7 @@ func main() {
 
 		// Update health checker
 		healthChecker.UpdateConfig(newCfg)
+		return nil
 	})
 
 	// Start certificate file watcher
@@ -114,6 +227,11 @@ func main() {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
+				// Spread scans of instances sharing a certificate store
+				if err := certScanner.Jitter(ctx); err != nil {
+					return
+				}
+
 				log.Debug("Running periodic certificate scan")
 				if err := certScanner.Scan(ctx); err != nil {
 					log.Error("Periodic scan failed", zap.Error(err))
@@ -122,9 +240,6 @@ func main() {
 		}
 	}()
 
-	// Initialize and start HTTP server
-	srv := server.New(cfg, metricsCollector, healthChecker, log)
-
 	// Start server in goroutine
 	serverErrors := make(chan error, 1)
 	go func() {
@@ -139,6 +254,20 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// Dump the certificate cache for debugging on SIGUSR1, where supported
+	dumpChan := make(chan os.Signal, 1)
+	notifyCacheDump(dumpChan)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-dumpChan:
+				dumpCache(cfg.CacheDir, certScanner, log)
+			}
+		}
+	}()
+
 	// Wait for shutdown signal or server error
 	select {
 	case sig := <-sigChan:
@@ -161,8 +290,157 @@ func main() {
 		log.Error("Server shutdown error", zap.Error(err))
 	}
 
+	// Let canceled scans unwind before Close saves the cache
+	if err := certScanner.Wait(shutdownCtx); err != nil {
+		log.Warn("Timed out waiting for running scans to stop", zap.Error(err))
+	}
+
 	// Final cleanup
 	certScanner.Close()
 
 	log.Info("Shutdown complete")
 }
+
+// pushTimeout bounds the push to the Pushgateway at the end of a --once run
+const pushTimeout = 10 * time.Second
+
+// pushMetrics pushes all metrics to the configured Pushgateway, grouped by
+// job and host name. The URL isn't logged as it may carry credentials.
+func pushMetrics(cfg *config.Config, log *zap.Logger) {
+	transport, err := httpclient.NewTransport(httpclient.Options{
+		UserAgent:    cfg.HTTPUserAgent,
+		CABundleFile: cfg.HTTPCABundleFile,
+	})
+	if err != nil {
+		log.Error("Failed to push metrics to the Pushgateway", zap.Error(err))
+		return
+	}
+	instance, _ := os.Hostname()
+
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+
+	client := &http.Client{Transport: transport, Timeout: pushTimeout}
+	if err := metrics.Push(ctx, prometheus.DefaultGatherer, cfg.PushgatewayURL, cfg.PushgatewayJob, instance, client); err != nil {
+		log.Error("Failed to push metrics to the Pushgateway", zap.Error(err))
+		return
+	}
+
+	log.Info("Pushed metrics to the Pushgateway",
+		zap.String("job", cfg.PushgatewayJob),
+		zap.String("instance", instance))
+}
+
+// cacheDumpFile is the file a cache dump is written to
+const cacheDumpFile = "cache-dump.txt"
+
+// dumpCache writes the certificate cache to cacheDumpFile in the cache
+// directory, or in the temp directory when the cache isn't kept on disk
+func dumpCache(dir string, certScanner *scanner.Scanner, log *zap.Logger) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	path := filepath.Join(dir, cacheDumpFile)
+
+	var buf bytes.Buffer
+	if err := certScanner.DumpCache(&buf); err != nil {
+		log.Error("Failed to dump certificate cache", zap.Error(err))
+		return
+	}
+	if err := fileutil.WriteAtomic(path, buf.Bytes(), 0600); err != nil {
+		log.Error("Failed to write certificate cache dump", zap.String("path", path), zap.Error(err))
+		return
+	}
+
+	log.Info("Dumped certificate cache", zap.String("path", path))
+}
+
+// inspectStdin checks the certificate on stdin, prints the report and returns
+// the exit code, which is 1 if the certificate can't be parsed or has problems
+func inspectStdin(cfg *config.Config, format string) int {
+	if format != "table" && format != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown output format %q, expected table or json\n", format)
+		return 2
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read stdin: %v\n", err)
+		return 1
+	}
+
+	inspection, err := scanner.Inspect(cfg, logger.NewNop(), "<stdin>", data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse certificate: %v\n", err)
+		return 1
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(inspection); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to encode report: %v\n", err)
+			return 1
+		}
+	} else {
+		printInspection(inspection)
+	}
+
+	if len(inspection.Problems) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printInspection prints a certificate report as an aligned table
+func printInspection(inspection *scanner.Inspection) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Subject:\t%s\n", inspection.Subject)
+	fmt.Fprintf(w, "Issuer:\t%s (code %d)\n", inspection.Issuer, inspection.IssuerCode)
+	fmt.Fprintf(w, "Serial:\t%s\n", inspection.SerialNumber)
+	fmt.Fprintf(w, "Fingerprint:\t%s\n", inspection.Fingerprint)
+	fmt.Fprintf(w, "SPKI SHA-256:\t%s\n", inspection.SPKISHA256)
+	fmt.Fprintf(w, "Valid:\t%s to %s (%d days left)\n",
+		inspection.NotBefore.UTC().Format(time.RFC3339),
+		inspection.NotAfter.UTC().Format(time.RFC3339),
+		inspection.DaysRemaining)
+	fmt.Fprintf(w, "Signature:\t%s\n", inspection.SignatureAlgorithm)
+	if inspection.KeySize > 0 {
+		fmt.Fprintf(w, "Key size:\t%d\n", inspection.KeySize)
+	}
+	fmt.Fprintf(w, "DNS SANs:\t%s\n", strings.Join(inspection.DNSNames, ", "))
+	fmt.Fprintf(w, "IP SANs:\t%s\n", strings.Join(inspection.IPAddresses, ", "))
+	fmt.Fprintf(w, "Chain depth:\t%d\n", inspection.ChainDepth)
+	fmt.Fprintf(w, "Self-signed:\t%v\n", inspection.IsSelfSigned)
+	w.Flush()
+
+	if len(inspection.Problems) == 0 {
+		fmt.Println("\nNo problems found")
+		return
+	}
+	fmt.Println("\nProblems:")
+	for _, problem := range inspection.Problems {
+		fmt.Printf("  - %s\n", problem)
+	}
+}
+
+// reportConfigCheck prints the result of --check-config and returns the exit code
+func reportConfigCheck(err error) int {
+	if err == nil {
+		fmt.Println("Configuration is valid")
+		return 0
+	}
+
+	var validationErrs config.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "Configuration has %d problem(s):\n", len(validationErrs))
+	for _, validationErr := range validationErrs {
+		fmt.Fprintf(os.Stderr, "  - %s: %s\n", validationErr.Field, validationErr.Message)
+	}
+	return 1
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+// signal_unix.go
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyCacheDump relays SIGUSR1, which requests a cache dump, to c
+func notifyCacheDump(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR1)
+}
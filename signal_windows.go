@@ -0,0 +1,12 @@
+//go:build windows
+
+// signal_windows.go
+
+package main
+
+import (
+	"os"
+)
+
+// notifyCacheDump does nothing on Windows, which has no SIGUSR1
+func notifyCacheDump(c chan<- os.Signal) {}
@@ -0,0 +1,313 @@
+// test/cache_test.go
+
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/cache"
+)
+
+func TestCacheBackupFallback(t *testing.T) {
+	dir := t.TempDir()
+
+	// First save produces cache.gob
+	c, err := cache.New(dir, 30*time.Minute, 10485760)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("first", "value")
+	c.Close()
+
+	// Second save rotates the previous file to cache.gob.bak
+	c, err = cache.New(dir, 30*time.Minute, 10485760)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("second", "value")
+	c.Close()
+
+	cacheFile := filepath.Join(dir, "cache.gob")
+	if _, err := os.Stat(cacheFile + ".bak"); err != nil {
+		t.Fatalf("Expected backup cache file: %v", err)
+	}
+
+	// Corrupt the primary file
+	if err := os.WriteFile(cacheFile, []byte("corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = cache.New(dir, 30*time.Minute, 10485760)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if c.Get("first") == nil {
+		t.Error("Expected entry restored from backup cache file")
+	}
+	if c.Get("second") != nil {
+		t.Error("Expected entry from corrupt cache file to be lost")
+	}
+}
+
+func TestCacheSaveKeepsFileInPlace(t *testing.T) {
+	dir := t.TempDir()
+	cacheFile := filepath.Join(dir, "cache.gob")
+
+	c, err := cache.New(dir, 30*time.Minute, 10485760)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Set("key", 0)
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Backing up the previous version never leaves cache.gob missing
+	done := make(chan struct{})
+	missing := make(chan struct{}, 1)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := os.Stat(cacheFile); os.IsNotExist(err) {
+				select {
+				case missing <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	for i := 1; i <= 100; i++ {
+		c.Set("key", i)
+		if err := c.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+
+	select {
+	case <-missing:
+		t.Error("cache.gob was missing during a save")
+	default:
+	}
+	if _, err := os.Stat(cacheFile + ".bak"); err != nil {
+		t.Errorf("Expected backup cache file: %v", err)
+	}
+}
+
+func TestCacheIndex(t *testing.T) {
+	dir := t.TempDir()
+
+	c, err := cache.New(dir, 30*time.Minute, 10485760)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.SetWithIndex("/certs/old.pem", "digest", "parsed")
+
+	// The index entry survives its path being removed
+	c.Set("/certs/old.pem", nil)
+	if got := c.GetByIndex("digest"); got != "parsed" {
+		t.Errorf("GetByIndex() = %v, want parsed", got)
+	}
+	if got := c.GetByIndex("unknown"); got != nil {
+		t.Errorf("GetByIndex() for unknown key = %v, want nil", got)
+	}
+
+	// The index is rebuilt when the cache is loaded from disk
+	c.SetWithIndex("/certs/new.pem", "digest", "parsed")
+	c.Close()
+
+	c, err = cache.New(dir, 30*time.Minute, 10485760)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if got := c.GetByIndex("digest"); got != "parsed" {
+		t.Errorf("GetByIndex() after reload = %v, want parsed", got)
+	}
+
+	c.Clear()
+	if got := c.GetByIndex("digest"); got != nil {
+		t.Errorf("GetByIndex() after Clear = %v, want nil", got)
+	}
+}
+
+func TestCacheSaveInterval(t *testing.T) {
+	dir := t.TempDir()
+	cacheFile := filepath.Join(dir, "cache.gob")
+
+	c, err := cache.NewWithSaveInterval(dir, 30*time.Minute, 10485760, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// A change is saved without waiting for Close
+	c.Set("first", "value")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(cacheFile); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the cache to be saved periodically")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// An unchanged cache isn't rewritten, so no backup gets rotated in
+	time.Sleep(100 * time.Millisecond)
+	if _, err := os.Stat(cacheFile + ".bak"); !os.IsNotExist(err) {
+		t.Errorf("Expected no rewrite of an unchanged cache, got %v", err)
+	}
+}
+
+func TestCacheSaveWithoutInterval(t *testing.T) {
+	dir := t.TempDir()
+	cacheFile := filepath.Join(dir, "cache.gob")
+
+	c, err := cache.NewWithSaveInterval(dir, 30*time.Minute, 10485760, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Set("first", "value")
+	time.Sleep(50 * time.Millisecond)
+	if _, err := os.Stat(cacheFile); !os.IsNotExist(err) {
+		t.Fatalf("Expected no periodic save with a zero interval, got %v", err)
+	}
+
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := cache.New(dir, 30*time.Minute, 10485760)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloaded.Close()
+
+	if reloaded.Get("first") == nil {
+		t.Error("Expected entry saved by Save to be loaded")
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	c, err := cache.New(t.TempDir(), 30*time.Minute, 10485760)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetWithIndex("/certs/keep.pem", "keep-digest", "keep")
+	c.SetWithIndex("/certs/stale.pem", "stale-digest", "stale")
+
+	pruned := c.Prune(func(_ string, value interface{}) bool {
+		return value == "stale"
+	})
+	if pruned != 1 {
+		t.Errorf("Prune() = %d, want 1", pruned)
+	}
+
+	if got := c.Get("/certs/stale.pem"); got != nil {
+		t.Errorf("Get() for pruned entry = %v, want nil", got)
+	}
+	if got := c.GetByIndex("stale-digest"); got != nil {
+		t.Errorf("GetByIndex() for pruned entry = %v, want nil", got)
+	}
+	if got := c.Get("/certs/keep.pem"); got != "keep" {
+		t.Errorf("Get() for kept entry = %v, want keep", got)
+	}
+}
+
+func TestCacheDelete(t *testing.T) {
+	c, err := cache.New(t.TempDir(), 30*time.Minute, 10485760)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetWithIndex("/certs/removed.pem", "removed-digest", "removed")
+	c.Set("/certs/kept.pem", "kept")
+	c.Delete("/certs/removed.pem")
+	c.Delete("/certs/missing.pem")
+
+	if got := c.Stats()["entries"]; got != 1 {
+		t.Errorf("Expected 1 entry after Delete, got %v", got)
+	}
+	if got := c.Get("/certs/removed.pem"); got != nil {
+		t.Errorf("Get() for deleted entry = %v, want nil", got)
+	}
+
+	// The content index still finds the value of a file renamed away
+	if got := c.GetByIndex("removed-digest"); got != "removed" {
+		t.Errorf("GetByIndex() after Delete = %v, want removed", got)
+	}
+	if got := c.Get("/certs/kept.pem"); got != "kept" {
+		t.Errorf("Get() for kept entry = %v, want kept", got)
+	}
+}
+
+func TestCacheDump(t *testing.T) {
+	c, err := cache.New(t.TempDir(), 30*time.Minute, 10485760)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetWithIndex("/certs/b.pem", "b-digest", "cached")
+	c.Set("/certs/a.pem", nil)
+
+	var buf bytes.Buffer
+	if err := c.Dump(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected a header and 2 entries, got:\n%s", buf.String())
+	}
+	if fields := strings.Fields(lines[0]); fields[0] != "KEY" {
+		t.Errorf("Expected a header line, got %q", lines[0])
+	}
+
+	// Sorted by key; an entry without a value or index key shows "-"
+	if fields := strings.Fields(lines[1]); fields[0] != "/certs/a.pem" || fields[1] != "-" || fields[4] != "-" {
+		t.Errorf("Unexpected dump line for invalidated entry: %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); fields[0] != "/certs/b.pem" || fields[1] != "b-digest" || fields[4] != "string" {
+		t.Errorf("Unexpected dump line for cached entry: %q", lines[2])
+	}
+
+	// A describe func renders the values instead of their types
+	buf.Reset()
+	describe := func(entry cache.Entry) string {
+		return "value=" + entry.Value.(string)
+	}
+	if err := c.Dump(&buf, describe); err != nil {
+		t.Fatal(err)
+	}
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if fields := strings.Fields(lines[1]); fields[4] != "-" {
+		t.Errorf("Expected the invalidated entry to skip describe, got %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); fields[4] != "value=cached" {
+		t.Errorf("Expected the described value, got %q", lines[2])
+	}
+}
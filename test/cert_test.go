@@ -0,0 +1,413 @@
+// test/cert_test.go
+
+package test
+
+import (
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/cert"
+)
+
+func TestIsSelfSigned(t *testing.T) {
+	sharedName := pkix.Name{
+		CommonName:   "shared.example.com",
+		Organization: []string{"Shared Org"},
+		Country:      []string{"US"},
+	}
+
+	tests := []struct {
+		name     string
+		pem      []byte
+		expected bool
+	}{
+		{"self_signed_leaf", generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)), true},
+		{"self_signed_ca", generateSelfSignedCertificate(t, 2048, time.Now().Add(365*24*time.Hour)), true},
+		{"ca_signed_equal_names", createCASignedCertificate(t, sharedName), false},
+		{"ca_signed", createCertificateWithIssuer(t, "CN=DigiCert TLS RSA SHA256 2020 CA1,O=DigiCert Inc,C=US"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := cert.Parse(tt.pem)
+			if err != nil {
+				t.Fatal("Failed to parse certificate:", err)
+			}
+
+			if got := cert.IsSelfSigned(c); got != tt.expected {
+				t.Errorf("IsSelfSigned() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestKeyWeaknesses(t *testing.T) {
+	tests := []struct {
+		name     string
+		pem      []byte
+		expected []string
+	}{
+		{"strong", generateCertificateWithExponent(t, 2048, 65537), nil},
+		{"small_modulus", generateCertificateWithExponent(t, 1024, 65537), []string{cert.WeaknessSmallModulus}},
+		{"small_exponent", generateCertificateWithExponent(t, 2048, 3), []string{cert.WeaknessSmallExponent}},
+		{"non_standard_exponent", generateCertificateWithExponent(t, 2048, 65539), []string{cert.WeaknessNonStandardExponent}},
+		{"multiple", generateCertificateWithExponent(t, 1024, 3), []string{cert.WeaknessSmallModulus, cert.WeaknessSmallExponent}},
+		{"ecdsa_p256", generateECDSACertificate(t, elliptic.P256()), nil},
+		{"ecdsa_p224", generateECDSACertificate(t, elliptic.P224()), []string{cert.WeaknessSmallCurve}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := cert.Parse(tt.pem)
+			if err != nil {
+				t.Fatal("Failed to parse certificate:", err)
+			}
+
+			got := cert.KeyWeaknesses(c)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("KeyWeaknesses() = %v, want %v", got, tt.expected)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("KeyWeaknesses()[%d] = %s, want %s", i, got[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCurveName(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ed25519.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+	}
+	edDER, err := x509.CreateCertificate(rand.Reader, template, template, edKey.Public(), edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		pem      []byte
+		expected string
+	}{
+		{"p224", generateECDSACertificate(t, elliptic.P224()), "P-224"},
+		{"p384", generateECDSACertificate(t, elliptic.P384()), "P-384"},
+		{"ed25519", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: edDER}), "Ed25519"},
+		{"rsa", generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := cert.Parse(tt.pem)
+			if err != nil {
+				t.Fatal("Failed to parse certificate:", err)
+			}
+
+			if got := cert.CurveName(c); got != tt.expected {
+				t.Errorf("CurveName() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHasSCT(t *testing.T) {
+	tests := []struct {
+		name     string
+		pem      []byte
+		expected bool
+	}{
+		{"embedded_sct", createCertificateWithSCT(t), true},
+		{"no_sct", generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := cert.Parse(tt.pem)
+			if err != nil {
+				t.Fatal("Failed to parse certificate:", err)
+			}
+
+			if got := cert.HasSCT(c); got != tt.expected {
+				t.Errorf("HasSCT() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseCombinedKeyAndCertificate(t *testing.T) {
+	certPEM := generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour))
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	want, err := cert.Parse(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{
+		"key first":  append(append([]byte{}, keyPEM...), certPEM...),
+		"cert first": append(append([]byte{}, certPEM...), keyPEM...),
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := cert.Parse(data)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !c.Equal(want) {
+				t.Error("Parse() returned a different certificate")
+			}
+		})
+	}
+
+	// A key on its own is still not a certificate
+	if _, err := cert.Parse(keyPEM); !errors.Is(err, cert.ErrNotCertificate) {
+		t.Errorf("Parse() of a lone key error = %v, want ErrNotCertificate", err)
+	}
+}
+
+func TestChainDepth(t *testing.T) {
+	leaf := generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour))
+	intermediate := generateSelfSignedCertificate(t, 2048, time.Now().Add(365*24*time.Hour))
+	block, _ := pem.Decode(leaf)
+	keyBlock := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("not a key")})
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected int
+	}{
+		{"leaf_only", leaf, 1},
+		{"bundle", append(append([]byte{}, leaf...), intermediate...), 2},
+		{"der", block.Bytes, 1},
+		{"ignores_non_certificate_blocks", append(append([]byte{}, leaf...), keyBlock...), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cert.ChainDepth(tt.data); got != tt.expected {
+				t.Errorf("ChainDepth() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestKeyUsages(t *testing.T) {
+	c, err := cert.Parse(generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+	if err != nil {
+		t.Fatal("Failed to parse certificate:", err)
+	}
+
+	usages := cert.KeyUsages(c)
+	if len(usages) != 2 || usages[0] != "digital_signature" || usages[1] != "key_encipherment" {
+		t.Errorf("KeyUsages() = %v, want [digital_signature key_encipherment]", usages)
+	}
+
+	ekus := cert.ExtKeyUsages(c)
+	if len(ekus) != 1 || ekus[0] != "server_auth" {
+		t.Errorf("ExtKeyUsages() = %v, want [server_auth]", ekus)
+	}
+}
+
+func TestSPKISHA256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Renewals that keep the key keep the pin
+	issue := func(serial int64) *x509.Certificate {
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "pinned.example.com"},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	first, renewed := issue(1), issue(2)
+	digest := sha256.Sum256(first.RawSubjectPublicKeyInfo)
+	if got, want := cert.SPKISHA256(first), base64.StdEncoding.EncodeToString(digest[:]); got != want {
+		t.Errorf("SPKISHA256() = %q, want %q", got, want)
+	}
+	if cert.SPKISHA256(first) != cert.SPKISHA256(renewed) {
+		t.Error("Expected certificates sharing a key to share a pin")
+	}
+
+	other, err := cert.Parse(generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+	if err != nil {
+		t.Fatal("Failed to parse certificate:", err)
+	}
+	if cert.SPKISHA256(first) == cert.SPKISHA256(other) {
+		t.Error("Expected different keys to have different pins")
+	}
+}
+
+func TestBaseDomain(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"*.example.com", "example.com"},
+		{"www.example.com", "example.com"},
+		{"api.eu.example.com", "eu.example.com"},
+		{"*.eu.example.com", "eu.example.com"},
+		{"WWW.Example.COM.", "example.com"},
+		{"example.com", "example.com"},
+		{"localhost", "localhost"},
+		{"10.0.0.1", ""},
+		{"::1", ""},
+		{"Test Org", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := cert.BaseDomain(tt.name); got != tt.want {
+			t.Errorf("BaseDomain(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	certPEM := generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour))
+	block, _ := pem.Decode(certPEM)
+
+	// Certificates-only PKCS #7, as in .p7b files
+	signedData, err := asn1.Marshal(struct {
+		Version          int
+		DigestAlgorithms []asn1.RawValue `asn1:"set"`
+		ContentInfo      struct{ ContentType asn1.ObjectIdentifier }
+		Certificates     asn1.RawValue `asn1:"tag:0,optional"`
+	}{
+		Version:      1,
+		ContentInfo:  struct{ ContentType asn1.ObjectIdentifier }{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}},
+		Certificates: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: block.Bytes},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs7, err := asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"explicit,tag:0"`
+	}{
+		ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2},
+		Content:     asn1.RawValue{FullBytes: signedData},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// PKCS #12 PFX header: version 3 followed by the auth safe
+	pkcs12, err := asn1.Marshal(struct {
+		Version  int
+		AuthSafe struct{ ContentType asn1.ObjectIdentifier }
+	}{
+		Version:  3,
+		AuthSafe: struct{ ContentType asn1.ObjectIdentifier }{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"pem", certPEM, cert.FormatPEM},
+		{"der", block.Bytes, cert.FormatDER},
+		{"pkcs7 pem", pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: pkcs7}), cert.FormatPKCS7},
+		{"pkcs7 der", pkcs7, cert.FormatPKCS7},
+		{"pkcs12", pkcs12, cert.FormatPKCS12},
+		{"garbage", []byte("not a certificate"), cert.FormatDER},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cert.Format(tt.data); got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	root, intermediate, leaf := createCAChain(t)
+
+	tests := []struct {
+		name     string
+		pem      []byte
+		expected string
+	}{
+		{"root", root, cert.TypeRoot},
+		{"intermediate", intermediate, cert.TypeIntermediate},
+		{"leaf", leaf, cert.TypeLeaf},
+		{"self_signed_leaf", generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)), cert.TypeLeaf},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := cert.Parse(tt.pem)
+			if err != nil {
+				t.Fatal("Failed to parse certificate:", err)
+			}
+
+			if got := cert.Classify(c); got != tt.expected {
+				t.Errorf("Classify() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseChain(t *testing.T) {
+	root, intermediate, leaf := createCAChain(t)
+	keyBlock := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("not a key")})
+
+	bundle := append(append(append(append([]byte{}, keyBlock...), leaf...), intermediate...), root...)
+	chain, err := cert.ParseChain(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 3 || chain[0].Subject.CommonName != "chain.example.com" {
+		t.Fatalf("ParseChain() returned %d certificates, want leaf first of 3", len(chain))
+	}
+
+	if _, err := cert.ParseChain(keyBlock); !errors.Is(err, cert.ErrNotCertificate) {
+		t.Errorf("ParseChain() of a lone key error = %v, want ErrNotCertificate", err)
+	}
+}
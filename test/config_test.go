@@ -1,12 +1,19 @@
 package test
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/brandonhon/tls-cert-monitor/internal/config"
+	"github.com/brandonhon/tls-cert-monitor/internal/logger"
 	"gopkg.in/yaml.v3"
 )
 
@@ -145,6 +152,220 @@ func TestConfigValidation(t *testing.T) {
 			wantErr: true,
 			errMsg:  "invalid log level",
 		},
+		{
+			name: "invalid metrics prefix",
+			config: &config.Config{
+				Port:                   3200,
+				CertificateDirectories: []string{t.TempDir()},
+				ScanInterval:           1 * time.Minute,
+				Workers:                4,
+				LogLevel:               "info",
+				MetricsPrefix:          "corp-tls",
+			},
+			wantErr: true,
+			errMsg:  "metrics prefix must start with a letter",
+		},
+		{
+			name: "negative cache save interval",
+			config: &config.Config{
+				Port:                   3200,
+				CertificateDirectories: []string{t.TempDir()},
+				ScanInterval:           1 * time.Minute,
+				Workers:                4,
+				LogLevel:               "info",
+				CacheSaveInterval:      -1 * time.Minute,
+			},
+			wantErr: true,
+			errMsg:  "cache save interval must not be negative",
+		},
+		{
+			name: "scan jitter as long as the interval",
+			config: &config.Config{
+				Port:                   3200,
+				CertificateDirectories: []string{t.TempDir()},
+				ScanInterval:           1 * time.Minute,
+				ScanJitter:             1 * time.Minute,
+				Workers:                4,
+				LogLevel:               "info",
+			},
+			wantErr: true,
+			errMsg:  "scan jitter must be shorter than the scan interval",
+		},
+		{
+			name: "s3 endpoint not a URL",
+			config: &config.Config{
+				Port:                   3200,
+				CertificateDirectories: []string{t.TempDir()},
+				ScanInterval:           1 * time.Minute,
+				S3Bucket:               "certs",
+				S3Region:               "us-east-1",
+				S3Endpoint:             "minio.internal:9000",
+				Workers:                4,
+				LogLevel:               "info",
+			},
+			wantErr: true,
+			errMsg:  "S3 endpoint must be an http or https URL",
+		},
+		{
+			name: "s3 bucket with a folder",
+			config: &config.Config{
+				Port:                   3200,
+				CertificateDirectories: []string{t.TempDir()},
+				ScanInterval:           1 * time.Minute,
+				S3Bucket:               "certs/prod",
+				S3Region:               "us-east-1",
+				Workers:                4,
+				LogLevel:               "info",
+			},
+			wantErr: true,
+			errMsg:  "S3 bucket name must not contain a slash",
+		},
+		{
+			name: "negative max depth",
+			config: &config.Config{
+				Port:                   3200,
+				CertificateDirectories: []string{t.TempDir()},
+				ScanInterval:           1 * time.Minute,
+				MaxDepth:               -1,
+				Workers:                4,
+				LogLevel:               "info",
+			},
+			wantErr: true,
+			errMsg:  "max depth must not be negative",
+		},
+		{
+			name: "negative max concurrent dirs",
+			config: &config.Config{
+				Port:                   3200,
+				CertificateDirectories: []string{t.TempDir()},
+				ScanInterval:           1 * time.Minute,
+				MaxConcurrentDirs:      -1,
+				Workers:                4,
+				LogLevel:               "info",
+			},
+			wantErr: true,
+			errMsg:  "max concurrent dirs must not be negative",
+		},
+		{
+			name: "invalid pushgateway URL",
+			config: &config.Config{
+				Port:                   3200,
+				CertificateDirectories: []string{t.TempDir()},
+				ScanInterval:           1 * time.Minute,
+				PushgatewayURL:         "pushgateway:9091",
+				PushgatewayJob:         "tls_cert_monitor",
+				Workers:                4,
+				LogLevel:               "info",
+			},
+			wantErr: true,
+			errMsg:  "Pushgateway URL must be an http or https URL",
+		},
+		{
+			name: "endpoint without port",
+			config: &config.Config{
+				Port:                   3200,
+				CertificateDirectories: []string{t.TempDir()},
+				ScanInterval:           1 * time.Minute,
+				Endpoints:              []string{"example.com"},
+				EndpointTimeout:        5 * time.Second,
+				Workers:                4,
+				LogLevel:               "info",
+			},
+			wantErr: true,
+			errMsg:  "endpoint must be host:port",
+		},
+		{
+			name: "endpoint with empty server name",
+			config: &config.Config{
+				Port:                   3200,
+				CertificateDirectories: []string{t.TempDir()},
+				ScanInterval:           1 * time.Minute,
+				Endpoints:              []string{"10.0.0.5:443@"},
+				EndpointTimeout:        5 * time.Second,
+				Workers:                4,
+				LogLevel:               "info",
+			},
+			wantErr: true,
+			errMsg:  "optionally followed by @server_name",
+		},
+		{
+			name: "validity policy without a date",
+			config: &config.Config{
+				Port:                   3200,
+				CertificateDirectories: []string{t.TempDir()},
+				ScanInterval:           1 * time.Minute,
+				ValidityPolicies:       []config.ValidityPolicy{{Name: "cabf-2026", From: "March 2026", MaxValidityDays: 200}},
+				Workers:                4,
+				LogLevel:               "info",
+			},
+			wantErr: true,
+			errMsg:  "from must be a YYYY-MM-DD date",
+		},
+		{
+			name: "invalid primary identifier",
+			config: &config.Config{
+				Port:                   3200,
+				CertificateDirectories: []string{t.TempDir()},
+				ScanInterval:           1 * time.Minute,
+				PrimaryIdentifier:      "serial",
+				Workers:                4,
+				LogLevel:               "info",
+			},
+			wantErr: true,
+			errMsg:  "invalid primary identifier",
+		},
+		{
+			name: "invalid include CN pattern",
+			config: &config.Config{
+				Port:                   3200,
+				CertificateDirectories: []string{t.TempDir()},
+				ScanInterval:           1 * time.Minute,
+				IncludeCNPatterns:      []string{"(unclosed"},
+				Workers:                4,
+				LogLevel:               "info",
+			},
+			wantErr: true,
+			errMsg:  "invalid include CN pattern",
+		},
+		{
+			name: "unordered expiry histogram buckets",
+			config: &config.Config{
+				Port:                   3200,
+				CertificateDirectories: []string{t.TempDir()},
+				ScanInterval:           1 * time.Minute,
+				ExpiryHistogramBuckets: []float64{30, 7},
+				Workers:                4,
+				LogLevel:               "info",
+			},
+			wantErr: true,
+			errMsg:  "expiry histogram buckets must be in increasing order",
+		},
+		{
+			name: "negative max validity days",
+			config: &config.Config{
+				Port:                   3200,
+				CertificateDirectories: []string{t.TempDir()},
+				ScanInterval:           1 * time.Minute,
+				Workers:                4,
+				LogLevel:               "info",
+				MaxValidityDays:        -1,
+			},
+			wantErr: true,
+			errMsg:  "max validity days must not be negative",
+		},
+		{
+			name: "unknown deprecated curve",
+			config: &config.Config{
+				Port:                   3200,
+				CertificateDirectories: []string{t.TempDir()},
+				ScanInterval:           1 * time.Minute,
+				Workers:                4,
+				LogLevel:               "info",
+				DeprecatedCurves:       []string{"P-192"},
+			},
+			wantErr: true,
+			errMsg:  "unknown curve: P-192",
+		},
 	}
 
 	for _, tt := range tests {
@@ -227,3 +448,783 @@ func TestConfigPathTraversal(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigValidateAll(t *testing.T) {
+	cfg := &config.Config{
+		Port:                   -1,
+		CertificateDirectories: []string{t.TempDir()},
+		ScanInterval:           1 * time.Minute,
+		Workers:                0,
+		LogLevel:               "invalid",
+	}
+
+	errs := cfg.ValidateAll()
+	if len(errs) != 3 {
+		t.Fatalf("ValidateAll() returned %d errors, want 3: %v", len(errs), errs)
+	}
+
+	wantFields := []string{"port", "workers", "log_level"}
+	for i, field := range wantFields {
+		if errs[i].Field != field {
+			t.Errorf("ValidateAll()[%d].Field = %s, want %s", i, errs[i].Field, field)
+		}
+	}
+
+	// Validate keeps returning only the first problem
+	err := cfg.Validate()
+	if err == nil || !contains(err.Error(), "invalid port") {
+		t.Errorf("Validate() error = %v, want first error about port", err)
+	}
+}
+
+func TestConfigDirectoryGlobs(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"apps/a/tls", "apps/b/tls", "apps/c"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeConfig := func(t *testing.T, strict bool) string {
+		cfg := map[string]interface{}{
+			"certificate_directories": []string{
+				filepath.Join(tmpDir, "apps", "*", "tls"),
+				filepath.Join(tmpDir, "missing", "*"),
+			},
+			"strict_globs": strict,
+			"cache_dir":    filepath.Join(tmpDir, "cache"),
+		}
+		data, err := yaml.Marshal(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		configFile := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(configFile, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return configFile
+	}
+
+	t.Run("lenient", func(t *testing.T) {
+		loaded, err := config.Load(writeConfig(t, false))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []string{
+			filepath.Join(tmpDir, "apps", "a", "tls"),
+			filepath.Join(tmpDir, "apps", "b", "tls"),
+		}
+		if len(loaded.CertificateDirectories) != len(expected) {
+			t.Fatalf("Expected directories %v, got %v", expected, loaded.CertificateDirectories)
+		}
+		for i, dir := range expected {
+			if loaded.CertificateDirectories[i] != dir {
+				t.Errorf("Directory %d = %s, want %s", i, loaded.CertificateDirectories[i], dir)
+			}
+		}
+
+		if warnings := loaded.Warnings(); len(warnings) != 1 {
+			t.Errorf("Expected 1 warning for the unmatched pattern, got %v", warnings)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		_, err := config.Load(writeConfig(t, true))
+		if err == nil {
+			t.Fatal("Expected error for unmatched pattern in strict mode")
+		}
+		if !contains(err.Error(), "matches no directories") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestConfigAllowedSigAlgs(t *testing.T) {
+	cfg := &config.Config{
+		Port:                   3200,
+		CertificateDirectories: []string{t.TempDir()},
+		ScanInterval:           1 * time.Minute,
+		Workers:                1,
+		LogLevel:               "info",
+		AllowedSigAlgs:         []string{"SHA256-RSA", "ecdsa-sha384", "SHA256-BOGUS"},
+	}
+
+	errs := cfg.ValidateAll()
+	if len(errs) != 1 || errs[0].Field != "allowed_sig_algs[2]" {
+		t.Fatalf("ValidateAll() = %v, want a single error for allowed_sig_algs[2]", errs)
+	}
+
+	if !cfg.IsSignatureAlgorithmAllowed("ECDSA-SHA384") {
+		t.Error("Expected ECDSA-SHA384 to be allowed (case-insensitive)")
+	}
+	if cfg.IsSignatureAlgorithmAllowed("SHA1-RSA") {
+		t.Error("Expected SHA1-RSA to be disallowed")
+	}
+
+	// An empty list allows everything
+	cfg.AllowedSigAlgs = nil
+	if !cfg.IsSignatureAlgorithmAllowed("SHA1-RSA") {
+		t.Error("Expected every algorithm to be allowed with an empty list")
+	}
+}
+
+func TestConfigRenewalThresholds(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	content := fmt.Sprintf(`certificate_directories:
+  - %q
+cache_dir: %q
+renewal_thresholds:
+  30: "1440h"
+  32: "720h"
+`, tmpDir, filepath.Join(tmpDir, "cache"))
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]time.Duration{30: 60 * 24 * time.Hour, 32: 30 * 24 * time.Hour}
+	if len(cfg.RenewalThresholds) != len(want) {
+		t.Fatalf("RenewalThresholds = %v, want %v", cfg.RenewalThresholds, want)
+	}
+	for code, threshold := range want {
+		if cfg.RenewalThresholds[code] != threshold {
+			t.Errorf("RenewalThresholds[%d] = %v, want %v", code, cfg.RenewalThresholds[code], threshold)
+		}
+	}
+
+	cfg.RenewalThresholds = map[int]time.Duration{0: time.Hour, 31: -time.Hour}
+	errs := cfg.ValidateAll()
+	if len(errs) != 2 || errs[0].Field != "renewal_thresholds[0]" || errs[1].Field != "renewal_thresholds[31]" {
+		t.Fatalf("ValidateAll() = %v, want errors for renewal_thresholds[0] and renewal_thresholds[31]", errs)
+	}
+}
+
+func TestConfigRequireSecureDirs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not checked on Windows")
+	}
+
+	secureDir := t.TempDir()
+	os.Chmod(secureDir, 0755)
+	insecureDir := t.TempDir()
+	os.Chmod(insecureDir, 0777)
+
+	cfg := &config.Config{
+		Port:                   3200,
+		CertificateDirectories: []string{secureDir, insecureDir},
+		ScanInterval:           1 * time.Minute,
+		Workers:                1,
+		LogLevel:               "info",
+	}
+
+	// Off by default
+	if errs := cfg.ValidateAll(); len(errs) != 0 {
+		t.Fatalf("ValidateAll() = %v, want no errors without require_secure_dirs", errs)
+	}
+
+	cfg.RequireSecureDirs = true
+	errs := cfg.ValidateAll()
+	if len(errs) != 1 || errs[0].Field != "certificate_directories[1]" {
+		t.Fatalf("ValidateAll() = %v, want a single error for certificate_directories[1]", errs)
+	}
+	if !contains(errs[0].Error(), "group or world writable") {
+		t.Errorf("Expected a writable directory error, got %v", errs[0])
+	}
+}
+
+func TestConfigSources(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	content := "port: 3300\nauth_token: \"s3cret\"\nscan_interval: 2m\ncertificate_directories:\n  - " + tmpDir + "\n"
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TLS_MONITOR_WORKERS", "3")
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.ConfigFile() != configFile {
+		t.Errorf("ConfigFile() = %q, want %q", cfg.ConfigFile(), configFile)
+	}
+
+	sources := cfg.Sources()
+	expected := map[string]string{
+		"port":      config.SourceFile,
+		"workers":   config.SourceEnv,
+		"log_level": config.SourceDefault,
+	}
+	for key, want := range expected {
+		if sources[key] != want {
+			t.Errorf("Sources()[%q] = %q, want %q", key, sources[key], want)
+		}
+	}
+
+	effective := cfg.Effective()
+	if effective["auth_token"] != "REDACTED" {
+		t.Errorf("auth_token = %v, want REDACTED", effective["auth_token"])
+	}
+	if effective["scan_interval"] != "2m0s" {
+		t.Errorf("scan_interval = %v, want 2m0s", effective["scan_interval"])
+	}
+	if effective["workers"] != 3 {
+		t.Errorf("workers = %v, want 3", effective["workers"])
+	}
+}
+
+func TestLoadValidityPolicies(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	content := "certificate_directories:\n  - " + tmpDir + "\n" +
+		"cache_dir: \"" + filepath.Join(tmpDir, "cache") + "\"\n" +
+		"validity_policies:\n" +
+		"  - name: cabf-2026\n    from: \"2026-03-15\"\n    max_validity_days: 200\n" +
+		"  - name: cabf-2027\n    from: \"2027-03-15\"\n    max_validity_days: 100\n"
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []config.ValidityPolicy{
+		{Name: "cabf-2026", From: "2026-03-15", MaxValidityDays: 200},
+		{Name: "cabf-2027", From: "2027-03-15", MaxValidityDays: 100},
+	}
+	if len(cfg.ValidityPolicies) != len(want) {
+		t.Fatalf("ValidityPolicies = %v, want %v", cfg.ValidityPolicies, want)
+	}
+	for i, policy := range want {
+		if cfg.ValidityPolicies[i] != policy {
+			t.Errorf("ValidityPolicies[%d] = %v, want %v", i, cfg.ValidityPolicies[i], policy)
+		}
+	}
+
+	// Policies apply from their date on
+	tests := []struct {
+		at   string
+		want string
+	}{
+		{"2026-03-14", ""},
+		{"2026-03-15", "cabf-2026"},
+		{"2027-06-01", "cabf-2027"},
+	}
+	for _, tt := range tests {
+		at, _ := time.Parse("2006-01-02", tt.at)
+		policy, _ := cfg.ValidityPolicyAt(at)
+		if policy.Name != tt.want {
+			t.Errorf("ValidityPolicyAt(%s) = %q, want %q", tt.at, policy.Name, tt.want)
+		}
+	}
+}
+
+func TestConfigEnvInterpolation(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	t.Setenv("CERT_MONITOR_TEST_ROOT", tmpDir)
+	t.Setenv("CERT_MONITOR_TEST_WORKERS", "3")
+
+	content := "certificate_directories:\n  - ${CERT_MONITOR_TEST_ROOT}\n" +
+		"workers: ${CERT_MONITOR_TEST_WORKERS}\n" +
+		"cache_dir: \"${CERT_MONITOR_TEST_ROOT}/cache\"\n" +
+		"auth_token: \"pa$$word\"\n" +
+		"include_cn_patterns:\n  - '\\.example\\.com$'\n"
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.CertificateDirectories) != 1 || cfg.CertificateDirectories[0] != tmpDir {
+		t.Errorf("CertificateDirectories = %v, want [%s]", cfg.CertificateDirectories, tmpDir)
+	}
+	if cfg.Workers != 3 {
+		t.Errorf("Workers = %d, want 3", cfg.Workers)
+	}
+	if cfg.CacheDir != filepath.Join(tmpDir, "cache") {
+		t.Errorf("CacheDir = %q, want %q", cfg.CacheDir, filepath.Join(tmpDir, "cache"))
+	}
+	if cfg.AuthToken != "pa$word" {
+		t.Errorf("AuthToken = %q, want $$ to escape a literal $", cfg.AuthToken)
+	}
+	if len(cfg.IncludeCNPatterns) != 1 || cfg.IncludeCNPatterns[0] != `\.example\.com$` {
+		t.Errorf("IncludeCNPatterns = %v, want the trailing $ kept", cfg.IncludeCNPatterns)
+	}
+
+	// Unset variables are reported by name instead of expanding to nothing
+	content = "certificate_directories:\n  - ${CERT_MONITOR_TEST_UNSET_A}\nlog_file: $CERT_MONITOR_TEST_UNSET_B\n"
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = config.Load(configFile)
+	if err == nil {
+		t.Fatal("Expected an error for unset environment variables")
+	}
+	if !strings.Contains(err.Error(), "CERT_MONITOR_TEST_UNSET_A, CERT_MONITOR_TEST_UNSET_B") {
+		t.Errorf("Expected the unset variables in the error, got: %v", err)
+	}
+}
+
+func TestConfigWatcherDebounce(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	if err := os.MkdirAll(certDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	writeConfig := func(workers int) {
+		data, err := yaml.Marshal(map[string]interface{}{
+			"certificate_directories": []string{certDir},
+			"cache_dir":               filepath.Join(tmpDir, "cache"),
+			"workers":                 workers,
+			"config_debounce":         "300ms",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(configFile, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(1)
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ConfigDebounce != 300*time.Millisecond {
+		t.Fatalf("ConfigDebounce = %v, want 300ms", cfg.ConfigDebounce)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloads := make(chan *config.Config, 10)
+	watcher := config.NewWatcher(cfg, configFile, logger.NewNop())
+	go watcher.Watch(ctx, func(newCfg *config.Config) error {
+		reloads <- newCfg
+		return nil
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	// A burst of writes reloads once, after the quiet period
+	started := time.Now()
+	for workers := 2; workers <= 4; workers++ {
+		writeConfig(workers)
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	select {
+	case newCfg := <-reloads:
+		if elapsed := time.Since(started); elapsed < 300*time.Millisecond {
+			t.Errorf("Reloaded after %v, before the debounce", elapsed)
+		}
+		if newCfg.Workers != 4 {
+			t.Errorf("Reloaded workers = %d, want 4", newCfg.Workers)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the configuration to be reloaded")
+	}
+
+	select {
+	case <-reloads:
+		t.Error("Expected a single reload for the burst of writes")
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestConfigWatcherRejectsInvalidReload(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	if err := os.MkdirAll(certDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	writeConfig := func(workers int) {
+		data, err := yaml.Marshal(map[string]interface{}{
+			"certificate_directories": []string{certDir},
+			"cache_dir":               filepath.Join(tmpDir, "cache"),
+			"workers":                 workers,
+			"config_debounce":         "50ms",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(configFile, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(2)
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloads := make(chan *config.Config, 10)
+	reloadErrors := make(chan error, 10)
+	watcher := config.NewWatcher(cfg, configFile, logger.NewNop())
+	watcher.SetReloadErrorHandler(func(err error) {
+		reloadErrors <- err
+	})
+	go watcher.Watch(ctx, func(newCfg *config.Config) error {
+		reloads <- newCfg
+		return nil
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	writeConfig(0)
+
+	select {
+	case err := <-reloadErrors:
+		if !contains(err.Error(), "workers") {
+			t.Errorf("Expected a workers validation error, got %v", err)
+		}
+	case <-reloads:
+		t.Fatal("Expected the invalid configuration to be rejected")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the reload error handler to be called")
+	}
+
+	if got := watcher.GetConfig().Workers; got != 2 {
+		t.Errorf("Workers after a rejected reload = %d, want 2", got)
+	}
+}
+
+func TestConfigWatcherCallbackRejectsReload(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	if err := os.MkdirAll(certDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	writeConfig := func(workers int) {
+		data, err := yaml.Marshal(map[string]interface{}{
+			"certificate_directories": []string{certDir},
+			"cache_dir":               filepath.Join(tmpDir, "cache"),
+			"workers":                 workers,
+			"config_debounce":         "50ms",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(configFile, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(2)
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var rejectNext atomic.Bool
+	rejectNext.Store(true)
+	reloads := make(chan *config.Config, 10)
+	results := make(chan string, 10)
+	reloadErrors := make(chan error, 10)
+	watcher := config.NewWatcher(cfg, configFile, logger.NewNop())
+	watcher.SetReloadErrorHandler(func(err error) {
+		reloadErrors <- err
+	})
+	watcher.SetReloadResultHandler(func(result string) {
+		results <- result
+	})
+	go watcher.Watch(ctx, func(newCfg *config.Config) error {
+		if rejectNext.Swap(false) {
+			return errors.New("ca bundle unreadable")
+		}
+		reloads <- newCfg
+		return nil
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	expectResult := func(want string) {
+		t.Helper()
+		select {
+		case got := <-results:
+			if got != want {
+				t.Errorf("Reload result = %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected a %q reload result", want)
+		}
+	}
+
+	// A configuration the callback rejects is reported as an error and
+	// doesn't replace the current one
+	writeConfig(3)
+	expectResult(config.ReloadError)
+	select {
+	case err := <-reloadErrors:
+		if !contains(err.Error(), "ca bundle unreadable") {
+			t.Errorf("Expected the callback error, got %v", err)
+		}
+	default:
+		t.Error("Expected the reload error handler to be called")
+	}
+	if got := watcher.GetConfig().Workers; got != 2 {
+		t.Errorf("Workers after a rejected reload = %d, want 2", got)
+	}
+
+	// Saving the same contents again retries the reload
+	writeConfig(3)
+	expectResult(config.ReloadSuccess)
+	select {
+	case newCfg := <-reloads:
+		if newCfg.Workers != 3 {
+			t.Errorf("Reloaded workers = %d, want 3", newCfg.Workers)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the retried configuration to be reloaded")
+	}
+	if got := watcher.GetConfig().Workers; got != 3 {
+		t.Errorf("Workers after the retried reload = %d, want 3", got)
+	}
+}
+
+func TestConfigWatcherReloadResults(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	if err := os.MkdirAll(certDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	writeConfig := func(workers int) {
+		data, err := yaml.Marshal(map[string]interface{}{
+			"certificate_directories": []string{certDir},
+			"cache_dir":               filepath.Join(tmpDir, "cache"),
+			"workers":                 workers,
+			"config_debounce":         "50ms",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(configFile, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(2)
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloads := make(chan *config.Config, 10)
+	results := make(chan string, 10)
+	watcher := config.NewWatcher(cfg, configFile, logger.NewNop())
+	watcher.SetReloadResultHandler(func(result string) {
+		results <- result
+	})
+	go watcher.Watch(ctx, func(newCfg *config.Config) error {
+		reloads <- newCfg
+		return nil
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	expectResult := func(want string) {
+		t.Helper()
+		select {
+		case got := <-results:
+			if got != want {
+				t.Errorf("Reload result = %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected a %q reload result", want)
+		}
+	}
+
+	// Rewriting identical contents, deleting or truncating the file keeps
+	// the current configuration without calling back for a rescan
+	writeConfig(2)
+	expectResult(config.ReloadUnchanged)
+
+	if err := os.Remove(configFile); err != nil {
+		t.Fatal(err)
+	}
+	expectResult(config.ReloadMissing)
+
+	if err := os.WriteFile(configFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectResult(config.ReloadEmpty)
+
+	select {
+	case <-reloads:
+		t.Fatal("Expected no reload callback for a skipped reload")
+	default:
+	}
+	if got := watcher.GetConfig().Workers; got != 2 {
+		t.Errorf("Workers after skipped reloads = %d, want 2", got)
+	}
+
+	// The recreated file is reloaded
+	writeConfig(3)
+	expectResult(config.ReloadSuccess)
+
+	select {
+	case newCfg := <-reloads:
+		if newCfg.Workers != 3 {
+			t.Errorf("Reloaded workers = %d, want 3", newCfg.Workers)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the recreated configuration to be reloaded")
+	}
+}
+
+func TestConfigWatcherFollowsReplacedFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	if err := os.MkdirAll(certDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Lay the config out like a Kubernetes ConfigMap volume: config.yaml
+	// links through ..data, which is swapped atomically to a new directory
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	publish := func(version, workers int) {
+		dataDir := filepath.Join(tmpDir, fmt.Sprintf("data%d", version))
+		if err := os.MkdirAll(dataDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		data, err := yaml.Marshal(map[string]interface{}{
+			"certificate_directories": []string{certDir},
+			"cache_dir":               filepath.Join(tmpDir, "cache"),
+			"workers":                 workers,
+			"config_debounce":         "50ms",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dataDir, "config.yaml"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		link := filepath.Join(tmpDir, "..data_tmp")
+		if err := os.Symlink(filepath.Base(dataDir), link); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(link, filepath.Join(tmpDir, "..data")); err != nil {
+			t.Fatal(err)
+		}
+		if version > 1 {
+			if err := os.RemoveAll(filepath.Join(tmpDir, fmt.Sprintf("data%d", version-1))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	publish(1, 1)
+	if err := os.Symlink(filepath.Join("..data", "config.yaml"), configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloads := make(chan *config.Config, 10)
+	watcher := config.NewWatcher(cfg, configFile, logger.NewNop())
+	go watcher.Watch(ctx, func(newCfg *config.Config) error {
+		reloads <- newCfg
+		return nil
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	// Every replace is picked up, not just the first
+	for version, workers := 2, 2; version <= 3; version, workers = version+1, workers+1 {
+		publish(version, workers)
+
+		select {
+		case newCfg := <-reloads:
+			if newCfg.Workers != workers {
+				t.Errorf("Reloaded workers = %d, want %d", newCfg.Workers, workers)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected replace %d to be reloaded", version)
+		}
+	}
+}
+
+func TestConfigSchema(t *testing.T) {
+	schema := config.Schema()
+
+	properties, ok := schema["properties"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Schema properties = %T, want a map", schema["properties"])
+	}
+
+	// Every setting is described, and nothing else
+	settings := config.Defaults().Effective()
+	if len(properties) != len(settings) {
+		t.Errorf("Schema has %d properties, want %d", len(properties), len(settings))
+	}
+	for key := range settings {
+		property, ok := properties[key].(map[string]interface{})
+		if !ok {
+			t.Errorf("Schema is missing setting %s", key)
+			continue
+		}
+		if property["type"] == nil {
+			t.Errorf("Schema property %s has no type", key)
+		}
+	}
+
+	port := properties["port"].(map[string]interface{})
+	if port["type"] != "integer" || port["minimum"] != 1 || port["maximum"] != 65535 || port["default"] != 3200 {
+		t.Errorf("Unexpected port schema: %v", port)
+	}
+	if workers := properties["workers"].(map[string]interface{}); workers["minimum"] != 1 {
+		t.Errorf("Unexpected workers schema: %v", workers)
+	}
+	if interval := properties["scan_interval"].(map[string]interface{}); interval["type"] != "string" || interval["default"] != config.Defaults().ScanInterval.String() {
+		t.Errorf("Unexpected scan_interval schema: %v", interval)
+	}
+
+	// The example configuration only uses known settings
+	data, err := os.ReadFile(filepath.Join("..", "example.config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var example map[string]interface{}
+	if err := yaml.Unmarshal(data, &example); err != nil {
+		t.Fatal(err)
+	}
+	for key := range example {
+		if _, ok := properties[key]; !ok {
+			t.Errorf("example.config.yaml uses %s, which the schema doesn't know", key)
+		}
+	}
+}
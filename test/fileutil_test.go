@@ -0,0 +1,149 @@
+// test/fileutil_test.go
+
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/fileutil"
+)
+
+func TestWriteAtomic(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "inventory.csv")
+
+	if err := fileutil.WriteAtomic(path, []byte("first"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileutil.WriteAtomic(path, []byte("second"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "second" {
+		t.Errorf("Contents = %q, want %q", data, "second")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("Permissions = %o, want %o", info.Mode().Perm(), 0644)
+	}
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the target file, got %d entries", len(entries))
+	}
+}
+
+func TestWriteAtomicPermissionsIgnoreUmask(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.csv")
+
+	if err := fileutil.WriteAtomic(path, []byte("data"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0666 {
+		t.Errorf("Permissions = %o, want %o", info.Mode().Perm(), 0666)
+	}
+}
+
+func TestWriteAtomicFailureKeepsOriginal(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "target")
+
+	if err := fileutil.WriteAtomic(path, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory in the way makes the final rename fail
+	blocked := filepath.Join(tmpDir, "blocked")
+	if err := os.MkdirAll(filepath.Join(blocked, "child"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileutil.WriteAtomic(blocked, []byte("new"), 0644); err == nil {
+		t.Error("Expected an error replacing a non-empty directory")
+	}
+
+	if err := fileutil.WriteAtomic(filepath.Join(tmpDir, "missing", "target"), []byte("new"), 0644); err == nil {
+		t.Error("Expected an error writing into a missing directory")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "original" {
+		t.Errorf("Contents = %q, want %q", data, "original")
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected temporary files to be cleaned up, got %d entries", len(entries))
+	}
+}
+
+func TestWriteAtomicReadersNeverSeePartialFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "large")
+	small := bytes.Repeat([]byte("a"), 1024)
+	large := bytes.Repeat([]byte("b"), 1<<20)
+
+	if err := fileutil.WriteAtomic(path, small, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Errorf("Read failed: %v", err)
+				return
+			}
+			if !bytes.Equal(data, small) && !bytes.Equal(data, large) {
+				t.Errorf("Read a partial file of %d bytes", len(data))
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		data := small
+		if i%2 == 0 {
+			data = large
+		}
+		if err := fileutil.WriteAtomic(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
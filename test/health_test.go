@@ -0,0 +1,68 @@
+// test/health_test.go
+
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/config"
+	"github.com/brandonhon/tls-cert-monitor/internal/health"
+	"github.com/brandonhon/tls-cert-monitor/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestDiskSpaceThreshold(t *testing.T) {
+	certDir := t.TempDir()
+
+	// No filesystem has this much space available
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		ScanInterval:           1 * time.Minute,
+		Workers:                1,
+		MinDiskSpaceBytes:      1 << 62,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	checker := health.New(cfg, metricsCollector)
+
+	response := checker.Check()
+	if response.Status != health.StatusUnhealthy {
+		t.Errorf("Overall status = %s, want %s", response.Status, health.StatusUnhealthy)
+	}
+
+	found := false
+	for _, check := range response.Checks {
+		if contains(check.Name, "disk_space_") {
+			found = true
+			if check.Status != health.StatusUnhealthy {
+				t.Errorf("Disk check status = %s, want %s", check.Status, health.StatusUnhealthy)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("Expected a disk space check")
+	}
+
+	// Available bytes are exposed per directory
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	found = false
+	for _, family := range families {
+		if family.GetName() != "ssl_cert_monitor_disk_available_bytes" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if metric.GetLabel()[0].GetValue() == certDir && metric.GetGauge().GetValue() > 0 {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected ssl_cert_monitor_disk_available_bytes for the certificate directory")
+	}
+}
@@ -0,0 +1,90 @@
+// test/httpclient_test.go
+
+package test
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brandonhon/tls-cert-monitor/internal/httpclient"
+)
+
+func TestHTTPClientTransport(t *testing.T) {
+	userAgents := make(chan string, 1)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents <- r.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	serverPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, serverPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(opts httpclient.Options, userAgent string) (string, error) {
+		transport, err := httpclient.NewTransport(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if userAgent != "" {
+			req.Header.Set("User-Agent", userAgent)
+		}
+
+		resp, err := (&http.Client{Transport: transport}).Do(req)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+		return <-userAgents, nil
+	}
+
+	// The test server's CA is only trusted through the bundle
+	if _, err := get(httpclient.Options{}, ""); err == nil {
+		t.Error("Expected a request to an untrusted server to fail")
+	}
+
+	httpclient.SetVersion("1.2.3")
+	defer httpclient.SetVersion("dev")
+
+	tests := []struct {
+		name      string
+		opts      httpclient.Options
+		userAgent string
+		want      string
+	}{
+		{"default user agent", httpclient.Options{CABundleFile: bundle}, "", "tls-cert-monitor/1.2.3"},
+		{"configured user agent", httpclient.Options{CABundleFile: bundle, UserAgent: "cert-monitor-prod"}, "", "cert-monitor-prod"},
+		{"request user agent", httpclient.Options{CABundleFile: bundle}, "s3-signer/1", "s3-signer/1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := get(tt.opts, tt.userAgent)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("User-Agent = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// A bundle without certificates is an error
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, []byte("no certificates here"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := httpclient.NewTransport(httpclient.Options{CABundleFile: empty}); err == nil {
+		t.Error("Expected an error for a CA bundle without certificates")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -554,3 +555,171 @@ func TestMetricsWithInvalidCertificatesFixed(t *testing.T) {
 		t.Errorf("Server shutdown error: %v", err)
 	}
 }
+
+func TestMetricsPrefix(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+	writeCertToFile(t, filepath.Join(certDir, "cert.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithOptions(registry, metrics.Options{Prefix: "corp_tls"})
+	metricsCollector.SetDiskSpaceSource(func() map[string]uint64 {
+		return map[string]uint64{certDir: 1024}
+	})
+
+	s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	names := make(map[string]bool)
+	for _, family := range families {
+		if !strings.HasPrefix(family.GetName(), "corp_tls_") {
+			t.Errorf("Metric %s does not use the configured prefix", family.GetName())
+		}
+		names[family.GetName()] = true
+	}
+
+	for _, name := range []string{
+		"corp_tls_cert_expiration_timestamp",
+		"corp_tls_certs_parsed_total",
+		"corp_tls_cert_monitor_uptime_seconds",
+		"corp_tls_cert_monitor_disk_available_bytes",
+	} {
+		if !names[name] {
+			t.Errorf("Expected metric %s", name)
+		}
+	}
+}
+
+func TestMetricsDirLabel(t *testing.T) {
+	tmpDir := t.TempDir()
+	webDir := filepath.Join(tmpDir, "web")
+	apiDir := filepath.Join(tmpDir, "api")
+	os.MkdirAll(webDir, 0755)
+	os.MkdirAll(apiDir, 0755)
+	writeCertToFile(t, filepath.Join(webDir, "web.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+	writeCertToFile(t, filepath.Join(apiDir, "api.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	for _, collectorMode := range []bool{false, true} {
+		t.Run(fmt.Sprintf("collector_mode=%v", collectorMode), func(t *testing.T) {
+			cfg := &config.Config{
+				CertificateDirectories: []string{webDir, apiDir},
+				CollectorMode:          collectorMode,
+				Workers:                1,
+				CacheDir:               filepath.Join(t.TempDir(), "cache"),
+				CacheTTL:               30 * time.Minute,
+				CacheMaxSize:           10485760,
+				ScanInterval:           1 * time.Minute,
+			}
+
+			registry := prometheus.NewRegistry()
+			metricsCollector := metrics.NewCollectorWithOptions(registry, metrics.Options{
+				Prefix:   metrics.DefaultPrefix,
+				DirLabel: true,
+			})
+
+			s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			if err := s.Scan(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			families, err := registry.Gather()
+			if err != nil {
+				t.Fatal("Failed to gather metrics:", err)
+			}
+
+			for _, family := range families {
+				switch family.GetName() {
+				case "ssl_cert_expiration_timestamp", "ssl_cert_info", "ssl_cert_expiring_soon",
+					"ssl_cert_issuer_code", "ssl_cert_san_count", "ssl_cert_chain_depth":
+				default:
+					continue
+				}
+
+				if len(family.GetMetric()) != 2 {
+					t.Errorf("%s has %d series, want 2", family.GetName(), len(family.GetMetric()))
+				}
+				for _, metric := range family.GetMetric() {
+					labels := make(map[string]string)
+					for _, label := range metric.GetLabel() {
+						labels[label.GetName()] = label.GetValue()
+					}
+
+					dir := labels["dir"]
+					if dir != webDir && dir != apiDir {
+						t.Errorf("%s has dir label %q, want a configured directory", family.GetName(), dir)
+					}
+					if path, ok := labels["path"]; ok && filepath.Dir(path) != dir {
+						t.Errorf("%s for %s has dir label %q", family.GetName(), path, dir)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPushMetrics(t *testing.T) {
+	var (
+		method, path string
+		body         []byte
+	)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gateway.Close()
+
+	registry := prometheus.NewRegistry()
+	mc := metrics.NewCollectorWithRegistry(registry)
+	mc.SetCertsParsedTotal(3)
+
+	err := metrics.Push(context.Background(), registry, gateway.URL, "tls_cert_monitor", "host1", http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if method != http.MethodPut {
+		t.Errorf("Expected PUT replacing the previous push, got %s", method)
+	}
+	if path != "/metrics/job/tls_cert_monitor/instance/host1" {
+		t.Errorf("Unexpected push path %s", path)
+	}
+	if len(body) == 0 {
+		t.Error("Expected pushed metrics in the request body")
+	}
+
+	// An unreachable gateway fails once the context ends instead of hanging
+	gateway.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := metrics.Push(ctx, registry, gateway.URL, "tls_cert_monitor", "", http.DefaultClient); err == nil {
+		t.Error("Expected an error pushing to a closed gateway")
+	}
+}
@@ -3,17 +3,42 @@
 package test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/elliptic"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/brandonhon/tls-cert-monitor/internal/cert"
 	"github.com/brandonhon/tls-cert-monitor/internal/config"
 	"github.com/brandonhon/tls-cert-monitor/internal/logger"
 	"github.com/brandonhon/tls-cert-monitor/internal/metrics"
 	"github.com/brandonhon/tls-cert-monitor/internal/scanner"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestCertificateScanning(t *testing.T) {
@@ -418,3 +443,3818 @@ func TestIssuerClassification(t *testing.T) {
 		})
 	}
 }
+
+func TestKubernetesSecretParsing(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	cert := generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour))
+	secret := fmt.Sprintf(`apiVersion: v1
+kind: Secret
+type: kubernetes.io/tls
+metadata:
+  name: example-tls
+data:
+  tls.crt: %s
+  tls.key: ZHVtbXk=
+`, base64.StdEncoding.EncodeToString(cert))
+
+	writeCertToFile(t, filepath.Join(certDir, "example-tls.yaml"), []byte(secret))
+	writeCertToFile(t, filepath.Join(certDir, "configmap.yml"), []byte("apiVersion: v1\nkind: ConfigMap\ndata:\n  key: value\n"))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		ParseK8sSecrets:        true,
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	log := logger.NewNop()
+
+	s, err := scanner.New(cfg, metricsCollector, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := metricsCollector.GetMetrics()
+
+	// Both manifests are considered, but only the secret holds a certificate
+	if metrics["cert_files_total"] != 2 {
+		t.Errorf("Expected 2 manifest files, got %v", metrics["cert_files_total"])
+	}
+
+	if metrics["certs_parsed_total"] != 1 {
+		t.Errorf("Expected 1 parsed certificate, got %v", metrics["certs_parsed_total"])
+	}
+
+	// A manifest without tls.crt is skipped, not an error
+	if metrics["cert_parse_errors_total"] != 0 {
+		t.Errorf("Expected 0 parse errors, got %v", metrics["cert_parse_errors_total"])
+	}
+}
+
+func TestExpiringSoonGracePeriod(t *testing.T) {
+	tests := []struct {
+		name            string
+		ignoreNewerThan time.Duration
+		expected        float64
+	}{
+		{"no_grace_period", 0, 1},
+		{"within_grace_period", 48 * time.Hour, 0},
+		{"past_grace_period", 12 * time.Hour, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			certDir := filepath.Join(tmpDir, "certs")
+			os.MkdirAll(certDir, 0755)
+
+			// Issued a day ago, expires in five days
+			cert := generateTestCertificate(t, 2048, time.Now().Add(5*24*time.Hour))
+			writeCertToFile(t, filepath.Join(certDir, "short-lived.pem"), cert)
+
+			cfg := &config.Config{
+				CertificateDirectories: []string{certDir},
+				Workers:                1,
+				CacheDir:               filepath.Join(tmpDir, "cache"),
+				CacheTTL:               30 * time.Minute,
+				CacheMaxSize:           10485760,
+				ScanInterval:           1 * time.Minute,
+				ExpiryThreshold:        30 * 24 * time.Hour,
+				IgnoreNewerThan:        tt.ignoreNewerThan,
+			}
+
+			registry := prometheus.NewRegistry()
+			metricsCollector := metrics.NewCollectorWithRegistry(registry)
+			log := logger.NewNop()
+
+			s, err := scanner.New(cfg, metricsCollector, log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			if err := s.Scan(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			families, err := registry.Gather()
+			if err != nil {
+				t.Fatal("Failed to gather metrics:", err)
+			}
+
+			found := false
+			for _, family := range families {
+				if family.GetName() == "ssl_cert_expiring_soon" {
+					for _, metric := range family.GetMetric() {
+						found = true
+						if value := metric.GetGauge().GetValue(); value != tt.expected {
+							t.Errorf("Expected ssl_cert_expiring_soon %v, got %v", tt.expected, value)
+						}
+					}
+				}
+			}
+
+			if !found {
+				t.Error("Expected ssl_cert_expiring_soon metric to be present")
+			}
+		})
+	}
+}
+
+func TestCollectorMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	certPath := filepath.Join(certDir, "test.pem")
+	writeCertToFile(t, certPath, generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+		CollectorMode:          true,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	log := logger.NewNop()
+
+	s, err := scanner.New(cfg, metricsCollector, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	countExpirationSeries := func() int {
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal("Failed to gather metrics:", err)
+		}
+		for _, family := range families {
+			if family.GetName() == "ssl_cert_expiration_timestamp" {
+				return len(family.GetMetric())
+			}
+		}
+		return 0
+	}
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := countExpirationSeries(); got != 1 {
+		t.Fatalf("Expected 1 expiration series after scan, got %d", got)
+	}
+
+	// Resetting pushed metrics must not affect scrape-time collection
+	metricsCollector.ResetCertificateMetrics()
+	if got := countExpirationSeries(); got != 1 {
+		t.Errorf("Expected 1 expiration series after reset, got %d", got)
+	}
+
+	// Removed certificates disappear with the next scan
+	os.Remove(certPath)
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := countExpirationSeries(); got != 0 {
+		t.Errorf("Expected 0 expiration series after removal, got %d", got)
+	}
+}
+
+func TestInventoryCSVExport(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	certPath := filepath.Join(certDir, "test.pem")
+	writeCertToFile(t, certPath, generateTestCertificate(t, 2048, time.Now().Add(90*24*time.Hour)))
+
+	inventoryPath := filepath.Join(tmpDir, "inventory.csv")
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+		InventoryCSVPath:       inventoryPath,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	log := logger.NewNop()
+
+	s, err := scanner.New(cfg, metricsCollector, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(inventoryPath)
+	if err != nil {
+		t.Fatal("Failed to open inventory:", err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal("Failed to parse inventory:", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected header and 1 row, got %d records", len(records))
+	}
+
+	header := records[0]
+	if header[0] != "cn" || header[len(header)-1] != "filepath" {
+		t.Errorf("Unexpected header: %v", header)
+	}
+
+	row := records[1]
+	if row[4] != "89" {
+		t.Errorf("Expected 89 days remaining, got %s", row[4])
+	}
+	if row[5] != "test.example.com;*.example.com" {
+		t.Errorf("Unexpected SANs: %s", row[5])
+	}
+	if row[7] != certPath {
+		t.Errorf("Expected filepath %s, got %s", certPath, row[7])
+	}
+
+	// No temporary files are left next to the inventory
+	entries, _ := os.ReadDir(tmpDir)
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("Unexpected temporary file left behind: %s", entry.Name())
+		}
+	}
+}
+
+func TestSelfSignedIssuerCode(t *testing.T) {
+	digicertName := pkix.Name{
+		CommonName:   "DigiCert TLS RSA SHA256 2020 CA1",
+		Organization: []string{"DigiCert Inc"},
+		Country:      []string{"US"},
+	}
+
+	tests := []struct {
+		name         string
+		pem          []byte
+		expectedCode int
+	}{
+		// Signed by its own key, with an issuer name that would classify as Other
+		{"self_signed", generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)), 33},
+		// Subject equals issuer, but signed by a separate CA key
+		{"ca_signed_equal_names", createCASignedCertificate(t, digicertName), 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			certDir := filepath.Join(tmpDir, "certs")
+			os.MkdirAll(certDir, 0755)
+			writeCertToFile(t, filepath.Join(certDir, "test.pem"), tt.pem)
+
+			cfg := &config.Config{
+				CertificateDirectories: []string{certDir},
+				Workers:                1,
+				CacheDir:               filepath.Join(tmpDir, "cache"),
+				CacheTTL:               30 * time.Minute,
+				CacheMaxSize:           10485760,
+				ScanInterval:           1 * time.Minute,
+			}
+
+			registry := prometheus.NewRegistry()
+			metricsCollector := metrics.NewCollectorWithRegistry(registry)
+			log := logger.NewNop()
+
+			s, err := scanner.New(cfg, metricsCollector, log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			if err := s.Scan(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			families, err := registry.Gather()
+			if err != nil {
+				t.Fatal("Failed to gather metrics:", err)
+			}
+
+			found := false
+			for _, family := range families {
+				if family.GetName() == "ssl_cert_issuer_code" {
+					for _, metric := range family.GetMetric() {
+						found = true
+						if code := int(metric.GetGauge().GetValue()); code != tt.expectedCode {
+							t.Errorf("Expected issuer code %d, got %d", tt.expectedCode, code)
+						}
+					}
+				}
+			}
+
+			if !found {
+				t.Error("Expected ssl_cert_issuer_code metric to be present")
+			}
+		})
+	}
+}
+
+func TestOversizedFileSkipped(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	cert := generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour))
+	writeCertToFile(t, filepath.Join(certDir, "small.pem"), cert)
+
+	// Pad a second certificate past the limit
+	padded := append(append([]byte{}, cert...), make([]byte, 4096)...)
+	writeCertToFile(t, filepath.Join(certDir, "huge.pem"), padded)
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+		MaxCertFileSize:        int64(len(cert)),
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	log := logger.NewNop()
+
+	s, err := scanner.New(cfg, metricsCollector, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	values := metricsCollector.GetMetrics()
+	if values["certs_parsed_total"] != 1 {
+		t.Errorf("Expected 1 parsed certificate, got %v", values["certs_parsed_total"])
+	}
+	if values["cert_parse_errors_total"] != 0 {
+		t.Errorf("Expected oversized file to be skipped, not counted as an error, got %v", values["cert_parse_errors_total"])
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	oversized := -1.0
+	for _, family := range families {
+		if family.GetName() == "ssl_cert_oversized_files_total" {
+			oversized = family.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	if oversized != 1 {
+		t.Errorf("Expected 1 oversized file, got %v", oversized)
+	}
+}
+
+func TestKeyWeaknessMetrics(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	writeCertToFile(t, filepath.Join(certDir, "exponent.pem"), generateCertificateWithExponent(t, 2048, 3))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	log := logger.NewNop()
+
+	s, err := scanner.New(cfg, metricsCollector, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A small exponent alone makes the key weak
+	if weak := metricsCollector.GetMetrics()["weak_key_total"]; weak != 1 {
+		t.Errorf("Expected 1 weak key, got %v", weak)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	found := false
+	for _, family := range families {
+		if family.GetName() != "ssl_cert_key_weakness_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["reason"] == "small_exponent" && labels["file_name"] == "exponent.pem" &&
+				labels["common_name"] == "exponent.example.com" {
+				found = true
+			}
+		}
+	}
+
+	if !found {
+		t.Error("Expected ssl_cert_key_weakness_total with reason small_exponent")
+	}
+}
+
+func TestDisallowedSignatureAlgorithms(t *testing.T) {
+	tests := []struct {
+		name     string
+		allowed  []string
+		expected float64
+	}{
+		{"no_policy", nil, 0},
+		{"allowed", []string{"SHA256-RSA", "SHA384-RSA"}, 0},
+		{"disallowed", []string{"ECDSA-SHA384"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			certDir := filepath.Join(tmpDir, "certs")
+			os.MkdirAll(certDir, 0755)
+
+			// Signed with SHA256-RSA
+			writeCertToFile(t, filepath.Join(certDir, "test.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+			cfg := &config.Config{
+				CertificateDirectories: []string{certDir},
+				Workers:                1,
+				CacheDir:               filepath.Join(tmpDir, "cache"),
+				CacheTTL:               30 * time.Minute,
+				CacheMaxSize:           10485760,
+				ScanInterval:           1 * time.Minute,
+				AllowedSigAlgs:         tt.allowed,
+			}
+
+			metricsCollector := metrics.NewCollectorWithRegistry(prometheus.NewRegistry())
+			s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			if err := s.Scan(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := metricsCollector.GetMetrics()["disallowed_sigalg_total"]; got != tt.expected {
+				t.Errorf("disallowed_sigalg_total = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWalkPermissionErrors(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("Permission checks are bypassed when running as root")
+	}
+
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	lockedDir := filepath.Join(certDir, "locked")
+	os.MkdirAll(lockedDir, 0755)
+	writeCertToFile(t, filepath.Join(lockedDir, "hidden.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	if err := os.Chmod(lockedDir, 0000); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(lockedDir, 0755)
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+
+	s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	var permissionErrors float64
+	for _, family := range families {
+		if family.GetName() == "ssl_cert_walk_permission_errors_total" {
+			for _, metric := range family.GetMetric() {
+				permissionErrors += metric.GetCounter().GetValue()
+			}
+		}
+	}
+
+	// The locked subtree is reported once per scan
+	if permissionErrors != 1 {
+		t.Errorf("Expected 1 permission error, got %v", permissionErrors)
+	}
+}
+
+func TestIPSANValidation(t *testing.T) {
+	// Relies on the hosts file mapping 127.0.0.1 to localhost
+	names, err := net.DefaultResolver.LookupAddr(context.Background(), "127.0.0.1")
+	if err != nil || len(names) == 0 {
+		t.Skip("reverse lookup of 127.0.0.1 is not available")
+	}
+
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	loopback := []net.IP{net.ParseIP("127.0.0.1")}
+	writeCertToFile(t, filepath.Join(certDir, "match.pem"),
+		generateCertificateWithSANs(t, 2048, time.Now().Add(365*24*time.Hour), names, loopback))
+	writeCertToFile(t, filepath.Join(certDir, "mismatch.pem"),
+		generateCertificateWithSANs(t, 2048, time.Now().Add(365*24*time.Hour), []string{"example.com"}, loopback))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                2,
+		ValidateIPSANs:         true,
+		NetworkConcurrency:     1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	log := logger.NewNop()
+
+	s, err := scanner.New(cfg, metricsCollector, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	mismatched := make(map[string]string)
+	for _, family := range families {
+		if family.GetName() != "ssl_cert_ip_san_mismatch" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			mismatched[labels["file_name"]] = labels["ip"]
+		}
+	}
+
+	if len(mismatched) != 1 || mismatched["mismatch.pem"] != "127.0.0.1" {
+		t.Errorf("Expected a single mismatch for mismatch.pem on 127.0.0.1, got %v", mismatched)
+	}
+}
+
+func TestManifestScanning(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	otherDir := filepath.Join(tmpDir, "other")
+	os.MkdirAll(certDir, 0755)
+	os.MkdirAll(otherDir, 0755)
+
+	notAfter := time.Now().Add(365 * 24 * time.Hour)
+	writeCertToFile(t, filepath.Join(certDir, "listed.pem"), generateTestCertificate(t, 2048, notAfter))
+	writeCertToFile(t, filepath.Join(certDir, "unlisted.pem"), generateTestCertificate(t, 2048, notAfter))
+	writeCertToFile(t, filepath.Join(otherDir, "server.cert.txt"), generateTestCertificate(t, 2048, notAfter))
+
+	// Relative entries resolve against the manifest's directory; files are
+	// processed even without a certificate extension
+	manifest := filepath.Join(tmpDir, "manifest.txt")
+	content := "# certificate registry\ncerts/listed.pem\n\n" + filepath.Join(otherDir, "server.cert.txt") + "\n"
+	if err := os.WriteFile(manifest, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		ManifestFile:           manifest,
+		Workers:                2,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	log := logger.NewNop()
+
+	s, err := scanner.New(cfg, metricsCollector, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	scannedPaths := func() map[string]bool {
+		if err := s.Scan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		paths := make(map[string]bool)
+		for _, snapshot := range s.CertificateSnapshots() {
+			paths[snapshot.Path] = true
+		}
+		return paths
+	}
+
+	paths := scannedPaths()
+	if len(paths) != 2 || !paths[filepath.Join(certDir, "listed.pem")] || !paths[filepath.Join(otherDir, "server.cert.txt")] {
+		t.Errorf("Expected only the listed files to be scanned, got %v", paths)
+	}
+
+	// A JSON manifest replaces the list on the next scan
+	if err := os.WriteFile(manifest, []byte(`["certs/unlisted.pem"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	paths = scannedPaths()
+	if len(paths) != 1 || !paths[filepath.Join(certDir, "unlisted.pem")] {
+		t.Errorf("Expected the JSON manifest entry to be scanned, got %v", paths)
+	}
+
+	// A broken manifest fails the scan and keeps the previous results
+	if err := os.WriteFile(manifest, []byte(`["certs/listed.pem"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Scan(context.Background()); err == nil {
+		t.Error("Expected an error for an invalid manifest")
+	}
+	if got := len(s.CertificateSnapshots()); got != 1 {
+		t.Errorf("Expected previous results to be kept, got %d certificates", got)
+	}
+}
+
+func TestSCTMetrics(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	writeCertToFile(t, filepath.Join(certDir, "sct.pem"), createCertificateWithSCT(t))
+	writeCertToFile(t, filepath.Join(certDir, "plain.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	for _, checkSCT := range []bool{true, false} {
+		t.Run(fmt.Sprintf("check_sct_%v", checkSCT), func(t *testing.T) {
+			cfg := &config.Config{
+				CertificateDirectories: []string{certDir},
+				Workers:                1,
+				CheckSCT:               checkSCT,
+				CacheDir:               filepath.Join(t.TempDir(), "cache"),
+				CacheTTL:               30 * time.Minute,
+				CacheMaxSize:           10485760,
+				ScanInterval:           1 * time.Minute,
+			}
+
+			registry := prometheus.NewRegistry()
+			metricsCollector := metrics.NewCollectorWithRegistry(registry)
+			log := logger.NewNop()
+
+			s, err := scanner.New(cfg, metricsCollector, log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			if err := s.Scan(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			families, err := registry.Gather()
+			if err != nil {
+				t.Fatal("Failed to gather metrics:", err)
+			}
+
+			values := make(map[string]float64)
+			for _, family := range families {
+				if family.GetName() != "ssl_cert_has_sct" {
+					continue
+				}
+				for _, metric := range family.GetMetric() {
+					for _, label := range metric.GetLabel() {
+						if label.GetName() == "file_name" {
+							values[label.GetValue()] = metric.GetGauge().GetValue()
+						}
+					}
+				}
+			}
+
+			if !checkSCT {
+				if len(values) != 0 {
+					t.Errorf("Expected no ssl_cert_has_sct series when disabled, got %v", values)
+				}
+				return
+			}
+
+			if values["sct.pem"] != 1 || values["plain.pem"] != 0 || len(values) != 2 {
+				t.Errorf("Unexpected ssl_cert_has_sct values: %v", values)
+			}
+		})
+	}
+}
+
+func TestRenamedCertificate(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	oldPath := filepath.Join(certDir, "old.pem")
+	newPath := filepath.Join(certDir, "new.pem")
+	writeCertToFile(t, oldPath, generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	log := logger.NewNop()
+
+	s, err := scanner.New(cfg, metricsCollector, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	before := s.CertificateSnapshots()
+
+	if err := os.Rename(oldPath, newPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	after := s.CertificateSnapshots()
+
+	// The reused entry must describe the new path
+	if len(before) != 1 || len(after) != 1 {
+		t.Fatalf("Expected one certificate per scan, got %d and %d", len(before), len(after))
+	}
+	if after[0].Path != newPath || after[0].FileName != "new.pem" {
+		t.Errorf("Expected the renamed certificate at %s, got %s", newPath, after[0].Path)
+	}
+	if after[0].Fingerprint != before[0].Fingerprint {
+		t.Error("Expected the fingerprint to be unchanged by the rename")
+	}
+}
+
+func TestDuplicatePolicy(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	certPEM := generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour))
+	writeCertToFile(t, filepath.Join(certDir, "a.pem"), certPEM)
+	writeCertToFile(t, filepath.Join(certDir, "b.pem"), certPEM)
+	writeCertToFile(t, filepath.Join(certDir, "unique.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	// A chain shared by two directories, once in each, is not a duplicate
+	sharedPEM := generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour))
+	otherDir := filepath.Join(tmpDir, "other")
+	os.MkdirAll(otherDir, 0755)
+	writeCertToFile(t, filepath.Join(certDir, "shared.pem"), sharedPEM)
+	writeCertToFile(t, filepath.Join(otherDir, "shared.pem"), sharedPEM)
+
+	tests := []struct {
+		policy     string
+		violations float64
+	}{
+		{config.DuplicatePolicyCount, 0},
+		{config.DuplicatePolicyWarn, 0},
+		{config.DuplicatePolicyError, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			cfg := &config.Config{
+				CertificateDirectories: []string{certDir, otherDir},
+				Workers:                2,
+				DuplicatePolicy:        tt.policy,
+				CacheDir:               filepath.Join(t.TempDir(), "cache"),
+				CacheTTL:               30 * time.Minute,
+				CacheMaxSize:           10485760,
+				ScanInterval:           1 * time.Minute,
+			}
+
+			registry := prometheus.NewRegistry()
+			metricsCollector := metrics.NewCollectorWithRegistry(registry)
+			log := logger.NewNop()
+
+			s, err := scanner.New(cfg, metricsCollector, log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			if err := s.Scan(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			families, err := registry.Gather()
+			if err != nil {
+				t.Fatal("Failed to gather metrics:", err)
+			}
+
+			violations := 0.0
+			for _, family := range families {
+				if family.GetName() == "ssl_cert_duplicate_violation_total" {
+					violations = family.GetMetric()[0].GetCounter().GetValue()
+				}
+			}
+
+			if violations != tt.violations {
+				t.Errorf("ssl_cert_duplicate_violation_total = %v, want %v", violations, tt.violations)
+			}
+		})
+	}
+}
+
+func TestKeyUsageMetrics(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	writeCertToFile(t, filepath.Join(certDir, "server.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	for _, export := range []bool{true, false} {
+		t.Run(fmt.Sprintf("export_%v", export), func(t *testing.T) {
+			cfg := &config.Config{
+				CertificateDirectories: []string{certDir},
+				Workers:                1,
+				ExportKeyUsage:         export,
+				CacheDir:               filepath.Join(t.TempDir(), "cache"),
+				CacheTTL:               30 * time.Minute,
+				CacheMaxSize:           10485760,
+				ScanInterval:           1 * time.Minute,
+			}
+
+			registry := prometheus.NewRegistry()
+			metricsCollector := metrics.NewCollectorWithRegistry(registry)
+			log := logger.NewNop()
+
+			s, err := scanner.New(cfg, metricsCollector, log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			if err := s.Scan(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			families, err := registry.Gather()
+			if err != nil {
+				t.Fatal("Failed to gather metrics:", err)
+			}
+
+			series := make(map[string]bool)
+			for _, family := range families {
+				if family.GetName() != "ssl_cert_key_usage" && family.GetName() != "ssl_cert_ext_key_usage" {
+					continue
+				}
+				for _, metric := range family.GetMetric() {
+					for _, label := range metric.GetLabel() {
+						if label.GetName() == "usage" || label.GetName() == "eku" {
+							series[label.GetValue()] = true
+						}
+					}
+				}
+			}
+
+			if !export {
+				if len(series) != 0 {
+					t.Errorf("Expected no usage series when disabled, got %v", series)
+				}
+				return
+			}
+
+			for _, usage := range []string{"digital_signature", "key_encipherment", "server_auth"} {
+				if !series[usage] {
+					t.Errorf("Expected a series for %s, got %v", usage, series)
+				}
+			}
+		})
+	}
+}
+
+func TestDegradedMetric(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	writeCertToFile(t, filepath.Join(certDir, "cert.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		expected float64
+	}{
+		// Any scan outlasts a nanosecond interval
+		{"falling_behind", time.Nanosecond, 1},
+		{"keeping_up", 1 * time.Minute, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				CertificateDirectories: []string{certDir},
+				Workers:                1,
+				CacheDir:               filepath.Join(t.TempDir(), "cache"),
+				CacheTTL:               30 * time.Minute,
+				CacheMaxSize:           10485760,
+				ScanInterval:           tt.interval,
+			}
+
+			metricsCollector := metrics.NewCollectorWithRegistry(prometheus.NewRegistry())
+			s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			if err := s.Scan(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := metricsCollector.GetMetrics()["degraded"]; got != tt.expected {
+				t.Errorf("degraded = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCABundleIssuerCode(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	otherCA, _ := createCAAndLeaf(t, pkix.Name{CommonName: "Acme Root CA"}, pkix.Name{CommonName: "unused.example.org"})
+	issuingCA, bundledLeaf := createCAAndLeaf(t, pkix.Name{CommonName: "Acme Issuing CA 1"}, pkix.Name{CommonName: "bundled.example.org"})
+	_, unbundledLeaf := createCAAndLeaf(t, pkix.Name{CommonName: "DigiCert Global G2 TLS RSA SHA256 2020 CA1"}, pkix.Name{CommonName: "unbundled.example.org"})
+
+	writeCertToFile(t, filepath.Join(certDir, "bundled.pem"), bundledLeaf)
+	writeCertToFile(t, filepath.Join(certDir, "unbundled.pem"), unbundledLeaf)
+
+	// Codes follow the order of the bundle, starting at 100
+	bundleFile := filepath.Join(tmpDir, "ca-bundle.pem")
+	writeCertToFile(t, bundleFile, append(append([]byte{}, otherCA...), issuingCA...))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		CABundleFile:           bundleFile,
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	log := logger.NewNop()
+
+	s, err := scanner.New(cfg, metricsCollector, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	codes := make(map[string]int)
+	for _, family := range families {
+		if family.GetName() != "ssl_cert_issuer_code" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "file_name" {
+					codes[label.GetValue()] = int(metric.GetGauge().GetValue())
+				}
+			}
+		}
+	}
+
+	// Certificates from CAs outside the bundle fall back to name matching
+	if codes["bundled.pem"] != 101 || codes["unbundled.pem"] != 30 {
+		t.Errorf("Expected issuer codes 101 and 30, got %v", codes)
+	}
+}
+
+func TestDirLastChangeMetric(t *testing.T) {
+	tmpDir := t.TempDir()
+	webDir := filepath.Join(tmpDir, "web")
+	apiDir := filepath.Join(tmpDir, "api")
+	os.MkdirAll(filepath.Join(webDir, "nested"), 0755)
+	os.MkdirAll(apiDir, 0755)
+
+	webChanged := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	apiChanged := time.Date(2023, 6, 15, 8, 30, 0, 0, time.UTC)
+
+	files := map[string]time.Time{
+		filepath.Join(webDir, "old.pem"):           webChanged.Add(-90 * 24 * time.Hour),
+		filepath.Join(webDir, "nested", "new.pem"): webChanged,
+		filepath.Join(apiDir, "api.pem"):           apiChanged,
+	}
+	for path, modTime := range files {
+		writeCertToFile(t, path, generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{webDir, apiDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+
+	s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	lastChanges := func() map[string]int64 {
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal("Failed to gather metrics:", err)
+		}
+
+		changes := make(map[string]int64)
+		for _, family := range families {
+			if family.GetName() != "ssl_cert_dir_last_change_seconds" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "dir" {
+						changes[label.GetValue()] = int64(metric.GetGauge().GetValue())
+					}
+				}
+			}
+		}
+		return changes
+	}
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files in subdirectories count towards the configured directory
+	changes := lastChanges()
+	if changes[webDir] != webChanged.Unix() || changes[apiDir] != apiChanged.Unix() || len(changes) != 2 {
+		t.Errorf("Unexpected last change timestamps: %v", changes)
+	}
+
+	// A file moved back in time doesn't rewind the directory
+	older := webChanged.Add(-365 * 24 * time.Hour)
+	os.Chtimes(filepath.Join(webDir, "nested", "new.pem"), older, older)
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastChanges()[webDir]; got != webChanged.Unix() {
+		t.Errorf("Expected last change to stay at %d, got %d", webChanged.Unix(), got)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	cfg := &config.Config{
+		ExpiryThreshold: 30 * 24 * time.Hour,
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		problems int
+	}{
+		{"valid", generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)), 0},
+		{"expiring", generateTestCertificate(t, 2048, time.Now().Add(5*24*time.Hour)), 1},
+		// Expired with a weak key
+		{"expired_weak", createExpiredCertificate(t, 1024), 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inspection, err := scanner.Inspect(cfg, logger.NewNop(), "<stdin>", tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(inspection.Problems) != tt.problems {
+				t.Errorf("Expected %d problems, got %v", tt.problems, inspection.Problems)
+			}
+			if inspection.Name != "<stdin>" || inspection.Fingerprint == "" {
+				t.Errorf("Unexpected report: %+v", inspection)
+			}
+		})
+	}
+
+	if _, err := scanner.Inspect(cfg, logger.NewNop(), "<stdin>", []byte("not a certificate")); err == nil {
+		t.Error("Expected an error for invalid input")
+	}
+}
+
+func TestCommonNameLabel(t *testing.T) {
+	cfg := &config.Config{
+		ExpiryThreshold: 30 * 24 * time.Hour,
+	}
+
+	notAfter := time.Now().Add(365 * 24 * time.Hour)
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"common name", createCertificateWithCustomSubject(t, "CN=www.example.com"), "www.example.com"},
+		{"control characters", createCertificateWithCustomSubject(t, "CN=bad\x01name\x7f"), `bad\u0001name\u007f`},
+		{"dns san only", generateCertificateWithSANs(t, 2048, notAfter, []string{"api.example.com", "www.example.com"}, nil), "api.example.com"},
+		{"ip san only", generateCertificateWithSANs(t, 2048, notAfter, nil, []net.IP{net.ParseIP("192.0.2.10")}), "192.0.2.10"},
+		{"no names", generateCertificateWithSANs(t, 2048, notAfter, nil, nil), "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inspection, err := scanner.Inspect(cfg, logger.NewNop(), "<stdin>", tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if inspection.CommonName != tt.want {
+				t.Errorf("CommonName = %q, want %q", inspection.CommonName, tt.want)
+			}
+		})
+	}
+}
+
+func TestGzipCertificates(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	compress := func(data []byte) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	certPEM := generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour))
+	block, _ := pem.Decode(certPEM)
+
+	writeCertToFile(t, filepath.Join(certDir, "web.pem.gz"), compress(certPEM))
+	writeCertToFile(t, filepath.Join(certDir, "api.DER.GZ"), compress(block.Bytes))
+	// Private keys stay excluded when compressed
+	writeCertToFile(t, filepath.Join(certDir, "server.key.gz"), compress([]byte("not a certificate")))
+	// Compresses far below the limit but expands past it
+	writeCertToFile(t, filepath.Join(certDir, "bomb.pem.gz"), compress(append(append([]byte{}, certPEM...), make([]byte, 1<<20)...)))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+		MaxCertFileSize:        64 * 1024,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+
+	s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	values := metricsCollector.GetMetrics()
+	if values["cert_files_total"] != 3 {
+		t.Errorf("Expected 3 certificate files, got %v", values["cert_files_total"])
+	}
+	if values["certs_parsed_total"] != 2 {
+		t.Errorf("Expected 2 parsed certificates, got %v", values["certs_parsed_total"])
+	}
+	if values["cert_parse_errors_total"] != 0 {
+		t.Errorf("Expected no parse errors, got %v", values["cert_parse_errors_total"])
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	oversized := -1.0
+	for _, family := range families {
+		if family.GetName() == "ssl_cert_oversized_files_total" {
+			oversized = family.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	if oversized != 1 {
+		t.Errorf("Expected 1 oversized file, got %v", oversized)
+	}
+}
+
+func TestWeakCryptoWebhook(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	// The same weak certificate in two files is reported once
+	weak := createWeakKeyCertificate(t)
+	writeCertToFile(t, filepath.Join(certDir, "weak.pem"), weak)
+	writeCertToFile(t, filepath.Join(certDir, "weak-copy.pem"), weak)
+	writeCertToFile(t, filepath.Join(certDir, "good.pem"), createValidCertificate(t))
+
+	type finding struct {
+		FileName    string `json:"file_name"`
+		Fingerprint string `json:"fingerprint"`
+		Issue       string `json:"issue"`
+		Detail      string `json:"detail"`
+	}
+	payloads := make(chan []finding, 10)
+	var failNext atomic.Bool
+	failNext.Store(true)
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Findings []finding `json:"findings"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Failed to decode webhook payload: %v", err)
+		}
+		payloads <- payload.Findings
+
+		if failNext.Swap(false) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer webhook.Close()
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		WeakCryptoWebhookURL:   webhook.URL,
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(prometheus.NewRegistry()), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Scan until the webhook is called; a failed delivery is only forgotten
+	// once the request completes, which may be after the next scan started
+	scanUntilNotified := func() []finding {
+		for attempt := 0; attempt < 50; attempt++ {
+			if err := s.Scan(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case findings := <-payloads:
+				return findings
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+		t.Fatal("Timed out waiting for webhook")
+		return nil
+	}
+
+	// The first delivery fails, so the finding is sent again on a later scan
+	for i := 0; i < 2; i++ {
+		findings := scanUntilNotified()
+		if len(findings) != 1 || findings[0].Issue != "weak_key" || findings[0].Detail != "small_modulus" {
+			t.Fatalf("Unexpected findings on delivery %d: %+v", i+1, findings)
+		}
+	}
+
+	// Delivered findings are not sent again
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case findings := <-payloads:
+		t.Errorf("Unexpected repeat notification: %+v", findings)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWeakCryptoWebhookWithScanTimeout(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+	writeCertToFile(t, filepath.Join(certDir, "weak.pem"), createWeakKeyCertificate(t))
+
+	// Respond only after Scan returned and released its scan_timeout context
+	delivered := make(chan struct{}, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		delivered <- struct{}{}
+	}))
+	defer webhook.Close()
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		WeakCryptoWebhookURL:   webhook.URL,
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+		ScanTimeout:            1 * time.Minute,
+	}
+
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(prometheus.NewRegistry()), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for webhook")
+	}
+
+	// A delivery canceled along with the scan would be forgotten and sent
+	// again by the next scan
+	time.Sleep(200 * time.Millisecond)
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-delivered:
+		t.Error("Finding sent again after a successful delivery")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestSPKIMetric(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	certPEM := generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour))
+	writeCertToFile(t, filepath.Join(certDir, "pinned.pem"), certPEM)
+
+	block, _ := pem.Decode(certPEM)
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := cert.SPKISHA256(parsed)
+
+	for _, exportSPKI := range []bool{false, true} {
+		cfg := &config.Config{
+			CertificateDirectories: []string{certDir},
+			Workers:                1,
+			CacheDir:               filepath.Join(tmpDir, "cache"),
+			CacheTTL:               30 * time.Minute,
+			CacheMaxSize:           10485760,
+			ScanInterval:           1 * time.Minute,
+			ExportSPKI:             exportSPKI,
+		}
+
+		registry := prometheus.NewRegistry()
+		metricsCollector := metrics.NewCollectorWithRegistry(registry)
+
+		s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Scan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal("Failed to gather metrics:", err)
+		}
+
+		var pins []string
+		for _, family := range families {
+			if family.GetName() != "ssl_cert_spki_info" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "spki_sha256" {
+						pins = append(pins, label.GetValue())
+					}
+				}
+			}
+		}
+
+		switch {
+		case !exportSPKI && len(pins) != 0:
+			t.Errorf("Expected no SPKI metric without export_spki, got %v", pins)
+		case exportSPKI && (len(pins) != 1 || pins[0] != want):
+			t.Errorf("Expected SPKI pin %q, got %v", want, pins)
+		}
+
+		s.Close()
+	}
+}
+
+func TestOrganizationMetric(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	writeCertToFile(t, filepath.Join(certDir, "acme.pem"), createCertificateWithCustomSubject(t, "CN=acme.example.com, O=Acme Corp, O=Acme Labs"))
+	writeCertToFile(t, filepath.Join(certDir, "none.pem"), createCertificateWithCustomSubject(t, "CN=none.example.com"))
+
+	for _, exportOrganization := range []bool{false, true} {
+		cfg := &config.Config{
+			CertificateDirectories: []string{certDir},
+			Workers:                1,
+			CacheDir:               filepath.Join(tmpDir, "cache"),
+			CacheTTL:               30 * time.Minute,
+			CacheMaxSize:           10485760,
+			ScanInterval:           1 * time.Minute,
+			ExportOrganization:     exportOrganization,
+		}
+
+		registry := prometheus.NewRegistry()
+		s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(registry), logger.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Scan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal("Failed to gather metrics:", err)
+		}
+
+		organizations := make(map[string]string)
+		for _, family := range families {
+			if family.GetName() != "ssl_cert_organization_info" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				labels := make(map[string]string)
+				for _, label := range metric.GetLabel() {
+					labels[label.GetName()] = label.GetValue()
+				}
+				organizations[labels["file_name"]] = labels["organization"]
+			}
+		}
+
+		// Certificates without an organization have no series
+		switch {
+		case !exportOrganization && len(organizations) != 0:
+			t.Errorf("Expected no organization metric without export_organization, got %v", organizations)
+		case exportOrganization && (len(organizations) != 1 || organizations["acme.pem"] != "Acme Corp, Acme Labs"):
+			t.Errorf("Expected only acme.pem with organization \"Acme Corp, Acme Labs\", got %v", organizations)
+		}
+
+		s.Close()
+	}
+}
+
+func TestParseErrorReasons(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	writeCertToFile(t, filepath.Join(certDir, "valid.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+	writeCertToFile(t, filepath.Join(certDir, "notes.pem"), []byte("this is not a certificate\n"))
+	writeCertToFile(t, filepath.Join(certDir, "request.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: []byte("csr")}))
+	writeCertToFile(t, filepath.Join(certDir, "corrupt.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("truncated")}))
+	writeCertToFile(t, filepath.Join(certDir, "broken.pem.gz"), []byte("not gzip"))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+
+	s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if total := metricsCollector.GetMetrics()["cert_parse_errors_total"]; total != 4 {
+		t.Errorf("Expected 4 parse errors, got %v", total)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	byReason := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != "ssl_cert_parse_errors_by_reason" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			byReason[metric.GetLabel()[0].GetValue()] = metric.GetGauge().GetValue()
+		}
+	}
+
+	expected := map[string]float64{
+		"read_error":              0,
+		"decompress_error":        1,
+		"invalid_secret_manifest": 0,
+		"no_pem_block":            1,
+		"not_certificate":         1,
+		"x509_parse":              1,
+	}
+	for reason, want := range expected {
+		got, ok := byReason[reason]
+		if !ok {
+			t.Errorf("Missing parse errors for reason %s", reason)
+			continue
+		}
+		if got != want {
+			t.Errorf("Parse errors for %s = %v, want %v", reason, got, want)
+		}
+	}
+}
+
+func TestScanCanceled(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	writeCertToFile(t, filepath.Join(certDir, "first.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(prometheus.NewRegistry()), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	writeCertToFile(t, filepath.Join(certDir, "second.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Scan(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Scan() error = %v, want context.Canceled", err)
+	}
+
+	// The partial scan doesn't replace the previous results
+	if got := len(s.Certificates()); got != 1 {
+		t.Errorf("Expected the previous scan's 1 certificate, got %d", got)
+	}
+}
+
+func TestDeprecatedCurveMetric(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	writeCertToFile(t, filepath.Join(certDir, "p224.pem"), generateECDSACertificate(t, elliptic.P224()))
+	writeCertToFile(t, filepath.Join(certDir, "p256.pem"), generateECDSACertificate(t, elliptic.P256()))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+		DeprecatedCurves:       []string{"p-224"},
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+
+	s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	var deprecated, weak []map[string]string
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			switch family.GetName() {
+			case "ssl_cert_deprecated_curve_total":
+				deprecated = append(deprecated, labels)
+			case "ssl_cert_key_weakness_total":
+				weak = append(weak, labels)
+			}
+		}
+	}
+
+	if len(deprecated) != 1 || deprecated[0]["file_name"] != "p224.pem" || deprecated[0]["curve"] != "P-224" {
+		t.Errorf("Expected only p224.pem on a deprecated curve, got %v", deprecated)
+	}
+	if len(weak) != 1 || weak[0]["file_name"] != "p224.pem" || weak[0]["reason"] != "small_curve" {
+		t.Errorf("Expected only p224.pem to have a small curve, got %v", weak)
+	}
+}
+
+func TestWatchDebounce(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+		WatchDebounce:          300 * time.Millisecond,
+	}
+
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(prometheus.NewRegistry()), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.WatchFiles(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	writeCertToFile(t, filepath.Join(certDir, "rotated.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	// Nothing happens until the file has been quiet for the debounce
+	time.Sleep(100 * time.Millisecond)
+	if got := len(s.Certificates()); got != 0 {
+		t.Errorf("Expected the change to wait for the debounce, got %d certificates", got)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.Certificates()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the new certificate after the debounce")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestWatchStableCheck(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+		WatchStableCheck:       50 * time.Millisecond,
+	}
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(prometheus.NewRegistry()), zap.New(core))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.WatchFiles(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	// A deploy creating the file before writing it
+	path := filepath.Join(certDir, "deployed.pem")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := f.Write(generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.Certificates()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the deployed certificate once written")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if failures := logs.FilterMessage("Failed to process changed certificate").Len(); failures != 0 {
+		t.Errorf("Expected no parse failure for the file caught mid-write, got %d", failures)
+	}
+}
+
+func TestWatchStableCheckDoesNotBlockOtherFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+		WatchStableCheck:       200 * time.Millisecond,
+	}
+
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(prometheus.NewRegistry()), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.WatchFiles(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	// An empty file is waited for until it gives up, 20 checks later
+	empty, err := os.Create(filepath.Join(certDir, "pending.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	writeCertToFile(t, filepath.Join(certDir, "ready.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(s.Certificates()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected ready.pem while pending.pem is still waited for")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestCacheHitMissMetrics(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	for _, name := range []string{"a.pem", "b.pem"} {
+		writeCertToFile(t, filepath.Join(certDir, name), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+	}
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	metricsCollector := metrics.NewCollectorWithRegistry(prometheus.NewRegistry())
+
+	s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	m := metricsCollector.GetMetrics()
+	if m["cache_hits_total"] != 0 || m["cache_misses_total"] != 2 {
+		t.Errorf("Expected 0 hits and 2 misses on the first scan, got %v hits and %v misses",
+			m["cache_hits_total"], m["cache_misses_total"])
+	}
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	m = metricsCollector.GetMetrics()
+	if m["cache_hits_total"] != 2 || m["cache_misses_total"] != 2 {
+		t.Errorf("Expected 2 hits and 2 misses after the second scan, got %v hits and %v misses",
+			m["cache_hits_total"], m["cache_misses_total"])
+	}
+}
+
+func TestCertByDomainMetric(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	writeCertToFile(t, filepath.Join(certDir, "wildcard.pem"), createCertificateWithCustomSubject(t, "CN=*.example.com"))
+	writeCertToFile(t, filepath.Join(certDir, "www.pem"), createCertificateWithCustomSubject(t, "CN=www.example.com"))
+	writeCertToFile(t, filepath.Join(certDir, "san-only.pem"),
+		generateCertificateWithSANs(t, 2048, time.Now().Add(365*24*time.Hour), []string{"api.eu.example.org"}, nil))
+	writeCertToFile(t, filepath.Join(certDir, "ip-only.pem"),
+		generateCertificateWithSANs(t, 2048, time.Now().Add(365*24*time.Hour), nil, []net.IP{net.ParseIP("10.0.0.1")}))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+
+	s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	domains := make(map[string]string)
+	for _, family := range families {
+		if family.GetName() != "ssl_cert_by_domain" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			domains[labels["file_name"]] = labels["base_domain"]
+		}
+	}
+
+	expected := map[string]string{
+		"wildcard.pem": "example.com",
+		"www.pem":      "example.com",
+		"san-only.pem": "eu.example.org",
+	}
+	if len(domains) != len(expected) {
+		t.Errorf("Expected %d ssl_cert_by_domain series, got %v", len(expected), domains)
+	}
+	for fileName, want := range expected {
+		if got := domains[fileName]; got != want {
+			t.Errorf("Expected base_domain %q for %s, got %q", want, fileName, got)
+		}
+	}
+}
+
+func TestInsecureDirMetric(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not checked on Windows")
+	}
+
+	tmpDir := t.TempDir()
+	secureDir := filepath.Join(tmpDir, "secure")
+	insecureDir := filepath.Join(tmpDir, "insecure")
+	os.MkdirAll(secureDir, 0755)
+	os.MkdirAll(insecureDir, 0755)
+	os.Chmod(insecureDir, 0777)
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{secureDir, insecureDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+
+	s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	insecure := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != "ssl_cert_insecure_dir" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			insecure[metric.GetLabel()[0].GetValue()] = metric.GetGauge().GetValue()
+		}
+	}
+
+	if value, ok := insecure[secureDir]; !ok || value != 0 {
+		t.Errorf("Expected %s to be reported secure, got %v", secureDir, insecure)
+	}
+	if insecure[insecureDir] != 1 {
+		t.Errorf("Expected %s to be reported insecure, got %v", insecureDir, insecure)
+	}
+}
+
+func TestInsecureDirWarning(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not checked on Windows")
+	}
+
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+	os.Chmod(certDir, 0777)
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(prometheus.NewRegistry()), zap.New(core))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	scanTimes := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			if err := s.Scan(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	warnings := func() int {
+		return logs.FilterMessage("Certificate directory is group or world writable").Len()
+	}
+
+	// Warned once however many scans see the directory insecure
+	scanTimes(3)
+	if got := warnings(); got != 1 {
+		t.Errorf("Expected 1 warning over 3 scans, got %d", got)
+	}
+
+	// Fixing the permissions is logged, loosening them again warns again
+	os.Chmod(certDir, 0755)
+	scanTimes(2)
+	if got := logs.FilterMessage("Certificate directory is no longer group or world writable").Len(); got != 1 {
+		t.Errorf("Expected the fix to be logged once, got %d", got)
+	}
+	os.Chmod(certDir, 0777)
+	scanTimes(2)
+	if got := warnings(); got != 2 {
+		t.Errorf("Expected a second warning after the permissions changed back, got %d", got)
+	}
+}
+
+func TestScannerWait(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+	writeCertToFile(t, filepath.Join(certDir, "a.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(prometheus.NewRegistry()), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// An idle scanner doesn't wait, even with an expired context
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Wait(expired); err != nil {
+		t.Errorf("Wait() on an idle scanner = %v, want nil", err)
+	}
+
+	// Overlapping scans, twice over, leave the scanner idle again
+	for round := 0; round < 2; round++ {
+		done := make(chan struct{})
+		for i := 0; i < 3; i++ {
+			go func() {
+				s.Scan(context.Background())
+				done <- struct{}{}
+			}()
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		for i := 0; i < 3; i++ {
+			<-done
+		}
+		if err := s.Wait(ctx); err != nil {
+			t.Errorf("Wait() after the scans finished = %v, want nil", err)
+		}
+		cancel()
+	}
+}
+
+func TestExcessiveValidityMetric(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	// generateTestCertificate backdates NotBefore by a day, so the span is just
+	// under 36501 days
+	writeCertToFile(t, filepath.Join(certDir, "yearly.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+	writeCertToFile(t, filepath.Join(certDir, "century.pem"), generateTestCertificate(t, 2048, time.Now().Add(36500*24*time.Hour)))
+
+	for _, maxValidityDays := range []int{0, 825} {
+		cfg := &config.Config{
+			CertificateDirectories: []string{certDir},
+			Workers:                1,
+			CacheDir:               filepath.Join(tmpDir, "cache"),
+			CacheTTL:               30 * time.Minute,
+			CacheMaxSize:           10485760,
+			ScanInterval:           1 * time.Minute,
+			MaxValidityDays:        maxValidityDays,
+		}
+
+		registry := prometheus.NewRegistry()
+		metricsCollector := metrics.NewCollectorWithRegistry(registry)
+
+		s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Scan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal("Failed to gather metrics:", err)
+		}
+
+		flagged := make(map[string]float64)
+		for _, family := range families {
+			if family.GetName() != "ssl_cert_excessive_validity" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "file_name" {
+						flagged[label.GetValue()] = metric.GetGauge().GetValue()
+					}
+				}
+			}
+		}
+
+		switch {
+		case maxValidityDays == 0 && len(flagged) != 0:
+			t.Errorf("Expected no excessive validity series with the check disabled, got %v", flagged)
+		case maxValidityDays > 0 && (len(flagged) != 1 || flagged["century.pem"] < 36500):
+			t.Errorf("Expected only century.pem flagged with about 36500 days, got %v", flagged)
+		}
+
+		s.Close()
+	}
+}
+
+func TestPolicyViolationMetric(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	writeCertToFile(t, filepath.Join(certDir, "quarterly.pem"), generateTestCertificate(t, 2048, time.Now().Add(90*24*time.Hour)))
+	writeCertToFile(t, filepath.Join(certDir, "yearly.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	// The latest policy that has taken effect applies, whatever the order
+	day := func(offset int) string {
+		return time.Now().AddDate(0, 0, offset).Format("2006-01-02")
+	}
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+		ValidityPolicies: []config.ValidityPolicy{
+			{Name: "future", From: day(30), MaxValidityDays: 47},
+			{Name: "current", From: day(-30), MaxValidityDays: 200},
+			{Name: "old", From: day(-3650), MaxValidityDays: 825},
+		},
+	}
+
+	registry := prometheus.NewRegistry()
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(registry), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	violations := make(map[string]string)
+	for _, family := range families {
+		if family.GetName() != "ssl_cert_policy_violation" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			violations[labels["file_name"]] = labels["policy"]
+			if days := metric.GetGauge().GetValue(); days < 365 {
+				t.Errorf("ssl_cert_policy_violation for %s = %v, want the validity period in days", labels["file_name"], days)
+			}
+		}
+	}
+
+	if len(violations) != 1 || violations["yearly.pem"] != "current" {
+		t.Errorf("Expected only yearly.pem to violate the current policy, got %v", violations)
+	}
+}
+
+func TestScanJitter(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{tmpDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+		ScanJitter:             200 * time.Millisecond,
+	}
+
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(prometheus.NewRegistry()), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	started := time.Now()
+	if err := s.Jitter(context.Background()); err != nil {
+		t.Fatalf("Jitter() = %v, want nil", err)
+	}
+	if elapsed := time.Since(started); elapsed > 200*time.Millisecond+100*time.Millisecond {
+		t.Errorf("Jitter() slept %v, longer than scan_jitter", elapsed)
+	}
+
+	// Cancellation cuts the sleep short
+	cfg.ScanJitter = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started = time.Now()
+	if err := s.Jitter(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Jitter() = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Jitter() ignored cancellation, slept %v", elapsed)
+	}
+}
+
+func TestCertRotationMetric(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+	certPath := filepath.Join(certDir, "server.pem")
+	writeCertToFile(t, certPath, createCertificateWithCustomSubject(t, "CN=rotated.example.com"))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+
+	s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	original := s.Certificates()[0].Fingerprint
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.WatchFiles(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	// Replace the certificate in place, as a renewal would
+	writeCertToFile(t, certPath, createCertificateWithCustomSubject(t, "CN=rotated.example.com"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Certificates()[0].Fingerprint == original {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the rewritten certificate to be picked up")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// A later scan of the same certificate is not another rotation
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	var rotations float64
+	for _, family := range families {
+		if family.GetName() != "ssl_cert_rotations_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			rotations += metric.GetCounter().GetValue()
+		}
+	}
+	if rotations != 1 {
+		t.Errorf("Expected 1 rotation, got %v", rotations)
+	}
+}
+
+func TestWatchedDirsAndMonitoredFilesMetrics(t *testing.T) {
+	tmpDir := t.TempDir()
+	dirA := filepath.Join(tmpDir, "a")
+	dirB := filepath.Join(tmpDir, "b")
+	os.MkdirAll(dirA, 0755)
+	os.MkdirAll(dirB, 0755)
+
+	writeCertToFile(t, filepath.Join(dirA, "one.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+	writeCertToFile(t, filepath.Join(dirA, "two.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+	writeCertToFile(t, filepath.Join(dirB, "three.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{dirA, dirB},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	metricsCollector := metrics.NewCollectorWithRegistry(prometheus.NewRegistry())
+
+	s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := metricsCollector.GetMetrics()["monitored_files"]; got != 3 {
+		t.Errorf("Expected 3 monitored files, got %v", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.WatchFiles(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for metricsCollector.GetMetrics()["watched_dirs"] != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 2 watched directories, got %v", metricsCollector.GetMetrics()["watched_dirs"])
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	os.Remove(filepath.Join(dirA, "two.pem"))
+
+	deadline = time.Now().Add(5 * time.Second)
+	for metricsCollector.GetMetrics()["monitored_files"] != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 2 monitored files after a removal, got %v", metricsCollector.GetMetrics()["monitored_files"])
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestScanTimeout(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+	writeCertToFile(t, filepath.Join(certDir, "a.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+		ScanTimeout:            200 * time.Millisecond,
+	}
+
+	metricsCollector := metrics.NewCollectorWithRegistry(prometheus.NewRegistry())
+
+	s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Opening a FIFO without a writer blocks like a read from hung storage
+	fifo := filepath.Join(certDir, "stuck.pem")
+	if err := exec.Command("mkfifo", fifo).Run(); err != nil {
+		t.Skip("mkfifo not available:", err)
+	}
+	defer func() {
+		// Let the abandoned read finish
+		if f, err := os.OpenFile(fifo, os.O_WRONLY, 0); err == nil {
+			f.Close()
+		}
+	}()
+
+	started := time.Now()
+	err = s.Scan(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Scan() = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("Scan() returned after %v, long after scan_timeout", elapsed)
+	}
+
+	if got := metricsCollector.GetMetrics()["scan_timeouts_total"]; got != 1 {
+		t.Errorf("Expected 1 scan timeout, got %v", got)
+	}
+
+	// The abandoned scan keeps the previous results
+	if got := len(s.Certificates()); got != 1 {
+		t.Errorf("Expected the previous scan's certificate to remain, got %d", got)
+	}
+}
+
+func TestScanCanceledWaitsForWorkers(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+	writeCertToFile(t, filepath.Join(certDir, "a.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+		ScanTimeout:            1 * time.Minute,
+	}
+
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(prometheus.NewRegistry()), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Opening a FIFO without a writer keeps a worker busy
+	fifo := filepath.Join(certDir, "stuck.pem")
+	if err := exec.Command("mkfifo", fifo).Run(); err != nil {
+		t.Skip("mkfifo not available:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	scanned := make(chan error, 1)
+	go func() {
+		scanned <- s.Scan(ctx)
+	}()
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+
+	// Shutdown, unlike scan_timeout, waits for the busy worker
+	select {
+	case err := <-scanned:
+		t.Fatalf("Scan() = %v returned while a worker was still running", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	if f, err := os.OpenFile(fifo, os.O_WRONLY, 0); err == nil {
+		f.Close()
+	}
+	select {
+	case err := <-scanned:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Scan() = %v, want canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Scan() didn't return once the worker finished")
+	}
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), time.Second)
+	defer waitCancel()
+	if err := s.Wait(waitCtx); err != nil {
+		t.Errorf("Wait() after the canceled scan = %v, want nil", err)
+	}
+}
+
+func TestScanCanceledPublishesPartialResults(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+	writeCertToFile(t, filepath.Join(certDir, "a.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(registry), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetPartialResults(true)
+
+	// a.pem is parsed before the worker blocks on the FIFO
+	fifo := filepath.Join(certDir, "stuck.pem")
+	if err := exec.Command("mkfifo", fifo).Run(); err != nil {
+		t.Skip("mkfifo not available:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	scanned := make(chan error, 1)
+	go func() {
+		scanned <- s.Scan(ctx)
+	}()
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	if f, err := os.OpenFile(fifo, os.O_WRONLY, 0); err == nil {
+		f.Close()
+	}
+
+	select {
+	case err := <-scanned:
+		if !errors.Is(err, scanner.ErrPartialScan) || !errors.Is(err, context.Canceled) {
+			t.Errorf("Scan() = %v, want a canceled partial scan", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Scan() didn't return once the worker finished")
+	}
+
+	if got := len(s.Certificates()); got != 1 {
+		t.Errorf("Expected the 1 certificate found before the cancel, got %d", got)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, family := range families {
+		if family.GetName() == "ssl_cert_expiration_timestamp" {
+			found = len(family.GetMetric()) == 1
+		}
+	}
+	if !found {
+		t.Error("Expected the partial scan to export the certificate's metrics")
+	}
+}
+
+func TestScansInFlightMetric(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+	writeCertToFile(t, filepath.Join(certDir, "a.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(registry), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Overlapping scans must leave the gauge at zero once all are done
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Scan(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "ssl_cert_scans_in_flight" {
+			continue
+		}
+		if got := family.GetMetric()[0].GetGauge().GetValue(); got != 0 {
+			t.Errorf("Expected no scans in flight, got %v", got)
+		}
+		return
+	}
+	t.Error("ssl_cert_scans_in_flight not exported")
+}
+
+func TestScanS3Bucket(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+
+	var (
+		objectPEM atomic.Value
+		etag      atomic.Value
+	)
+	objectPEM.Store(createCertificateWithCustomSubject(t, "CN=s3-one.example.com"))
+	etag.Store("v1")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bucket":
+			fmt.Fprintf(w, `<ListBucketResult>
+  <Contents><Key>certs/server.pem</Key><ETag>"%s"</ETag><Size>1</Size><LastModified>2024-01-02T00:00:00.000Z</LastModified></Contents>
+  <Contents><Key>certs/server.key</Key><ETag>"key"</ETag><Size>1</Size><LastModified>2024-01-02T00:00:00.000Z</LastModified></Contents>
+  <IsTruncated>false</IsTruncated>
+</ListBucketResult>`, etag.Load())
+		case "/bucket/certs/server.pem":
+			w.Write(objectPEM.Load().([]byte))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+	writeCertToFile(t, filepath.Join(certDir, "local.pem"), createCertificateWithCustomSubject(t, "CN=local.example.com"))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		S3Bucket:               "bucket",
+		S3Prefix:               "certs/",
+		S3Region:               "us-east-1",
+		S3Endpoint:             server.URL,
+		Workers:                2,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(prometheus.NewRegistry()), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	commonNames := func() map[string]string {
+		names := make(map[string]string)
+		for _, certInfo := range s.Certificates() {
+			names[certInfo.Path] = certInfo.CommonName()
+		}
+		return names
+	}
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	names := commonNames()
+	if len(names) != 2 {
+		t.Fatalf("Expected the local certificate and one object, got %v", names)
+	}
+	if got := names["s3://bucket/certs/server.pem"]; got != "s3-one.example.com" {
+		t.Errorf("Expected s3://bucket/certs/server.pem to hold s3-one.example.com, got %v", names)
+	}
+
+	// An object replaced in place is picked up by its new ETag
+	objectPEM.Store(createCertificateWithCustomSubject(t, "CN=s3-two.example.com"))
+	etag.Store("v2")
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := commonNames()["s3://bucket/certs/server.pem"]; got != "s3-two.example.com" {
+		t.Errorf("Expected the replaced object to hold s3-two.example.com, got %q", got)
+	}
+}
+
+func TestRenewalDueMetric(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	// Both certificates expire in a year; only DigiCert has a window that long
+	writeCertToFile(t, filepath.Join(certDir, "digicert.pem"), createCertificateWithIssuer(t, "CN=DigiCert TLS RSA SHA256 2020 CA1,O=DigiCert Inc,C=US"))
+	writeCertToFile(t, filepath.Join(certDir, "sectigo.pem"), createCertificateWithIssuer(t, "CN=Sectigo RSA Domain Validation Secure Server CA,O=Sectigo Limited,C=GB"))
+
+	for _, collectorMode := range []bool{false, true} {
+		cfg := &config.Config{
+			CertificateDirectories: []string{certDir},
+			Workers:                1,
+			CacheDir:               filepath.Join(tmpDir, "cache"),
+			CacheTTL:               30 * time.Minute,
+			CacheMaxSize:           10485760,
+			ScanInterval:           1 * time.Minute,
+			ExpiryThreshold:        30 * 24 * time.Hour,
+			RenewalThresholds:      map[int]time.Duration{30: 400 * 24 * time.Hour},
+			CollectorMode:          collectorMode,
+		}
+
+		registry := prometheus.NewRegistry()
+		s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(registry), logger.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Scan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal("Failed to gather metrics:", err)
+		}
+
+		due := make(map[string]float64)
+		for _, family := range families {
+			if family.GetName() != "ssl_cert_renewal_due" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "file_name" {
+						due[label.GetValue()] = metric.GetGauge().GetValue()
+					}
+				}
+			}
+		}
+		s.Close()
+
+		want := map[string]float64{"digicert.pem": 1, "sectigo.pem": 0}
+		for fileName, value := range want {
+			got, ok := due[fileName]
+			if !ok || got != value {
+				t.Errorf("collector_mode=%v: ssl_cert_renewal_due for %s = %v (present %v), want %v",
+					collectorMode, fileName, got, ok, value)
+			}
+		}
+	}
+}
+
+func TestScanMaxDepth(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	nested := filepath.Join(certDir, "a", "b")
+	os.MkdirAll(nested, 0755)
+
+	certPEM := generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour))
+	writeCertToFile(t, filepath.Join(certDir, "top.pem"), certPEM)
+	writeCertToFile(t, filepath.Join(certDir, "a", "middle.pem"), certPEM)
+	writeCertToFile(t, filepath.Join(nested, "deep.pem"), certPEM)
+
+	tests := []struct {
+		maxDepth int
+		want     int
+	}{
+		{0, 3},
+		{1, 1},
+		{2, 2},
+		{3, 3},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{
+			CertificateDirectories: []string{certDir},
+			Workers:                1,
+			CacheDir:               filepath.Join(tmpDir, "cache"),
+			CacheTTL:               30 * time.Minute,
+			CacheMaxSize:           10485760,
+			ScanInterval:           1 * time.Minute,
+			MaxDepth:               tt.maxDepth,
+		}
+
+		s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(prometheus.NewRegistry()), logger.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Scan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(s.Certificates()); got != tt.want {
+			t.Errorf("max_depth=%d: found %d certificates, want %d", tt.maxDepth, got, tt.want)
+		}
+		s.Close()
+	}
+}
+
+func TestScanMaxConcurrentDirs(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// More directories than the directories walked at once
+	certPEM := generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour))
+	var dirs, want []string
+	for i := 0; i < 5; i++ {
+		dir := filepath.Join(tmpDir, fmt.Sprintf("certs%d", i))
+		os.MkdirAll(dir, 0755)
+		for j := 0; j < 3; j++ {
+			path := filepath.Join(dir, fmt.Sprintf("cert%d.pem", j))
+			writeCertToFile(t, path, certPEM)
+			want = append(want, path)
+		}
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(want)
+
+	for _, maxConcurrentDirs := range []int{1, 2, 8} {
+		registry := prometheus.NewRegistry()
+		cfg := &config.Config{
+			CertificateDirectories: dirs,
+			Workers:                4,
+			MaxConcurrentDirs:      maxConcurrentDirs,
+			CacheDir:               filepath.Join(tmpDir, "cache"),
+			CacheTTL:               30 * time.Minute,
+			CacheMaxSize:           10485760,
+			ScanInterval:           1 * time.Minute,
+		}
+
+		s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(registry), logger.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Scan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, certInfo := range s.Certificates() {
+			got = append(got, certInfo.Path)
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("max_concurrent_dirs=%d: found %v, want %v", maxConcurrentDirs, got, want)
+		}
+		s.Close()
+	}
+}
+
+func TestScanWalkWorkers(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+
+	// A tree wider and deeper than the walk workers, with an excluded
+	// subtree and files too deep for max_depth
+	certPEM := generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour))
+	var want []string
+	for i := 0; i < 6; i++ {
+		dir := filepath.Join(certDir, fmt.Sprintf("app%d", i))
+		for depth := 0; depth < 4; depth++ {
+			os.MkdirAll(dir, 0755)
+			path := filepath.Join(dir, fmt.Sprintf("cert%d.pem", depth))
+			writeCertToFile(t, path, certPEM)
+			if depth < 2 {
+				want = append(want, path)
+			}
+			dir = filepath.Join(dir, "sub")
+		}
+	}
+	os.MkdirAll(filepath.Join(certDir, "staging", "sub"), 0755)
+	writeCertToFile(t, filepath.Join(certDir, "staging", "skipped.pem"), certPEM)
+	writeCertToFile(t, filepath.Join(certDir, "staging", "sub", "skipped.pem"), certPEM)
+	sort.Strings(want)
+
+	for _, walkWorkers := range []int{1, 4} {
+		cfg := &config.Config{
+			CertificateDirectories: []string{certDir},
+			Workers:                2,
+			WalkWorkers:            walkWorkers,
+			CacheDir:               filepath.Join(tmpDir, "cache"),
+			CacheTTL:               30 * time.Minute,
+			CacheMaxSize:           10485760,
+			ScanInterval:           1 * time.Minute,
+			MaxDepth:               3,
+			ExcludePaths:           []string{"staging"},
+		}
+
+		s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(prometheus.NewRegistry()), logger.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Scan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, certInfo := range s.Certificates() {
+			got = append(got, certInfo.Path)
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("walk_workers=%d: found %v, want %v", walkWorkers, got, want)
+		}
+		s.Close()
+	}
+}
+
+func TestScanSymlinkRotation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on Windows")
+	}
+
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(filepath.Join(certDir, "..2024_01"), 0755)
+
+	// Kubernetes secret volume layout: tls.crt -> ..data/tls.crt, ..data -> ..2024_01
+	firstExpiry := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	writeCertToFile(t, filepath.Join(certDir, "..2024_01", "tls.crt"), generateTestCertificate(t, 2048, firstExpiry))
+	if err := os.Symlink("..2024_01", filepath.Join(certDir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("..data", "tls.crt"), filepath.Join(certDir, "tls.crt")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+		MaxDepth:               1,
+		ResolveSymlinks:        true,
+	}
+
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(prometheus.NewRegistry()), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	notAfter := func() time.Time {
+		t.Helper()
+		if err := s.Scan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		for _, certInfo := range s.Certificates() {
+			if certInfo.Path == filepath.Join(certDir, "tls.crt") {
+				return certInfo.NotAfter
+			}
+		}
+		t.Fatalf("tls.crt not found in scan results: %v", s.Certificates())
+		return time.Time{}
+	}
+
+	if got := notAfter(); !got.Equal(firstExpiry) {
+		t.Fatalf("Expected first certificate expiring %v, got %v", firstExpiry, got)
+	}
+
+	// Rotate the way the kubelet does, by swapping ..data to a new directory
+	secondExpiry := time.Now().Add(90 * 24 * time.Hour).Truncate(time.Second)
+	os.MkdirAll(filepath.Join(certDir, "..2024_02"), 0755)
+	writeCertToFile(t, filepath.Join(certDir, "..2024_02", "tls.crt"), generateTestCertificate(t, 2048, secondExpiry))
+	if err := os.Symlink("..2024_02", filepath.Join(certDir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(certDir, "..data_tmp"), filepath.Join(certDir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := notAfter(); !got.Equal(secondExpiry) {
+		t.Errorf("Expected rotated certificate expiring %v, got %v", secondExpiry, got)
+	}
+}
+
+func TestIncludeCNPatterns(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	writeCertToFile(t, filepath.Join(certDir, "payments.pem"), createCertificateWithCustomSubject(t, "CN=api.payments.example.com"))
+	writeCertToFile(t, filepath.Join(certDir, "blog.pem"), createCertificateWithCustomSubject(t, "CN=blog.example.org"))
+
+	for _, collectorMode := range []bool{false, true} {
+		cfg := &config.Config{
+			CertificateDirectories: []string{certDir},
+			Workers:                1,
+			CacheDir:               filepath.Join(tmpDir, "cache"),
+			CacheTTL:               30 * time.Minute,
+			CacheMaxSize:           10485760,
+			ScanInterval:           1 * time.Minute,
+			CollectorMode:          collectorMode,
+			IncludeCNPatterns:      []string{`\.payments\.`},
+		}
+
+		registry := prometheus.NewRegistry()
+		mc := metrics.NewCollectorWithRegistry(registry)
+		s, err := scanner.New(cfg, mc, logger.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Scan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		// Certificates picked up by the watcher are filtered too
+		ctx, cancel := context.WithCancel(context.Background())
+		go s.WatchFiles(ctx)
+		time.Sleep(100 * time.Millisecond)
+
+		watched := filepath.Join(certDir, "shop.pem")
+		writeCertToFile(t, watched, createCertificateWithCustomSubject(t, "CN=shop.example.org"))
+		deadline := time.Now().Add(5 * time.Second)
+		for len(s.Certificates()) != 3 {
+			if time.Now().After(deadline) {
+				t.Fatalf("collector_mode=%v: expected the watcher to pick up shop.pem", collectorMode)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal("Failed to gather metrics:", err)
+		}
+
+		var paths []string
+		for _, family := range families {
+			if family.GetName() != "ssl_cert_expiration_timestamp" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "path" {
+						paths = append(paths, filepath.Base(label.GetValue()))
+					}
+				}
+			}
+		}
+		if len(paths) != 1 || paths[0] != "payments.pem" {
+			t.Errorf("collector_mode=%v: expected expiration metrics only for payments.pem, got %v", collectorMode, paths)
+		}
+
+		// Excluded certificates still count towards the totals
+		if got := mc.GetMetrics()["certs_parsed_total"]; got != 2 {
+			t.Errorf("collector_mode=%v: expected 2 parsed certificates, got %v", collectorMode, got)
+		}
+		cancel()
+		s.Close()
+		os.Remove(watched)
+	}
+}
+
+func TestScanExcludePaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(filepath.Join(certDir, "staging"), 0755)
+	os.MkdirAll(filepath.Join(certDir, "app", "staging"), 0755)
+	os.MkdirAll(filepath.Join(certDir, "staging-old"), 0755)
+
+	certPEM := generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour))
+	writeCertToFile(t, filepath.Join(certDir, "top.pem"), certPEM)
+	writeCertToFile(t, filepath.Join(certDir, "staging", "excluded.pem"), certPEM)
+	writeCertToFile(t, filepath.Join(certDir, "app", "staging", "nested.pem"), certPEM)
+	writeCertToFile(t, filepath.Join(certDir, "staging-old", "sibling.pem"), certPEM)
+
+	tests := []struct {
+		name     string
+		excludes []string
+		want     []string
+	}{
+		{"absolute", []string{filepath.Join(certDir, "staging")}, []string{"nested.pem", "sibling.pem", "top.pem"}},
+		{"relative to the directory", []string{"staging"}, []string{"nested.pem", "sibling.pem", "top.pem"}},
+		{"nested", []string{"app/staging", "top.pem"}, []string{"excluded.pem", "sibling.pem"}},
+		{"whole directory", []string{certDir}, nil},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{
+			CertificateDirectories: []string{certDir},
+			Workers:                1,
+			CacheDir:               filepath.Join(tmpDir, "cache"),
+			CacheTTL:               30 * time.Minute,
+			CacheMaxSize:           10485760,
+			ScanInterval:           1 * time.Minute,
+			ExcludePaths:           tt.excludes,
+		}
+
+		s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(prometheus.NewRegistry()), logger.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Scan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, certInfo := range s.Certificates() {
+			got = append(got, filepath.Base(certInfo.Path))
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: found %v, want %v", tt.name, got, tt.want)
+		}
+		s.Close()
+	}
+}
+
+func TestPrimaryIdentifier(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	// Carries the DNS SAN test.example.com
+	writeCertToFile(t, filepath.Join(certDir, "marketing.pem"), createCertificateWithCustomSubject(t, "CN=Example Storefront"))
+
+	tests := []struct {
+		identifier string
+		want       string
+	}{
+		{"", "Example Storefront"},
+		{config.IdentifierCommonName, "Example Storefront"},
+		{config.IdentifierFirstSAN, "test.example.com"},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{
+			CertificateDirectories: []string{certDir},
+			Workers:                1,
+			CacheDir:               filepath.Join(tmpDir, "cache"),
+			CacheTTL:               30 * time.Minute,
+			CacheMaxSize:           10485760,
+			ScanInterval:           1 * time.Minute,
+			PrimaryIdentifier:      tt.identifier,
+		}
+
+		registry := prometheus.NewRegistry()
+		s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(registry), logger.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Scan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal("Failed to gather metrics:", err)
+		}
+		var got []string
+		for _, family := range families {
+			if family.GetName() != "ssl_cert_renewal_due" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "common_name" {
+						got = append(got, label.GetValue())
+					}
+				}
+			}
+		}
+		if len(got) != 1 || got[0] != tt.want {
+			t.Errorf("primary_identifier=%q: common_name labels %v, want [%s]", tt.identifier, got, tt.want)
+		}
+		s.Close()
+	}
+}
+
+func TestCertFormatMetric(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	certPEM := generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour))
+	block, _ := pem.Decode(certPEM)
+	writeCertToFile(t, filepath.Join(certDir, "server.pem"), certPEM)
+	writeCertToFile(t, filepath.Join(certDir, "server.der"), block.Bytes)
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(registry), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	formats := make(map[string]string)
+	for _, family := range families {
+		if family.GetName() != "ssl_cert_format" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			formats[labels["file_name"]] = labels["format"]
+		}
+	}
+
+	want := map[string]string{"server.pem": "pem", "server.der": "der"}
+	for fileName, format := range want {
+		if formats[fileName] != format {
+			t.Errorf("ssl_cert_format for %s = %q, want %q (all: %v)", fileName, formats[fileName], format, formats)
+		}
+	}
+}
+
+func TestCertTypeCountMetric(t *testing.T) {
+	tmpDir := t.TempDir()
+	caDir := filepath.Join(tmpDir, "ca")
+	serverDir := filepath.Join(tmpDir, "server")
+	os.MkdirAll(caDir, 0755)
+	os.MkdirAll(serverDir, 0755)
+
+	root, intermediate, leaf := createCAChain(t)
+	writeCertToFile(t, filepath.Join(caDir, "root.pem"), root)
+	writeCertToFile(t, filepath.Join(caDir, "intermediate.pem"), intermediate)
+	writeCertToFile(t, filepath.Join(serverDir, "fullchain.pem"), append(append([]byte{}, leaf...), intermediate...))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{caDir, serverDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(registry), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	counts := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != "ssl_cert_type_count" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			counts[filepath.Base(labels["dir"])+"/"+labels["type"]] = metric.GetGauge().GetValue()
+		}
+	}
+
+	want := map[string]float64{
+		"ca/leaf":             0,
+		"ca/intermediate":     1,
+		"ca/root":             1,
+		"server/leaf":         1,
+		"server/intermediate": 1,
+		"server/root":         0,
+	}
+	for key, count := range want {
+		if value, ok := counts[key]; !ok || value != count {
+			t.Errorf("ssl_cert_type_count %s = %v, want %v (all: %v)", key, value, count, counts)
+		}
+	}
+}
+
+func TestScanEndpoints(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+	writeCertToFile(t, filepath.Join(certDir, "file.pem"), createValidCertificate(t))
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	endpoint := server.Listener.Addr().String()
+
+	// Nothing listens on a port just released
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := listener.Addr().String()
+	listener.Close()
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Endpoints:              []string{endpoint, closed},
+		EndpointTimeout:        2 * time.Second,
+		Workers:                2,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(registry), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	sources := make(map[string]string)
+	var readErrors float64
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			switch family.GetName() {
+			case "ssl_cert_info":
+				sources[labels["path"]] = labels["source"]
+			case "ssl_cert_parse_errors_by_reason":
+				if labels["reason"] == "read_error" {
+					readErrors = metric.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+
+	want := map[string]string{
+		filepath.Join(certDir, "file.pem"): "file",
+		"tls://" + endpoint:                "network",
+	}
+	if len(sources) != len(want) {
+		t.Errorf("ssl_cert_info paths = %v, want %v", sources, want)
+	}
+	for path, source := range want {
+		if sources[path] != source {
+			t.Errorf("ssl_cert_info source for %s = %q, want %q", path, sources[path], source)
+		}
+	}
+
+	// The endpoint's certificate is the one the server presents
+	for _, certInfo := range s.Certificates() {
+		if certInfo.Path != "tls://"+endpoint {
+			continue
+		}
+		if !certInfo.NotAfter.Equal(server.Certificate().NotAfter) {
+			t.Errorf("Endpoint certificate expires %v, want %v", certInfo.NotAfter, server.Certificate().NotAfter)
+		}
+	}
+
+	// An unreachable endpoint is reported as a read error
+	if readErrors != 1 {
+		t.Errorf("read_error parse errors = %v, want 1", readErrors)
+	}
+}
+
+func TestScanEndpointsSNI(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Record the server names requested, falling back to the test
+	// certificate (example.com and 127.0.0.1) for every one of them
+	var requested sync.Map
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			requested.Store(hello.ServerName, true)
+			return nil, nil
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+	address := server.Listener.Addr().String()
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{tmpDir},
+		Endpoints:              []string{address, address + "@example.com", address + "@other.test"},
+		EndpointTimeout:        2 * time.Second,
+		Workers:                2,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(registry), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, serverName := range []string{"example.com", "other.test"} {
+		if _, ok := requested.Load(serverName); !ok {
+			t.Errorf("Server name %q was not requested through SNI", serverName)
+		}
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	matches := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != "ssl_cert_endpoint_sni_match" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["endpoint"] != address {
+				t.Errorf("endpoint label = %q, want %q", labels["endpoint"], address)
+			}
+			if labels["common_name"] == "" {
+				t.Errorf("Missing common_name label for %s", labels["server_name"])
+			}
+			matches[labels["server_name"]] = metric.GetGauge().GetValue()
+		}
+	}
+
+	// Without @ the server name is the host, here an IP SAN of the certificate
+	want := map[string]float64{
+		"127.0.0.1":   1,
+		"example.com": 1,
+		"other.test":  0,
+	}
+	if len(matches) != len(want) {
+		t.Errorf("ssl_cert_endpoint_sni_match = %v, want %v", matches, want)
+	}
+	for serverName, value := range want {
+		if got, ok := matches[serverName]; !ok || got != value {
+			t.Errorf("ssl_cert_endpoint_sni_match for %s = %v, want %v", serverName, got, value)
+		}
+	}
+}
+
+func TestBackoffOnParseErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	junkDir := filepath.Join(tmpDir, "junk")
+	os.MkdirAll(junkDir, 0755)
+	os.WriteFile(filepath.Join(junkDir, "a.crt"), []byte("not a certificate"), 0644)
+	os.WriteFile(filepath.Join(junkDir, "b.crt"), []byte("not a certificate either"), 0644)
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{junkDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+		BackoffOnParseErrors:   true,
+		ParseErrorThreshold:    1,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	backoff := func() float64 {
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal("Failed to gather metrics:", err)
+		}
+		for _, family := range families {
+			if family.GetName() == "ssl_cert_dir_backoff" {
+				return family.GetMetric()[0].GetGauge().GetValue()
+			}
+		}
+		return -1
+	}
+
+	// Each scan reports the files it read: the first fails, the second is
+	// skipped, the third fails again and doubles the backoff
+	for i, wantFiles := range []float64{2, 0, 2, 0, 0} {
+		if err := s.Scan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got := metricsCollector.GetMetrics()["cert_files_total"]; got != wantFiles {
+			t.Fatalf("Scan %d read %v files, want %v", i+1, got, wantFiles)
+		}
+	}
+	if backoff() != 1 {
+		t.Errorf("Expected ssl_cert_dir_backoff to be 1, got %v", backoff())
+	}
+
+	// A valid certificate ends the backoff once the directory is scanned again
+	writeCertToFile(t, filepath.Join(junkDir, "server.crt"), createValidCertificate(t))
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := metricsCollector.GetMetrics()["certs_parsed_total"]; got != 1 {
+		t.Errorf("Expected the certificate to be parsed, got %v", got)
+	}
+	if backoff() != 0 {
+		t.Errorf("Expected ssl_cert_dir_backoff to be 0, got %v", backoff())
+	}
+}
+
+func TestCertExpiryHistogram(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+
+	for name, days := range map[string]int{"soon.pem": 10, "later.pem": 45, "distant.pem": 365} {
+		certPEM := generateTestCertificate(t, 2048, time.Now().Add(time.Duration(days)*24*time.Hour))
+		writeCertToFile(t, filepath.Join(certDir, name), certPEM)
+	}
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	mc := metrics.NewCollectorWithOptions(registry, metrics.Options{
+		Prefix:        metrics.DefaultPrefix,
+		ExpiryBuckets: []float64{30, 60},
+	})
+	s, err := scanner.New(cfg, mc, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// A second scan must replace the distribution rather than add to it
+	for i := 0; i < 2; i++ {
+		if err := s.Scan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Nor may a watcher update add to it until the next scan
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.WatchFiles(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	writeCertToFile(t, filepath.Join(certDir, "added.pem"), generateTestCertificate(t, 2048, time.Now().Add(20*24*time.Hour)))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.Certificates()) != 4 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the watcher to pick up the added certificate")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Failed to gather metrics:", err)
+	}
+
+	var found bool
+	for _, family := range families {
+		if family.GetName() != "ssl_cert_expiry_days" || len(family.GetMetric()) != 1 {
+			continue
+		}
+		found = true
+		histogram := family.GetMetric()[0].GetHistogram()
+
+		if histogram.GetSampleCount() != 3 {
+			t.Errorf("Expected 3 observations, got %d", histogram.GetSampleCount())
+		}
+		want := map[float64]uint64{30: 1, 60: 2}
+		for _, bucket := range histogram.GetBucket() {
+			if count, ok := want[bucket.GetUpperBound()]; ok && bucket.GetCumulativeCount() != count {
+				t.Errorf("Bucket le=%v: expected %d, got %d", bucket.GetUpperBound(), count, bucket.GetCumulativeCount())
+			}
+		}
+		if len(histogram.GetBucket()) != len(want) {
+			t.Errorf("Expected %d buckets, got %d", len(want), len(histogram.GetBucket()))
+		}
+	}
+	if !found {
+		t.Fatal("ssl_cert_expiry_days histogram not exported")
+	}
+}
+
+func TestScannerDumpCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+	writeCertToFile(t, filepath.Join(certDir, "a.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+	}
+
+	s, err := scanner.New(cfg, metrics.NewCollectorWithRegistry(prometheus.NewRegistry()), logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	certs := s.Certificates()
+	if len(certs) != 1 {
+		t.Fatalf("Expected 1 certificate, got %d", len(certs))
+	}
+
+	var buf bytes.Buffer
+	if err := s.DumpCache(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dump := buf.String()
+
+	for _, want := range []string{
+		"fingerprint=" + certs[0].Fingerprint,
+		"not_after=" + certs[0].NotAfter.UTC().Format(time.RFC3339),
+		"mod_time=",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("Expected %q in the cache dump, got:\n%s", want, dump)
+		}
+	}
+	if strings.Contains(dump, "mod_time=-") {
+		t.Errorf("Expected the file's modification time in the cache dump, got:\n%s", dump)
+	}
+}
+
+func TestPruneExpiredCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+	writeCertToFile(t, filepath.Join(certDir, "valid.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+	writeCertToFile(t, filepath.Join(certDir, "stale.pem"), createExpiredCertificate(t, 2048))
+
+	cfg := &config.Config{
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+		ScanInterval:           1 * time.Minute,
+		PruneExpiredAfter:      7 * 24 * time.Hour,
+	}
+
+	metricsCollector := metrics.NewCollectorWithRegistry(prometheus.NewRegistry())
+	s, err := scanner.New(cfg, metricsCollector, logger.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := s.Scan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The stale certificate is re-read each time; the valid one only once
+	got := metricsCollector.GetMetrics()
+	if got["cache_misses_total"] != 3 || got["cache_hits_total"] != 1 {
+		t.Errorf("Expected 3 cache misses and 1 hit, got %v misses and %v hits",
+			got["cache_misses_total"], got["cache_hits_total"])
+	}
+
+	// Pruned certificates are still reported
+	if n := len(s.Certificates()); n != 2 {
+		t.Errorf("Expected both certificates in the results, got %d", n)
+	}
+}
@@ -2,19 +2,34 @@ package test
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/brandonhon/tls-cert-monitor/internal/cert"
 	"github.com/brandonhon/tls-cert-monitor/internal/config"
 	"github.com/brandonhon/tls-cert-monitor/internal/health"
+	"github.com/brandonhon/tls-cert-monitor/internal/logbuffer"
 	"github.com/brandonhon/tls-cert-monitor/internal/logger"
 	"github.com/brandonhon/tls-cert-monitor/internal/metrics"
+	"github.com/brandonhon/tls-cert-monitor/internal/scanner"
 	"github.com/brandonhon/tls-cert-monitor/internal/server"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestServerEndpoints(t *testing.T) {
@@ -266,6 +281,70 @@ func TestMetricsEndpoint(t *testing.T) {
 	}
 }
 
+func TestMetricsEndpointOpenMetrics(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled=%v", enabled), func(t *testing.T) {
+			port := generateTestPort()
+			cfg := &config.Config{
+				Port:                   port,
+				BindAddress:            "127.0.0.1",
+				CertificateDirectories: []string{t.TempDir()},
+				Workers:                2,
+				LogLevel:               "info",
+				ScanInterval:           1 * time.Minute,
+				EnableOpenMetrics:      enabled,
+			}
+
+			registry := prometheus.NewRegistry()
+			metricsCollector := metrics.NewCollectorWithRegistry(registry)
+			healthChecker := health.New(cfg, metricsCollector)
+			metricsCollector.SetCertFilesTotal(1)
+
+			srv := server.NewWithRegistry(cfg, metricsCollector, healthChecker, logger.NewNop(), registry)
+			go func() {
+				if err := srv.Start(); err != nil && err != http.ErrServerClosed {
+					t.Errorf("Server start error: %v", err)
+				}
+			}()
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				_ = srv.Shutdown(ctx)
+			}()
+			time.Sleep(100 * time.Millisecond)
+
+			req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/metrics", port), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			contentType := resp.Header.Get("Content-Type")
+			isOpenMetrics := strings.HasPrefix(contentType, "application/openmetrics-text")
+			if isOpenMetrics != enabled {
+				t.Errorf("Content-Type = %q with enable_openmetrics=%v", contentType, enabled)
+			}
+			if hasEOF := strings.HasSuffix(string(body), "# EOF\n"); hasEOF != enabled {
+				t.Errorf("Body ends in # EOF = %v, want %v", hasEOF, enabled)
+			}
+			if !contains(string(body), "ssl_cert_files_total") {
+				t.Error("Metrics response missing ssl_cert_files_total")
+			}
+		})
+	}
+}
+
 func TestGracefulShutdown(t *testing.T) {
 	// Setup
 	port := generateTestPort()
@@ -326,3 +405,1166 @@ func TestGracefulShutdown(t *testing.T) {
 		t.Error("Server did not stop after shutdown")
 	}
 }
+
+func TestVerifyEndpoint(t *testing.T) {
+	// Setup
+	port := generateTestPort()
+	certDir := t.TempDir()
+	certPath := filepath.Join(certDir, "server.pem")
+	cert := generateCertificateWithSANs(t, 2048, time.Now().Add(365*24*time.Hour),
+		[]string{"api.example.com", "*.example.com"}, []net.IP{net.ParseIP("10.0.0.1")})
+	writeCertToFile(t, certPath, cert)
+
+	cfg := &config.Config{
+		Port:                   port,
+		BindAddress:            "127.0.0.1",
+		CertificateDirectories: []string{certDir},
+		Workers:                2,
+		LogLevel:               "info",
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	healthChecker := health.New(cfg, metricsCollector)
+	log := logger.NewNop()
+
+	srv := server.NewWithRegistry(cfg, metricsCollector, healthChecker, log, registry)
+
+	// Start server
+	go func() {
+		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
+			t.Errorf("Server start error: %v", err)
+		}
+	}()
+
+	// Wait for server to start
+	time.Sleep(100 * time.Millisecond)
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d/verify", port)
+
+	tests := []struct {
+		name        string
+		file        string
+		host        string
+		wantStatus  int
+		wantMatched bool
+		wantSANs    int
+	}{
+		{"exact", certPath, "api.example.com", http.StatusOK, true, 2},
+		{"wildcard", certPath, "www.example.com", http.StatusOK, true, 1},
+		{"wildcard_too_deep", certPath, "a.b.example.com", http.StatusOK, false, 0},
+		{"ip", certPath, "10.0.0.1", http.StatusOK, true, 1},
+		{"mismatch", certPath, "other.org", http.StatusOK, false, 0},
+		{"outside_dirs", "/etc/passwd", "api.example.com", http.StatusForbidden, false, 0},
+		{"missing_name", certPath, "", http.StatusBadRequest, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := url.Values{"file": {tt.file}, "name": {tt.host}}
+			resp, err := http.Get(baseURL + "?" + query.Encode())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("Status code = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var result struct {
+				Matched     bool     `json:"matched"`
+				MatchedSANs []string `json:"matched_sans"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Matched != tt.wantMatched {
+				t.Errorf("matched = %v, want %v", result.Matched, tt.wantMatched)
+			}
+			if len(result.MatchedSANs) != tt.wantSANs {
+				t.Errorf("matched_sans = %v, want %d entries", result.MatchedSANs, tt.wantSANs)
+			}
+		})
+	}
+
+	// Shutdown server
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Errorf("Server shutdown error: %v", err)
+	}
+}
+
+func TestCacheClearEndpoint(t *testing.T) {
+	// Setup
+	port := generateTestPort()
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	if err := os.MkdirAll(certDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeCertToFile(t, filepath.Join(certDir, "a.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+	writeCertToFile(t, filepath.Join(certDir, "b.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	cfg := &config.Config{
+		Port:                   port,
+		BindAddress:            "127.0.0.1",
+		AuthToken:              "secret-token",
+		CertificateDirectories: []string{certDir},
+		Workers:                2,
+		LogLevel:               "info",
+		ScanInterval:           1 * time.Minute,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	healthChecker := health.New(cfg, metricsCollector)
+	log := logger.NewNop()
+
+	certScanner, err := scanner.New(cfg, metricsCollector, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer certScanner.Close()
+
+	// Populate the cache
+	if err := certScanner.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := server.NewWithRegistry(cfg, metricsCollector, healthChecker, log, registry)
+	srv.SetScanner(certScanner)
+
+	// Start server
+	go func() {
+		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
+			t.Errorf("Server start error: %v", err)
+		}
+	}()
+
+	// Wait for server to start
+	time.Sleep(100 * time.Millisecond)
+
+	endpoint := fmt.Sprintf("http://127.0.0.1:%d/cache/clear", port)
+
+	clearCache := func(method, token string) *http.Response {
+		req, err := http.NewRequest(method, endpoint, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	t.Run("missing_token", func(t *testing.T) {
+		resp := clearCache(http.MethodPost, "")
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Status code = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("wrong_method", func(t *testing.T) {
+		resp := clearCache(http.MethodGet, "secret-token")
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("Status code = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+		}
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		resp := clearCache(http.MethodPost, "secret-token")
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Status code = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+
+		var result struct {
+			Cleared       int  `json:"cleared"`
+			RescanStarted bool `json:"rescan_started"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+
+		if result.Cleared != 2 {
+			t.Errorf("cleared = %d, want 2", result.Cleared)
+		}
+		if !result.RescanStarted {
+			t.Error("Expected rescan to be started")
+		}
+	})
+
+	// Shutdown server; it waits for the background rescan
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Errorf("Server shutdown error: %v", err)
+	}
+}
+
+// blockingScanner is a scanner whose scans run until canceled
+type blockingScanner struct {
+	server.Scanner
+	started  chan struct{}
+	finished chan struct{}
+}
+
+func (b *blockingScanner) ClearCache() int {
+	return 0
+}
+
+func (b *blockingScanner) Scan(ctx context.Context) error {
+	close(b.started)
+	<-ctx.Done()
+	time.Sleep(100 * time.Millisecond)
+	close(b.finished)
+	return ctx.Err()
+}
+
+func TestCacheClearRescanCanceledOnShutdown(t *testing.T) {
+	port := generateTestPort()
+	cfg := &config.Config{
+		Port:        port,
+		BindAddress: "127.0.0.1",
+		LogLevel:    "info",
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	srv := server.NewWithRegistry(cfg, metricsCollector, health.New(cfg, metricsCollector), logger.NewNop(), registry)
+	stub := &blockingScanner{started: make(chan struct{}), finished: make(chan struct{})}
+	srv.SetScanner(stub)
+
+	go func() {
+		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
+			t.Errorf("Server start error: %v", err)
+		}
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	resp, err := http.Post(fmt.Sprintf("http://127.0.0.1:%d/cache/clear", port), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Status code = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	select {
+	case <-stub.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the cache clear to start a rescan")
+	}
+
+	// Shutdown cancels the rescan and returns once it has stopped
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Errorf("Server shutdown error: %v", err)
+	}
+	select {
+	case <-stub.finished:
+	default:
+		t.Error("Shutdown returned before the rescan stopped")
+	}
+}
+
+func TestAlertsEndpoint(t *testing.T) {
+	// Setup
+	port := generateTestPort()
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	if err := os.MkdirAll(certDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeCertToFile(t, filepath.Join(certDir, "expired.pem"), createExpiredCertificate(t, 2048))
+	writeCertToFile(t, filepath.Join(certDir, "expiring.pem"), generateTestCertificate(t, 2048, time.Now().Add(5*24*time.Hour)))
+	writeCertToFile(t, filepath.Join(certDir, "valid.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	cfg := &config.Config{
+		Port:                   port,
+		BindAddress:            "127.0.0.1",
+		AuthToken:              "secret-token",
+		EnableAlertsEndpoint:   true,
+		ExpiryThreshold:        30 * 24 * time.Hour,
+		CertificateDirectories: []string{certDir},
+		Workers:                2,
+		LogLevel:               "info",
+		ScanInterval:           1 * time.Minute,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	healthChecker := health.New(cfg, metricsCollector)
+	log := logger.NewNop()
+
+	certScanner, err := scanner.New(cfg, metricsCollector, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer certScanner.Close()
+
+	if err := certScanner.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := server.NewWithRegistry(cfg, metricsCollector, healthChecker, log, registry)
+	srv.SetScanner(certScanner)
+
+	// Start server
+	go func() {
+		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
+			t.Errorf("Server start error: %v", err)
+		}
+	}()
+
+	// Wait for server to start
+	time.Sleep(100 * time.Millisecond)
+
+	endpoint := fmt.Sprintf("http://127.0.0.1:%d/alerts", port)
+
+	// Alerts name certificate files, so they sit behind the token
+	resp, err := http.Get(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Status code without token = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret-token")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Status code = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var alerts []struct {
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+		StartsAt    time.Time         `json:"startsAt"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&alerts); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(alerts) != 2 {
+		t.Fatalf("Got %d alerts, want 2", len(alerts))
+	}
+
+	byFile := make(map[string]string)
+	for _, a := range alerts {
+		byFile[a.Labels["file_name"]] = a.Labels["alertname"]
+		if a.Annotations["summary"] == "" {
+			t.Errorf("Alert for %s has no summary", a.Labels["file_name"])
+		}
+	}
+	if byFile["expired.pem"] != "CertificateExpired" {
+		t.Errorf("expired.pem alertname = %q, want CertificateExpired", byFile["expired.pem"])
+	}
+	if byFile["expiring.pem"] != "CertificateExpiringSoon" {
+		t.Errorf("expiring.pem alertname = %q, want CertificateExpiringSoon", byFile["expiring.pem"])
+	}
+
+	// Shutdown server
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Errorf("Server shutdown error: %v", err)
+	}
+}
+
+func TestCertsEndpoint(t *testing.T) {
+	// Setup
+	port := generateTestPort()
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	if err := os.MkdirAll(certDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeCertToFile(t, filepath.Join(certDir, "cert.pem"), generateTestCertificate(t, 2048, time.Now().Add(365*24*time.Hour)))
+
+	cfg := &config.Config{
+		Port:                   port,
+		BindAddress:            "127.0.0.1",
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		LogLevel:               "info",
+		ScanInterval:           1 * time.Minute,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	healthChecker := health.New(cfg, metricsCollector)
+	log := logger.NewNop()
+
+	certScanner, err := scanner.New(cfg, metricsCollector, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer certScanner.Close()
+
+	if err := certScanner.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := server.NewWithRegistry(cfg, metricsCollector, healthChecker, log, registry)
+	srv.SetScanner(certScanner)
+
+	// Start server
+	go func() {
+		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
+			t.Errorf("Server start error: %v", err)
+		}
+	}()
+
+	// Wait for server to start
+	time.Sleep(100 * time.Millisecond)
+
+	type certResponse struct {
+		Path        string `json:"path"`
+		Fingerprint string `json:"fingerprint"`
+		SPKISHA256  string `json:"spki_sha256"`
+		PEM         string `json:"pem"`
+	}
+
+	getCerts := func(query string) []certResponse {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/certs%s", port, query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Status code = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+
+		var certs []certResponse
+		if err := json.NewDecoder(resp.Body).Decode(&certs); err != nil {
+			t.Fatal(err)
+		}
+		if len(certs) != 1 {
+			t.Fatalf("Got %d certificates, want 1", len(certs))
+		}
+		return certs
+	}
+
+	// PEM is only included on request
+	if certs := getCerts(""); certs[0].PEM != "" {
+		t.Error("Expected no PEM without include_pem")
+	}
+
+	certs := getCerts("?include_pem=true")
+	block, _ := pem.Decode([]byte(certs[0].PEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		t.Fatalf("Expected a PEM certificate, got %q", certs[0].PEM)
+	}
+	digest := sha256.Sum256(block.Bytes)
+	if hex.EncodeToString(digest[:]) != certs[0].Fingerprint {
+		t.Error("Embedded PEM does not match the certificate fingerprint")
+	}
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certs[0].SPKISHA256 != cert.SPKISHA256(parsed) {
+		t.Errorf("spki_sha256 = %q, want %q", certs[0].SPKISHA256, cert.SPKISHA256(parsed))
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/certs?include_pem=maybe", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Status code = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	// Shutdown server
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Errorf("Server shutdown error: %v", err)
+	}
+}
+
+func TestDiskEndpointRateLimit(t *testing.T) {
+	// Setup
+	port := generateTestPort()
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	if err := os.MkdirAll(certDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	certPath := filepath.Join(certDir, "cert.pem")
+	writeCertToFile(t, certPath, generateCertificateWithSANs(t, 2048, time.Now().Add(365*24*time.Hour), []string{"www.example.com"}, nil))
+
+	cfg := &config.Config{
+		Port:                   port,
+		BindAddress:            "127.0.0.1",
+		DiskEndpointRateLimit:  0.5,
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		LogLevel:               "info",
+		ScanInterval:           1 * time.Minute,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	healthChecker := health.New(cfg, metricsCollector)
+	log := logger.NewNop()
+
+	certScanner, err := scanner.New(cfg, metricsCollector, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer certScanner.Close()
+
+	srv := server.NewWithRegistry(cfg, metricsCollector, healthChecker, log, registry)
+	srv.SetScanner(certScanner)
+
+	// Start server
+	go func() {
+		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
+			t.Errorf("Server start error: %v", err)
+		}
+	}()
+
+	// Wait for server to start
+	time.Sleep(100 * time.Millisecond)
+
+	get := func(path string) *http.Response {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", port, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := get("/certs"); resp.StatusCode != http.StatusOK {
+		t.Errorf("First request status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	// /verify shares the bucket with /certs
+	resp := get("/verify?" + url.Values{"file": {certPath}, "name": {"www.example.com"}}.Encode())
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Over-limit status = %d, want %d", resp.StatusCode, http.StatusTooManyRequests)
+	}
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("Retry-After = %q, want 2", retryAfter)
+	}
+
+	// Endpoints that don't read certificate files are not limited
+	if resp := get("/healthz"); resp.StatusCode == http.StatusTooManyRequests {
+		t.Error("Health endpoint should not be rate limited")
+	}
+
+	// Shutdown server
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Errorf("Server shutdown error: %v", err)
+	}
+}
+
+func TestServerUpdateConfig(t *testing.T) {
+	// Setup
+	port := generateTestPort()
+	certDir := t.TempDir()
+	cfg := &config.Config{
+		Port:                   port,
+		BindAddress:            "127.0.0.1",
+		CertificateDirectories: []string{certDir},
+		Workers:                2,
+		LogLevel:               "info",
+		ScanInterval:           1 * time.Minute,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	healthChecker := health.New(cfg, metricsCollector)
+	srv := server.NewWithRegistry(cfg, metricsCollector, healthChecker, logger.NewNop(), registry)
+
+	// Start server
+	go func() {
+		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
+			t.Errorf("Server start error: %v", err)
+		}
+	}()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			t.Errorf("Server shutdown error: %v", err)
+		}
+	}()
+
+	// Wait for server to start
+	time.Sleep(100 * time.Millisecond)
+
+	get := func(path, token string) (*http.Response, []byte) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d%s", port, path), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, body
+	}
+
+	configWorkers := func(token string) float64 {
+		t.Helper()
+		resp, body := get("/config", token)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("/config status = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+		var response struct {
+			Config map[string]interface{} `json:"config"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			t.Fatal(err)
+		}
+		workers, _ := response.Config["workers"].(float64)
+		return workers
+	}
+
+	if got := configWorkers(""); got != 2 {
+		t.Errorf("workers before the reload = %v, want 2", got)
+	}
+	if resp, _ := get("/alerts", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("/alerts status before the reload = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+
+	// A reload changes the settings served and the endpoint gating
+	srv.UpdateConfig(&config.Config{
+		Port:                   port,
+		BindAddress:            "127.0.0.1",
+		CertificateDirectories: []string{certDir},
+		Workers:                3,
+		LogLevel:               "info",
+		ScanInterval:           1 * time.Minute,
+		AuthToken:              "s3cret",
+		EnableAlertsEndpoint:   true,
+		DiskEndpointRateLimit:  0.5,
+	})
+
+	if resp, _ := get("/config", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("/config status without the new token = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if got := configWorkers("s3cret"); got != 3 {
+		t.Errorf("workers after the reload = %v, want 3", got)
+	}
+	if resp, _ := get("/alerts", ""); resp.StatusCode == http.StatusNotFound {
+		t.Error("/alerts should be served once enabled by a reload")
+	}
+
+	// The new rate limit applies to the endpoints reading certificate files
+	get("/verify?file=x&name=y", "")
+	if resp, _ := get("/verify?file=x&name=y", ""); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Over-limit status after the reload = %d, want %d", resp.StatusCode, http.StatusTooManyRequests)
+	}
+}
+
+func TestCertSearchEndpoint(t *testing.T) {
+	// Setup
+	port := generateTestPort()
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	if err := os.MkdirAll(certDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeCertToFile(t, filepath.Join(certDir, "api.pem"), createCertificateWithCustomSubject(t, "CN=api.example.com,O=Example"))
+	writeCertToFile(t, filepath.Join(certDir, "api-internal.pem"), createCertificateWithCustomSubject(t, "CN=api.example.com.internal,O=Example"))
+	writeCertToFile(t, filepath.Join(certDir, "web.pem"), createCertificateWithCustomSubject(t, "CN=web.example.com,O=Example"))
+
+	cfg := &config.Config{
+		Port:                   port,
+		BindAddress:            "127.0.0.1",
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		LogLevel:               "info",
+		ScanInterval:           1 * time.Minute,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	healthChecker := health.New(cfg, metricsCollector)
+	log := logger.NewNop()
+
+	certScanner, err := scanner.New(cfg, metricsCollector, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer certScanner.Close()
+
+	if err := certScanner.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := server.NewWithRegistry(cfg, metricsCollector, healthChecker, log, registry)
+	srv.SetScanner(certScanner)
+
+	// Start server
+	go func() {
+		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
+			t.Errorf("Server start error: %v", err)
+		}
+	}()
+
+	// Wait for server to start
+	time.Sleep(100 * time.Millisecond)
+
+	search := func(query string) (int, []string) {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/certs/search?%s", port, query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return resp.StatusCode, nil
+		}
+
+		var certs []struct {
+			CommonName string `json:"common_name"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&certs); err != nil {
+			t.Fatal(err)
+		}
+		names := make([]string, 0, len(certs))
+		for _, c := range certs {
+			names = append(names, c.CommonName)
+		}
+		return resp.StatusCode, names
+	}
+
+	tests := []struct {
+		query  string
+		status int
+		names  []string
+	}{
+		{"cn=API.example", http.StatusOK, []string{"api.example.com.internal", "api.example.com"}},
+		{"cn=api.example.com&exact=true", http.StatusOK, []string{"api.example.com"}},
+		{"cn=api.example&exact=true", http.StatusNotFound, nil},
+		{"cn=mail", http.StatusNotFound, nil},
+		{"", http.StatusBadRequest, nil},
+		{"cn=api&exact=maybe", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		status, names := search(tt.query)
+		if status != tt.status {
+			t.Errorf("%s: status code = %d, want %d", tt.query, status, tt.status)
+			continue
+		}
+		if fmt.Sprint(names) != fmt.Sprint(tt.names) && tt.names != nil {
+			t.Errorf("%s: common names = %v, want %v", tt.query, names, tt.names)
+		}
+	}
+
+	// Misses explain themselves as JSON
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/certs/search?cn=mail", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if body["error"] == "" {
+		t.Error("Expected an error message for a search without matches")
+	}
+
+	// Shutdown server
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Errorf("Server shutdown error: %v", err)
+	}
+}
+
+func TestPprofEndpoint(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		port := generateTestPort()
+		cfg := &config.Config{
+			Port:                   port,
+			BindAddress:            "127.0.0.1",
+			AuthToken:              "secret-token",
+			EnablePprof:            enabled,
+			CertificateDirectories: []string{t.TempDir()},
+			Workers:                1,
+			LogLevel:               "info",
+			ScanInterval:           1 * time.Minute,
+		}
+
+		registry := prometheus.NewRegistry()
+		metricsCollector := metrics.NewCollectorWithRegistry(registry)
+		healthChecker := health.New(cfg, metricsCollector)
+		srv := server.NewWithRegistry(cfg, metricsCollector, healthChecker, logger.NewNop(), registry)
+
+		go func() {
+			if err := srv.Start(); err != nil && err != http.ErrServerClosed {
+				t.Errorf("Server start error: %v", err)
+			}
+		}()
+		time.Sleep(100 * time.Millisecond)
+
+		get := func(path, token string) int {
+			req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d%s", port, path), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		if enabled {
+			if code := get("/debug/pprof/cmdline", ""); code != http.StatusUnauthorized {
+				t.Errorf("Status code without token = %d, want %d", code, http.StatusUnauthorized)
+			}
+			for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/pprof/heap"} {
+				if code := get(path, "secret-token"); code != http.StatusOK {
+					t.Errorf("%s status code = %d, want %d", path, code, http.StatusOK)
+				}
+			}
+		} else if code := get("/debug/pprof/", "secret-token"); code != http.StatusNotFound {
+			t.Errorf("Status code with enable_pprof off = %d, want %d", code, http.StatusNotFound)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := srv.Shutdown(ctx); err != nil {
+			t.Errorf("Server shutdown error: %v", err)
+		}
+		cancel()
+	}
+}
+
+func TestDebugLogsEndpoint(t *testing.T) {
+	port := generateTestPort()
+	cfg := &config.Config{
+		Port:                   port,
+		BindAddress:            "127.0.0.1",
+		AuthToken:              "secret-token",
+		EnablePprof:            true,
+		CertificateDirectories: []string{t.TempDir()},
+		Workers:                1,
+		LogLevel:               "info",
+		ScanInterval:           1 * time.Minute,
+	}
+
+	// A buffer of three entries keeps the last three of four messages
+	logs := logbuffer.New(3)
+	log := zap.New(logs.Core(zapcore.InfoLevel))
+	log.Debug("filtered")
+	log.Info("one")
+	log.Info("two")
+	log.With(zap.String("dir", "/etc/ssl")).Warn("three", zap.Int("errors", 2))
+	log.Error("four")
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	healthChecker := health.New(cfg, metricsCollector)
+	srv := server.NewWithRegistry(cfg, metricsCollector, healthChecker, logger.NewNop(), registry)
+	srv.SetLogBuffer(logs)
+
+	go func() {
+		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
+			t.Errorf("Server start error: %v", err)
+		}
+	}()
+	time.Sleep(100 * time.Millisecond)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	get := func(query, token string) (int, []logbuffer.Entry) {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/debug/logs%s", port, query), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var entries []logbuffer.Entry
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode, entries
+	}
+
+	if code, _ := get("", ""); code != http.StatusUnauthorized {
+		t.Errorf("Status code without token = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code, _ := get("?limit=x", "secret-token"); code != http.StatusBadRequest {
+		t.Errorf("Status code with an invalid limit = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	code, entries := get("", "secret-token")
+	if code != http.StatusOK {
+		t.Fatalf("Status code = %d, want %d", code, http.StatusOK)
+	}
+	var messages []string
+	for _, entry := range entries {
+		messages = append(messages, entry.Message)
+	}
+	if got := fmt.Sprint(messages); got != "[two three four]" {
+		t.Errorf("Messages = %s, want [two three four]", got)
+	}
+	if len(entries) == 3 {
+		three := entries[1]
+		if three.Level != "WARN" || three.Fields["dir"] != "/etc/ssl" || three.Fields["errors"] != float64(2) {
+			t.Errorf("Unexpected entry for three: %+v", three)
+		}
+	}
+
+	if _, entries := get("?limit=1", "secret-token"); len(entries) != 1 || entries[0].Message != "four" {
+		t.Errorf("Entries with limit=1 = %+v, want only four", entries)
+	}
+}
+
+func TestDebugScanEndpoint(t *testing.T) {
+	tmpDir := t.TempDir()
+	certDir := filepath.Join(tmpDir, "certs")
+	os.MkdirAll(certDir, 0755)
+	writeCertToFile(t, filepath.Join(certDir, "good.pem"), createCertificateWithCustomSubject(t, "CN=debug.example.com"))
+	writeCertToFile(t, filepath.Join(certDir, "broken.crt"), []byte("not a certificate"))
+	writeCertToFile(t, filepath.Join(certDir, "notes.txt"), []byte("hello"))
+
+	port := generateTestPort()
+	cfg := &config.Config{
+		Port:                   port,
+		BindAddress:            "127.0.0.1",
+		AuthToken:              "secret-token",
+		EnablePprof:            true,
+		CertificateDirectories: []string{certDir},
+		Workers:                1,
+		LogLevel:               "info",
+		ScanInterval:           1 * time.Minute,
+		CacheDir:               filepath.Join(tmpDir, "cache"),
+		CacheTTL:               30 * time.Minute,
+		CacheMaxSize:           10485760,
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	healthChecker := health.New(cfg, metricsCollector)
+	log := logger.NewNop()
+
+	certScanner, err := scanner.New(cfg, metricsCollector, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer certScanner.Close()
+
+	srv := server.NewWithRegistry(cfg, metricsCollector, healthChecker, log, registry)
+	srv.SetScanner(certScanner)
+
+	go func() {
+		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
+			t.Errorf("Server start error: %v", err)
+		}
+	}()
+	time.Sleep(100 * time.Millisecond)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	post := func(dir, token string) *http.Response {
+		target := fmt.Sprintf("http://127.0.0.1:%d/debug/scan?dir=%s", port, url.QueryEscape(dir))
+		req, err := http.NewRequest(http.MethodPost, target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	for _, tt := range []struct {
+		dir, token string
+		want       int
+	}{
+		{certDir, "", http.StatusUnauthorized},
+		{tmpDir, "secret-token", http.StatusForbidden},
+		{filepath.Join(certDir, "missing"), "secret-token", http.StatusNotFound},
+	} {
+		resp := post(tt.dir, tt.token)
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("POST /debug/scan?dir=%s status = %d, want %d", tt.dir, resp.StatusCode, tt.want)
+		}
+	}
+
+	resp := post(certDir, "secret-token")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Status code = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var body struct {
+		Files []struct {
+			Path        string `json:"path"`
+			Status      string `json:"status"`
+			Reason      string `json:"reason"`
+			Certificate *struct {
+				CommonName string `json:"common_name"`
+			} `json:"certificate"`
+		} `json:"files"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := make(map[string]string)
+	for _, file := range body.Files {
+		statuses[filepath.Base(file.Path)] = file.Status
+		if filepath.Base(file.Path) == "good.pem" && (file.Certificate == nil || file.Certificate.CommonName != "debug.example.com") {
+			t.Errorf("Expected good.pem to report debug.example.com, got %+v", file.Certificate)
+		}
+		if filepath.Base(file.Path) == "broken.crt" && file.Reason != "no_pem_block" {
+			t.Errorf("broken.crt reason = %q, want no_pem_block", file.Reason)
+		}
+	}
+	want := map[string]string{"good.pem": "parsed", "broken.crt": "failed", "notes.txt": "ignored"}
+	for name, status := range want {
+		if statuses[name] != status {
+			t.Errorf("%s status = %q, want %q (all: %v)", name, statuses[name], status, statuses)
+		}
+	}
+
+	// The published results stay as they were
+	if got := len(certScanner.Certificates()); got != 0 {
+		t.Errorf("Expected no published certificates without a regular scan, got %d", got)
+	}
+}
+
+func TestServerSelfCertExpiration(t *testing.T) {
+	tmpDir := t.TempDir()
+	notAfter := time.Now().Add(90 * 24 * time.Hour).Truncate(time.Second)
+	certPath, keyPath := writeTLSKeyPair(t, tmpDir, notAfter)
+
+	port := generateTestPort()
+	cfg := &config.Config{
+		Port:                   port,
+		BindAddress:            "127.0.0.1",
+		CertificateDirectories: []string{tmpDir},
+		TLSCert:                certPath,
+		TLSKey:                 keyPath,
+		LogLevel:               "info",
+	}
+
+	registry := prometheus.NewRegistry()
+	metricsCollector := metrics.NewCollectorWithRegistry(registry)
+	healthChecker := health.New(cfg, metricsCollector)
+	srv := server.NewWithRegistry(cfg, metricsCollector, healthChecker, logger.NewNop(), registry)
+
+	go func() {
+		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
+			t.Errorf("Server start error: %v", err)
+		}
+	}()
+	time.Sleep(100 * time.Millisecond)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+	resp, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d/metrics", port))
+	if err != nil {
+		t.Fatal("Failed to scrape over HTTPS:", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	for _, metric := range parsePrometheusMetrics(string(body)) {
+		if metric.Name == "ssl_cert_monitor_self_expiration_timestamp" {
+			if metric.Labels["path"] != certPath {
+				t.Errorf("Expected path label %q, got %q", certPath, metric.Labels["path"])
+			}
+			if metric.Value != float64(notAfter.Unix()) {
+				t.Errorf("Expected expiration %d, got %v", notAfter.Unix(), metric.Value)
+			}
+			return
+		}
+	}
+	t.Errorf("ssl_cert_monitor_self_expiration_timestamp not exported:\n%s", body)
+}